@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/Devon-White/docs-cloner/pkg/cloner"
+)
+
+// plannedPage is the JSON form of a --dry-run entry.
+type plannedPage struct {
+	URL        string `json:"url"`
+	Path       string `json:"path"`
+	LastMod    string `json:"lastmod,omitempty"`
+	ChangeFreq string `json:"changefreq,omitempty"`
+	Priority   string `json:"priority,omitempty"`
+	Lang       string `json:"lang,omitempty"`
+	Version    string `json:"version,omitempty"`
+}
+
+// printPlan writes the pages a run would clone to w, as an aligned table or
+// a JSON array.
+func printPlan(ctx context.Context, w io.Writer, c *cloner.Cloner, format string) error {
+	switch format {
+	case "table":
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "URL\tPATH\tVERSION\tLASTMOD\tCHANGEFREQ\tPRIORITY\tLANG")
+		for p, err := range c.Plan(ctx) {
+			if err != nil {
+				tw.Flush()
+				return err
+			}
+			e := p.Sitemap
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+				p.URL, p.Path, dash(p.Version), dash(e.LastMod), dash(e.ChangeFreq), dash(e.Priority), dash(e.Lang()))
+		}
+		return tw.Flush()
+
+	case "json":
+		pages := []plannedPage{}
+		for p, err := range c.Plan(ctx) {
+			if err != nil {
+				return err
+			}
+			e := p.Sitemap
+			pages = append(pages, plannedPage{
+				URL:        p.URL,
+				Path:       p.Path,
+				LastMod:    e.LastMod,
+				ChangeFreq: e.ChangeFreq,
+				Priority:   e.Priority,
+				Lang:       e.Lang(),
+				Version:    p.Version,
+			})
+		}
+		enc := json.NewEncoder(w)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "  ")
+		return enc.Encode(pages)
+
+	default:
+		return fmt.Errorf("invalid --dry-run format %q (want table or json)", format)
+	}
+}
+
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
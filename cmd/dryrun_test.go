@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Devon-White/docs-cloner/pkg/cloner"
+)
+
+// sitemapFetcher serves a single sitemap document.
+type sitemapFetcher string
+
+func (s sitemapFetcher) Get(_ context.Context, url string, _ string) (*cloner.Response, error) {
+	if url != "https://example.com/sitemap.xml" {
+		return nil, fmt.Errorf("HTTP 404 for %s", url)
+	}
+	return &cloner.Response{URL: url, StatusCode: 200, Header: http.Header{"Content-Type": {"application/xml"}}, Body: []byte(s)}, nil
+}
+
+const planSitemap = `<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9" xmlns:xhtml="http://www.w3.org/1999/xhtml">
+<url><loc>https://example.com/docs/v2/install</loc><lastmod>2024-05-01</lastmod><priority>0.8</priority>
+  <xhtml:link rel="alternate" hreflang="en" href="https://example.com/docs/v2/install"/></url>
+<url><loc>https://example.com/about</loc></url>
+</urlset>`
+
+func newPlanCloner(t *testing.T) *cloner.Cloner {
+	t.Helper()
+	c, err := cloner.New(cloner.Config{SitemapURL: "https://example.com/sitemap.xml", OutputDir: "unused", Concurrency: 1},
+		cloner.WithFetcher(sitemapFetcher(planSitemap)), cloner.WithLogger(log.New(io.Discard, "", 0)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestPrintPlanTable(t *testing.T) {
+	var out bytes.Buffer
+	if err := printPlan(context.Background(), &out, newPlanCloner(t), "table"); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("got %d lines, want a header and 2 pages:\n%s", len(lines), out.String())
+	}
+	want := [][]string{
+		{"URL", "PATH", "VERSION", "LASTMOD", "CHANGEFREQ", "PRIORITY", "LANG"},
+		{"https://example.com/docs/v2/install", "docs/v2/install.md", "v2", "2024-05-01", "-", "0.8", "en"},
+		{"https://example.com/about", "about.md", "-", "-", "-", "-", "-"},
+	}
+	for i, line := range lines {
+		if got := strings.Fields(line); strings.Join(got, " ") != strings.Join(want[i], " ") {
+			t.Errorf("line %d = %q, want %q", i, got, want[i])
+		}
+	}
+}
+
+func TestPrintPlanJSON(t *testing.T) {
+	var out bytes.Buffer
+	if err := printPlan(context.Background(), &out, newPlanCloner(t), "json"); err != nil {
+		t.Fatal(err)
+	}
+	var pages []plannedPage
+	if err := json.Unmarshal(out.Bytes(), &pages); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, out.String())
+	}
+	want := []plannedPage{
+		{URL: "https://example.com/docs/v2/install", Path: "docs/v2/install.md", LastMod: "2024-05-01", Priority: "0.8", Lang: "en", Version: "v2"},
+		{URL: "https://example.com/about", Path: "about.md"},
+	}
+	if !reflect.DeepEqual(pages, want) {
+		t.Errorf("pages = %+v, want %+v", pages, want)
+	}
+	if strings.Contains(out.String(), `"changefreq"`) {
+		t.Errorf("empty fields should be omitted:\n%s", out.String())
+	}
+}
+
+func TestPrintPlanRejectsUnknownFormat(t *testing.T) {
+	err := printPlan(context.Background(), io.Discard, newPlanCloner(t), "csv")
+	if err == nil || !strings.Contains(err.Error(), "want table or json") {
+		t.Errorf("printPlan = %v", err)
+	}
+}
@@ -2,64 +2,201 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
-	"github.com/Devon-White/docs-cloner/internal/config"
-	"github.com/Devon-White/docs-cloner/internal/pipeline"
+	"github.com/Devon-White/docs-cloner/internal/extractor"
+	"github.com/Devon-White/docs-cloner/internal/fetcher"
+	"github.com/Devon-White/docs-cloner/internal/sitemap"
+	"github.com/Devon-White/docs-cloner/pkg/cloner"
 	"github.com/spf13/cobra"
 )
 
-var cfg config.Config
+var (
+	cfg         cloner.Config
+	since       string
+	dryRun      string
+	maxBodySize string
+)
 
 var rootCmd = &cobra.Command{
 	Use:   "docs-cloner",
 	Short: "Clone documentation sites into AI-friendly markdown",
 	Long: `docs-cloner fetches a documentation site via its XML sitemap and converts
-each page to clean markdown suitable for use with AI systems.
+each page to clean markdown suitable for use with AI systems. Text sitemaps,
+RSS/Atom feeds, plain URL lists and llms.txt files are accepted too, from a
+URL (--url) or a local file or stdin (--urls-from). A local build of a static
+site can be converted without any network access (--from-dir).
 
 It supports two modes:
   - HTML-to-Markdown (default): fetches each page's HTML, extracts the main
     content area, and converts it to clean markdown.
-  - Raw Markdown (--fetch-md): fetches markdown directly from an alternate URL
-    pattern, useful for sites that serve raw .md files.`,
+  - Raw Markdown (--fetch-md): fetches markdown directly from one or more
+    alternate URL patterns, useful for sites that serve raw .md files. Pages
+    without valid markdown fall back to HTML conversion.`,
 	RunE: run,
 }
 
 func init() {
-	rootCmd.Flags().StringVar(&cfg.SitemapURL, "url", "", "sitemap URL (required)")
-	rootCmd.Flags().StringVarP(&cfg.OutputDir, "output", "o", "./output", "output directory")
-	rootCmd.Flags().StringVar(&cfg.FetchMD, "fetch-md", "", "URL pattern for raw markdown (use {url}, {path}, {host} as placeholders; omit value to default to {url}.md)")
+	rootCmd.Flags().StringVar(&cfg.SitemapURL, "url", "", "sitemap, feed, URL list or llms.txt URL (required unless --urls-from or --from-dir is set)")
+	rootCmd.Flags().StringVar(&cfg.URLsFrom, "urls-from", "", "read the sitemap, feed, URL list or llms.txt from a local file (\"-\" for stdin)")
+	rootCmd.Flags().StringVar(&cfg.FromDir, "from-dir", "", "convert a local directory of built .html files instead of fetching (requires --base-url)")
+	rootCmd.Flags().StringVar(&cfg.BaseURL, "base-url", "", "URL the --from-dir directory is published at, used to derive page URLs")
+	rootCmd.Flags().IntVar(&cfg.SitemapMaxDepth, "sitemap-max-depth", sitemap.DefaultMaxDepth, "maximum sitemap index nesting depth")
+	rootCmd.Flags().IntVar(&cfg.SitemapConcurrency, "sitemap-concurrency", 4, "number of sub-sitemaps fetched in parallel")
+	rootCmd.Flags().StringSliceVar(&cfg.SitemapInclude, "sitemap-include", nil, "only fetch sub-sitemaps whose URL contains this substring (repeatable)")
+	rootCmd.Flags().StringSliceVar(&cfg.SitemapExclude, "sitemap-exclude", nil, "skip sub-sitemaps whose URL contains this substring (repeatable)")
+	rootCmd.Flags().StringVarP(&cfg.OutputDir, "output", "o", "./output", "output directory, .tar.gz/.zip archive, or s3://bucket/prefix")
+	rootCmd.Flags().StringVar(&cfg.S3Endpoint, "s3-endpoint", "", "S3-compatible endpoint URL for s3:// output (default: AWS S3)")
+	rootCmd.Flags().StringVar(&cfg.S3Region, "s3-region", "", "region for s3:// output (default: $AWS_REGION or us-east-1)")
+	rootCmd.Flags().BoolVar(&cfg.HostDirs, "host-dirs", false, "prefix output paths with each page's host")
+	rootCmd.Flags().StringArrayVar(&cfg.FetchMD, "fetch-md", nil, "URL pattern for raw markdown, repeatable and tried in order (placeholders: {url}, {scheme}, {host}, {path}, {path_noext}, {dir}, {slug}, {query}; \"auto\" probes common patterns; omit value to default to {url}.md)")
 	rootCmd.Flags().Lookup("fetch-md").NoOptDefVal = "{url}.md"
+	rootCmd.Flags().StringVar(&cfg.FetchMDMatch, "fetch-md-match", "", "regex applied to page URLs; captures are available in --fetch-md patterns as {1} or {name}")
+	rootCmd.Flags().BoolVar(&cfg.AcceptMD, "accept-markdown", false, "request markdown via the Accept header (HTML as fallback) and route each response by its Content-Type")
+	rootCmd.Flags().StringVar(&cfg.WARCOut, "warc-out", "", "record every request and response to a .warc or .warc.gz file")
+	rootCmd.Flags().StringVar(&cfg.WARCIn, "warc-in", "", "replay fetches from a .warc or .warc.gz file instead of the network")
+	rootCmd.Flags().StringVar(&cfg.Record, "record", "", "save every fetched response to a fixture directory")
+	rootCmd.Flags().StringVar(&cfg.Replay, "replay", "", "serve every fetch from a fixture directory written by --record; misses fail")
 	rootCmd.Flags().IntVarP(&cfg.Concurrency, "concurrency", "c", 5, "number of parallel workers")
 	rootCmd.Flags().IntVarP(&cfg.DelayMS, "delay", "d", 200, "delay between requests per worker (ms)")
+	rootCmd.Flags().DurationVar(&cfg.ConnectTimeout, "connect-timeout", fetcher.DefaultConnectTimeout, "timeout for connecting to a server, including the TLS handshake")
+	rootCmd.Flags().DurationVar(&cfg.HeaderTimeout, "header-timeout", fetcher.DefaultHeaderTimeout, "timeout for a server to start responding after a request is sent")
+	rootCmd.Flags().DurationVar(&cfg.Timeout, "timeout", fetcher.DefaultTimeout, "timeout for a whole request, including reading the body")
+	rootCmd.Flags().StringVar(&maxBodySize, "max-body-size", "50MB", "largest response body to download, e.g. 512KB or 20MB; larger pages fail (0 = no limit)")
+	rootCmd.Flags().IntVar(&cfg.MaxPages, "max-pages", 0, "stop after queuing this many pages (0 = no limit)")
+	rootCmd.Flags().DurationVar(&cfg.MaxDuration, "max-duration", 0, "stop starting new pages after the run has taken this long, e.g. 10m (0 = no limit)")
+	rootCmd.Flags().DurationVar(&cfg.GracePeriod, "grace-period", 10*time.Second, "on Ctrl-C or --max-duration, how long pages in flight may take to finish before they are aborted")
 	rootCmd.Flags().BoolVar(&cfg.SingleFile, "single-file", false, "also produce a single concatenated all-pages.md")
+	rootCmd.Flags().StringVar(&cfg.Frontmatter, "frontmatter", "yaml", "frontmatter format: yaml, toml, json or none")
+	rootCmd.Flags().StringSliceVar(&cfg.FrontmatterFields, "frontmatter-fields", nil, "frontmatter fields to write, comma-separated (default: all)")
 	rootCmd.Flags().StringVar(&cfg.Selector, "selector", "", "CSS selector for main content area (default: auto-detect)")
+	rootCmd.Flags().StringVar(&cfg.RenderCmd, "render-cmd", "", "command printing the rendered DOM of {url}, run for client-rendered and thin pages (e.g. \"chromium --headless --dump-dom {url}\")")
+	rootCmd.Flags().StringVar(&cfg.RenderURL, "render-url", "", "HTTP rendering service for client-rendered and thin pages; {url} is replaced with the page URL, otherwise the URL is POSTed as JSON")
+	rootCmd.Flags().StringSliceVar(&cfg.TitleFrom, "title-from", extractor.DefaultTitleFrom, "title sources in priority order: h1, og:title, title")
+	rootCmd.Flags().StringVar(&cfg.TitleStrip, "title-strip", "", "regex removed from every page title (default: strip the site name shared by all titles)")
+	rootCmd.Flags().StringVar(&cfg.HeadingAnchors, "heading-anchors", "html", "keep heading ids as <a id> anchors (html), {#id} attributes (attr), or drop them (none)")
+	rootCmd.Flags().IntVar(&cfg.MinWords, "min-words", 10, "pages with fewer words are thin (0 = no minimum)")
+	rootCmd.Flags().Float64Var(&cfg.MinTextRatio, "min-text-ratio", 0.05, "pages whose extracted text is a smaller share of the page's text are thin (0 = off)")
+	rootCmd.Flags().StringArrayVar(&cfg.Soft404Phrases, "soft-404-phrase", nil, "phrase marking a short page as a soft 404, added to the built-in list (repeatable)")
+	rootCmd.Flags().StringVar(&cfg.OnThin, "on-thin", "warn", "what to do with thin pages: skip, warn or write")
+	rootCmd.Flags().IntVar(&cfg.NearDuplicates, "near-duplicates", 3, "max SimHash distance in bits for near-duplicate pages, up to 3 (0 = exact duplicates only)")
+	rootCmd.Flags().BoolVar(&cfg.KeepDuplicates, "keep-duplicates", false, "write duplicate pages instead of recording them as aliases")
 	rootCmd.Flags().StringSliceVar(&cfg.Include, "include", nil, "only process URLs containing this substring (repeatable)")
 	rootCmd.Flags().StringSliceVar(&cfg.Exclude, "exclude", nil, "skip URLs containing this substring (repeatable)")
+	rootCmd.Flags().StringSliceVar(&cfg.IncludeGlob, "include-glob", nil, "only process URLs whose path matches this glob; ** matches across directories (repeatable)")
+	rootCmd.Flags().StringSliceVar(&cfg.ExcludeGlob, "exclude-glob", nil, "skip URLs whose path matches this glob (repeatable)")
+	rootCmd.Flags().StringArrayVar(&cfg.IncludeRegex, "include-regex", nil, "only process URLs whose path matches this regex (repeatable)")
+	rootCmd.Flags().StringArrayVar(&cfg.ExcludeRegex, "exclude-regex", nil, "skip URLs whose path matches this regex (repeatable)")
+	rootCmd.Flags().StringSliceVar(&cfg.IncludeHost, "include-host", nil, "only process URLs on this host; *.example.com matches subdomains (repeatable)")
+	rootCmd.Flags().StringSliceVar(&cfg.ExcludeHost, "exclude-host", nil, "skip URLs on this host (repeatable)")
+	rootCmd.Flags().StringVar(&cfg.Lang, "lang", "", "only process pages in this locale, per sitemap hreflang alternates (e.g. en)")
+	rootCmd.Flags().StringVar(&cfg.Versions, "versions", "all", "docs versions to clone: all, latest, or a comma-separated list (e.g. 2.3,next)")
+	rootCmd.Flags().BoolVar(&cfg.VersionDirs, "version-dirs", false, "write each docs version into its own output tree")
+	rootCmd.Flags().StringVar(&since, "since", "", "only process pages whose sitemap lastmod is on or after this date (YYYY-MM-DD)")
+	rootCmd.Flags().StringVar(&dryRun, "dry-run", "", "list the URLs that would be cloned with their output paths and sitemap metadata, without fetching pages (table or json; omit value for table)")
+	rootCmd.Flags().Lookup("dry-run").NoOptDefVal = "table"
 	rootCmd.Flags().BoolVar(&cfg.Clean, "clean", false, "remove output directory before writing")
 	rootCmd.Flags().BoolVarP(&cfg.Verbose, "verbose", "v", false, "verbose logging")
 	rootCmd.Flags().StringVar(&cfg.UserAgent, "user-agent", "docs-cloner/1.0", "custom User-Agent string")
 
-	rootCmd.MarkFlagRequired("url")
+	rootCmd.MarkFlagsOneRequired("url", "urls-from", "from-dir")
+	rootCmd.MarkFlagsMutuallyExclusive("url", "urls-from", "from-dir")
+	rootCmd.MarkFlagsRequiredTogether("from-dir", "base-url")
+	rootCmd.MarkFlagsMutuallyExclusive("from-dir", "warc-in", "replay")
+	rootCmd.MarkFlagsMutuallyExclusive("render-cmd", "render-url")
 }
 
 func run(cmd *cobra.Command, args []string) error {
-	if cfg.Concurrency < 1 {
-		return fmt.Errorf("concurrency must be at least 1")
+	if since != "" {
+		t, ok := sitemap.ParseDate(since)
+		if !ok {
+			return fmt.Errorf("invalid --since date %q (want YYYY-MM-DD)", since)
+		}
+		cfg.Since = t
+	}
+
+	size, err := parseSize(maxBodySize)
+	if err != nil {
+		return fmt.Errorf("invalid --max-body-size: %w", err)
 	}
-	if cfg.DelayMS < 0 {
-		return fmt.Errorf("delay must be non-negative")
+	cfg.MaxBodySize = size
+
+	c, err := cloner.New(cfg)
+	if err != nil {
+		return err
 	}
+	cmd.SilenceUsage = true
 
-	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
+	interrupts := make(chan os.Signal, 2)
+	signal.Notify(interrupts, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(interrupts)
+	go func() {
+		if _, ok := <-interrupts; !ok {
+			return
+		}
+		log.Printf("Interrupted; finishing pages in flight for up to %s. Press Ctrl-C again to quit immediately.", cfg.GracePeriod)
+		cancel()
+		if _, ok := <-interrupts; ok {
+			log.Printf("Quitting.")
+			os.Exit(ExitInterrupted)
+		}
+	}()
 
-	return pipeline.Run(ctx, &cfg)
+	if dryRun != "" {
+		return printPlan(ctx, cmd.OutOrStdout(), c, dryRun)
+	}
+	return c.Run(ctx)
+}
+
+// sizeUnits are the suffixes parseSize accepts, longest first.
+var sizeUnits = []struct {
+	suffix string
+	bytes  int64
+}{
+	{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10},
+	{"G", 1 << 30}, {"M", 1 << 20}, {"K", 1 << 10}, {"B", 1},
 }
 
+// parseSize parses a byte count with an optional binary unit suffix, such as
+// "512KB" or "20MB".
+func parseSize(s string) (int64, error) {
+	num, mult := strings.ToUpper(strings.TrimSpace(s)), int64(1)
+	for _, u := range sizeUnits {
+		if strings.HasSuffix(num, u.suffix) {
+			num, mult = strings.TrimSpace(strings.TrimSuffix(num, u.suffix)), u.bytes
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(num, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%q is not a size like 512KB or 20MB", s)
+	}
+	return int64(n * float64(mult)), nil
+}
+
+// ExitInterrupted is the exit status of a run stopped by Ctrl-C, like a
+// shell reports for a process killed by SIGINT.
+const ExitInterrupted = 130
+
 // Execute runs the root command.
 func Execute() error {
 	return rootCmd.Execute()
 }
+
+// ExitCode returns the process exit status for an error from Execute.
+func ExitCode(err error) int {
+	if errors.Is(err, cloner.ErrInterrupted) {
+		return ExitInterrupted
+	}
+	return 1
+}
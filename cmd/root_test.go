@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/Devon-White/docs-cloner/pkg/cloner"
+)
+
+func TestFetchMDPatternsKeepCommas(t *testing.T) {
+	args := []string{"--fetch-md={url}?fields=a,b", "--fetch-md", "--fetch-md={url}/index.md"}
+	if err := rootCmd.Flags().Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"{url}?fields=a,b", "{url}.md", "{url}/index.md"}
+	if !slices.Equal(cfg.FetchMD, want) {
+		t.Errorf("FetchMD = %q, want %q", cfg.FetchMD, want)
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int64
+	}{
+		{"0", 0},
+		{"1024", 1024},
+		{"512B", 512},
+		{"512KB", 512 << 10},
+		{"20mb", 20 << 20},
+		{" 1.5 M ", 3 << 19},
+		{"2G", 2 << 30},
+		{"1GB", 1 << 30},
+	}
+	for _, tt := range tests {
+		got, err := parseSize(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("parseSize(%q) = %d, %v; want %d", tt.in, got, err, tt.want)
+		}
+	}
+
+	for _, bad := range []string{"", "MB", "-1MB", "20 megabytes", "1TB", "ten"} {
+		if _, err := parseSize(bad); err == nil {
+			t.Errorf("parseSize(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{cloner.ErrInterrupted, ExitInterrupted},
+		{errors.Join(cloner.ErrInterrupted, errors.New("closing output: disk full")), ExitInterrupted},
+		{fmt.Errorf("run: %w", cloner.ErrInterrupted), ExitInterrupted},
+		{errors.New("all 3 pages failed"), 1},
+	}
+	for _, tt := range tests {
+		if got := ExitCode(tt.err); got != tt.want {
+			t.Errorf("ExitCode(%v) = %d, want %d", tt.err, got, tt.want)
+		}
+	}
+	if ExitInterrupted != 130 {
+		t.Errorf("ExitInterrupted = %d, want 130 like a shell", ExitInterrupted)
+	}
+}
@@ -1,17 +1,65 @@
 package config
 
+import "time"
+
 // Config holds all CLI options for a docs-cloner run.
 type Config struct {
-	SitemapURL  string
-	OutputDir   string
-	FetchMD     string // URL pattern with {url}/{path}/{host} placeholders; empty = HTML-to-MD mode
-	Concurrency int
-	DelayMS     int
-	SingleFile  bool
-	Selector    string // CSS selector for main content; empty = heuristic
-	Include     []string // URL must contain at least one of these substrings
-	Exclude     []string // URL must not contain any of these substrings
-	Clean       bool
-	Verbose     bool
-	UserAgent   string
+	SitemapURL         string
+	URLsFrom           string   // file path, file:// URL or "-" (stdin) read instead of SitemapURL
+	FromDir            string   // local directory of built HTML pages read instead of SitemapURL
+	BaseURL            string   // URL the FromDir root is served at; page URLs are derived from it
+	SitemapMaxDepth    int      // max sub-sitemap nesting below the root; 0 = default (5)
+	SitemapConcurrency int      // sub-sitemaps fetched in parallel
+	SitemapInclude     []string // sub-sitemap URL must contain one of these substrings
+	SitemapExclude     []string // sub-sitemap URL must not contain any of these substrings
+	OutputDir          string   // directory, .tar.gz/.tgz/.zip archive, or s3://bucket/prefix
+	S3Endpoint         string   // S3-compatible endpoint for s3:// output; empty = AWS
+	S3Region           string
+	HostDirs           bool     // prefix output paths with the page's host
+	FetchMD            []string // URL patterns tried in order, or ["auto"]; empty = HTML-to-MD mode
+	FetchMDMatch       string   // regex applied to page URLs; captures usable as {1}/{name} in FetchMD
+	AcceptMD           bool     // request markdown via the Accept header and route by Content-Type
+	WARCOut            string   // record every fetch to this .warc or .warc.gz file
+	WARCIn             string   // serve every fetch from this WARC file instead of the network
+	Record             string   // save every fetched response to this fixture directory
+	Replay             string   // serve every fetch from this fixture directory; misses fail
+	Concurrency        int
+	DelayMS            int
+	ConnectTimeout     time.Duration // TCP connect and TLS handshake timeout; 0 = 10s
+	HeaderTimeout      time.Duration // time to wait for response headers; 0 = 15s
+	Timeout            time.Duration // whole-request timeout, including the body; 0 = 30s
+	MaxBodySize        int64         // largest response body in bytes; larger downloads fail; 0 = no limit
+	MaxPages           int           // stop queuing pages after this many; 0 = no limit
+	MaxDuration        time.Duration // stop starting pages after the run has taken this long; 0 = no limit
+	GracePeriod        time.Duration // how long pages in flight may take to finish once a run is stopped; 0 = abort them
+	SingleFile         bool
+	Frontmatter        string    // frontmatter format: yaml, toml, json or none; empty = yaml
+	FrontmatterFields  []string  // frontmatter fields to write; empty = all
+	Selector           string    // CSS selector for main content; empty = heuristic
+	RenderCmd          string    // command printing the rendered DOM of {url}, for JavaScript-rendered pages
+	RenderURL          string    // HTTP rendering service for JavaScript-rendered pages
+	TitleFrom          []string  // title sources in priority order (h1, og:title, title); empty = that order
+	TitleStrip         string    // regex removed from every title; empty = strip the shared site name
+	HeadingAnchors     string    // how heading ids are kept: html, attr or none; empty = html
+	MinWords           int       // pages with fewer words are thin; 0 = no minimum
+	MinTextRatio       float64   // pages whose extracted text is a smaller share of the page are thin; 0 = off
+	Soft404Phrases     []string  // phrases marking a short page as a soft 404, on top of the built-in list
+	OnThin             string    // what to do with thin pages: skip, warn or write; empty = warn
+	KeepDuplicates     bool      // write pages that duplicate an earlier page instead of recording them as aliases
+	NearDuplicates     int       // max SimHash distance in bits for near-duplicate pages (up to 3); 0 = exact only
+	Include            []string  // URL must contain at least one of these substrings
+	Exclude            []string  // URL must not contain any of these substrings
+	IncludeGlob        []string  // URL path must match one of these globs (** crosses directories)
+	ExcludeGlob        []string  // URL path must not match any of these globs
+	IncludeRegex       []string  // URL path must match one of these regexes
+	ExcludeRegex       []string  // URL path must not match any of these regexes
+	IncludeHost        []string  // URL host must be one of these (*.example.com for subdomains)
+	ExcludeHost        []string  // URL host must not be any of these
+	Lang               string    // keep only pages in this locale (from sitemap hreflang); empty = all
+	Since              time.Time // keep only pages with a sitemap lastmod on or after this; zero = all
+	Versions           string    // "all", "latest", or a comma-separated list of URL versions to keep
+	VersionDirs        bool      // write each URL version into its own output tree
+	Clean              bool
+	Verbose            bool
+	UserAgent          string
 }
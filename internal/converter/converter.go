@@ -1,7 +1,6 @@
 package converter
 
 import (
-	"context"
 	"fmt"
 	"net/url"
 	"regexp"
@@ -11,8 +10,6 @@ import (
 	"github.com/JohannesKaufmann/html-to-markdown/v2/plugin/base"
 	"github.com/JohannesKaufmann/html-to-markdown/v2/plugin/commonmark"
 	"github.com/JohannesKaufmann/html-to-markdown/v2/plugin/table"
-
-	"github.com/Devon-White/docs-cloner/internal/fetcher"
 )
 
 // removeTags are HTML tags that should be stripped entirely during conversion.
@@ -23,8 +20,11 @@ var removeTags = []string{
 var multiBlankLines = regexp.MustCompile(`\n{3,}`)
 
 // ConvertHTML converts an extracted HTML fragment to markdown.
-// sourceURL is used to resolve relative links to absolute.
-func ConvertHTML(extractedHTML string, sourceURL string) (string, error) {
+// sourceURL is used to resolve relative links to absolute. Heading ids are
+// kept as anchors in the given style (one of the Anchor* constants).
+func ConvertHTML(extractedHTML string, sourceURL string, anchors string) (string, error) {
+	extractedHTML, ids := markHeadingIDs(extractedHTML, anchors)
+
 	conv := converter.NewConverter(
 		converter.WithPlugins(
 			base.NewBasePlugin(),
@@ -37,38 +37,65 @@ func ConvertHTML(extractedHTML string, sourceURL string) (string, error) {
 		conv.Register.TagType(tag, converter.TagTypeRemove, converter.PriorityStandard)
 	}
 
-	md, err := conv.ConvertString(extractedHTML, converter.WithDomain(domainFromURL(sourceURL)))
+	domain := domainFromURL(sourceURL)
+	md, err := conv.ConvertString(extractedHTML, converter.WithDomain(domain))
 	if err != nil {
 		return "", fmt.Errorf("html-to-markdown conversion: %w", err)
 	}
-
-	return CleanMarkdown(md), nil
-}
-
-// FetchRawMD fetches raw markdown from a URL derived from the page URL using
-// the given pattern. Supported placeholders: {url}, {path}, {host}.
-func FetchRawMD(f *fetcher.Fetcher, ctx context.Context, pageURL string, pattern string) (string, error) {
-	mdURL := expandPattern(pattern, pageURL)
-
-	body, err := f.Fetch(ctx, mdURL)
-	if err != nil {
-		return "", fmt.Errorf("fetching raw markdown from %s: %w", mdURL, err)
+	if domain != "" {
+		// Same-page fragment links come back prefixed with the domain
+		md = strings.ReplaceAll(md, "]("+domain+"#", "](#")
 	}
 
-	return CleanMarkdown(string(body)), nil
+	return CleanMarkdown(insertAnchors(md, ids, anchors)), nil
 }
 
 // ExtractTitleFromMarkdown extracts the first level-1 heading from markdown.
+// Heading anchors are left out.
 func ExtractTitleFromMarkdown(md string) string {
 	for _, line := range strings.Split(md, "\n") {
-		line = strings.TrimSpace(line)
-		if strings.HasPrefix(line, "# ") {
-			return strings.TrimPrefix(line, "# ")
+		if level, text := headingLevel(strings.TrimSpace(line)); level == 1 {
+			return text
 		}
 	}
 	return ""
 }
 
+// DropRepeatedTitle removes headings that repeat title: any heading before
+// the first paragraph after the first one that matches, and any later H1
+// matching it. Themes often render the page title both in a header and at
+// the top of the article, which would otherwise leave it in the body twice.
+func DropRepeatedTitle(md, title string) string {
+	title = normalizeHeading(title)
+	if title == "" {
+		return md
+	}
+
+	lines := strings.Split(md, "\n")
+	out := lines[:0]
+	seen, leading, fenced := false, true, false
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			fenced = !fenced
+		}
+		level, text := headingLevel(trimmed)
+		switch {
+		case fenced || level == 0:
+			if trimmed != "" {
+				leading = false
+			}
+		case normalizeHeading(text) == title:
+			if seen && (leading || level == 1) {
+				continue
+			}
+			seen = true
+		}
+		out = append(out, line)
+	}
+	return CleanMarkdown(strings.Join(out, "\n"))
+}
+
 // CleanMarkdown normalizes whitespace in markdown output.
 func CleanMarkdown(md string) string {
 	// Collapse 3+ blank lines to 2
@@ -94,13 +121,3 @@ func domainFromURL(rawURL string) string {
 	}
 	return u.Scheme + "://" + u.Host
 }
-
-func expandPattern(pattern, pageURL string) string {
-	u, _ := url.Parse(pageURL)
-	r := strings.NewReplacer(
-		"{url}", pageURL,
-		"{path}", u.Path,
-		"{host}", u.Host,
-	)
-	return r.Replace(pattern)
-}
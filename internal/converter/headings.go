@@ -0,0 +1,230 @@
+package converter
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Heading anchor styles for ConvertHTML.
+const (
+	AnchorHTML = "html" // <a id="id"></a> at the start of the heading
+	AnchorAttr = "attr" // {#id} after the heading text (Pandoc, Hugo, kramdown)
+	AnchorNone = "none" // drop heading ids
+)
+
+// ValidateAnchors checks a heading anchor style.
+func ValidateAnchors(style string) error {
+	switch style {
+	case "", AnchorHTML, AnchorAttr, AnchorNone:
+		return nil
+	}
+	return fmt.Errorf("unknown heading anchor style %q (want html, attr or none)", style)
+}
+
+// anchorToken marks where a heading id goes. It is plain lowercase text so
+// it passes through HTML-to-markdown conversion unescaped.
+var anchorToken = regexp.MustCompile(`\s*dcanchor(\d+)z`)
+
+// attrID matches ids that the {#id} syntax can express.
+var attrID = regexp.MustCompile(`^[A-Za-z][\w:.-]*$`)
+
+// Anchors in heading lines, as written by insertAnchors.
+var (
+	leadingAnchor  = regexp.MustCompile(`^<a id="[^"]*"></a>\s*`)
+	trailingAnchor = regexp.MustCompile(`\s*\{#[^}\s]+\}$`)
+)
+
+// markHeadingIDs appends a token to every heading in fragment that has an id
+// (on the heading itself or an anchor inside it) and returns the ids in
+// token order. Permalink anchors pointing at the heading ("#", "¶") are
+// removed, since the id is kept explicitly.
+func markHeadingIDs(fragment, style string) (string, []string) {
+	if style == AnchorNone || (!strings.Contains(fragment, "id=") && !strings.Contains(fragment, "name=")) {
+		return fragment, nil
+	}
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(fragment))
+	if err != nil {
+		return fragment, nil
+	}
+
+	var ids []string
+	doc.Find("h1, h2, h3, h4, h5, h6").Each(func(_ int, h *goquery.Selection) {
+		id := strings.TrimSpace(h.AttrOr("id", ""))
+		if id == "" {
+			inner := h.Find("[id], a[name]").First()
+			id = strings.TrimSpace(inner.AttrOr("id", inner.AttrOr("name", "")))
+			if goquery.NodeName(inner) == "a" && strings.TrimSpace(inner.Text()) == "" {
+				inner.Remove()
+			}
+		}
+		if id == "" {
+			return
+		}
+		h.Find("a").Each(func(_ int, a *goquery.Selection) {
+			if a.AttrOr("href", "") == "#"+id && isPermalinkText(a.Text()) {
+				a.Remove()
+			}
+		})
+		h.AppendHtml(" dcanchor" + strconv.Itoa(len(ids)) + "z")
+		ids = append(ids, id)
+	})
+	if ids == nil {
+		return fragment, nil
+	}
+
+	out, err := doc.Find("body").Html()
+	if err != nil {
+		return fragment, nil
+	}
+	return out, ids
+}
+
+// isPermalinkText reports whether an anchor's text is just a permalink
+// symbol, such as "#", "¶", "§" or a zero-width space.
+func isPermalinkText(s string) bool {
+	for _, r := range s {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return len([]rune(strings.TrimSpace(s))) <= 3
+}
+
+// insertAnchors replaces the tokens left by markHeadingIDs with anchors in
+// the given style.
+func insertAnchors(md string, ids []string, style string) string {
+	if ids == nil {
+		return md
+	}
+	lines := strings.Split(md, "\n")
+	for i, line := range lines {
+		m := anchorToken.FindStringSubmatchIndex(line)
+		if m == nil {
+			continue
+		}
+		n, _ := strconv.Atoi(line[m[2]:m[3]])
+		if n >= len(ids) {
+			continue
+		}
+		id := ids[n]
+		line = line[:m[0]] + line[m[1]:]
+
+		level, _ := headingLevel(strings.TrimSpace(line))
+		switch {
+		case style == AnchorAttr && attrID.MatchString(id):
+			line += " {#" + id + "}"
+		case level > 0:
+			indent := len(line) - len(strings.TrimLeft(line, " "))
+			marker := indent + level + 1
+			if marker > len(line) {
+				marker = len(line)
+			}
+			line = line[:marker] + `<a id="` + html.EscapeString(id) + `"></a>` + line[marker:]
+		default:
+			line = `<a id="` + html.EscapeString(id) + `"></a>` + line
+		}
+		lines[i] = line
+	}
+	return strings.Join(lines, "\n")
+}
+
+// headingLevel returns the level and text of an ATX heading line, or 0.
+// Anchors written by insertAnchors are not part of the text.
+func headingLevel(line string) (int, string) {
+	level := 0
+	for level < len(line) && line[level] == '#' {
+		level++
+	}
+	if level == 0 || level > 6 || (level < len(line) && line[level] != ' ') {
+		return 0, ""
+	}
+	text := strings.TrimSpace(line[level:])
+	text = leadingAnchor.ReplaceAllString(text, "")
+	text = trailingAnchor.ReplaceAllString(text, "")
+	return level, text
+}
+
+func normalizeHeading(s string) string {
+	return strings.ToLower(strings.Join(strings.Fields(s), " "))
+}
+
+// NormalizeHeadings shifts heading levels so the page has exactly one H1,
+// its first heading, and no skipped levels. A page without an H1 whose first
+// heading isn't the title gets "# title" prepended, so its sections keep
+// their relative levels below it.
+func NormalizeHeadings(md, title string) string {
+	type heading struct{ line, level int }
+	lines := strings.Split(md, "\n")
+	var headings []heading
+	hasH1, fenced := false, false
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			fenced = !fenced
+			continue
+		}
+		if fenced {
+			continue
+		}
+		if level, _ := headingLevel(trimmed); level > 0 {
+			headings = append(headings, heading{i, level})
+			hasH1 = hasH1 || level == 1
+		}
+	}
+
+	if !hasH1 && title != "" {
+		first := ""
+		if len(headings) > 0 {
+			_, first = headingLevel(strings.TrimSpace(lines[headings[0].line]))
+		}
+		if normalizeHeading(first) != normalizeHeading(title) {
+			lines = append([]string{"# " + title, ""}, lines...)
+			for i := range headings {
+				headings[i].line += 2
+			}
+			headings = append([]heading{{0, 1}}, headings...)
+		}
+	}
+	if len(headings) == 0 {
+		return md
+	}
+
+	// Shift so the shallowest heading is level 1. If the first heading isn't
+	// the only H1, it becomes the H1 and every other heading moves down one.
+	base := 6
+	for _, h := range headings {
+		base = min(base, h.level)
+	}
+	levels := make([]int, len(headings))
+	h1s := 0
+	for i, h := range headings {
+		levels[i] = h.level - base + 1
+		if levels[i] == 1 {
+			h1s++
+		}
+	}
+	if levels[0] != 1 || h1s > 1 {
+		for i := 1; i < len(levels); i++ {
+			levels[i]++
+		}
+		levels[0] = 1
+	}
+
+	prev := 0
+	for i, h := range headings {
+		level := min(levels[i], prev+1, 6)
+		prev = level
+		if level == h.level {
+			continue
+		}
+		trimmed := strings.TrimSpace(lines[h.line])
+		lines[h.line] = strings.Repeat("#", level) + trimmed[h.level:]
+	}
+	return strings.Join(lines, "\n")
+}
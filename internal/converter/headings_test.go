@@ -0,0 +1,174 @@
+package converter
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestConvertHTMLHeadingAnchors(t *testing.T) {
+	const page = `<h1 id="intro">Intro<a class="headerlink" href="#intro">¶</a></h1>` +
+		`<p>Text <a href="#setup">see</a>.</p>` +
+		`<h2><a name="setup"></a>Set up</h2>` +
+		`<h3 id="1 odd">Odd</h3>` +
+		`<h2 id="x">Link <a href="#x">#</a> <a href="/y">kept</a></h2>`
+
+	tests := []struct {
+		style string
+		want  string
+	}{
+		{AnchorHTML, `# <a id="intro"></a>Intro
+
+Text [see](#setup).
+
+## <a id="setup"></a>Set up
+
+### <a id="1 odd"></a>Odd
+
+## <a id="x"></a>Link [kept](https://example.com/y)`},
+		// Ids that {#id} can't express fall back to an HTML anchor
+		{AnchorAttr, `# Intro {#intro}
+
+Text [see](#setup).
+
+## Set up {#setup}
+
+### <a id="1 odd"></a>Odd
+
+## Link [kept](https://example.com/y) {#x}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.style, func(t *testing.T) {
+			md, err := ConvertHTML(page, "https://example.com/docs/page", tt.style)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if md != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", md, tt.want)
+			}
+		})
+	}
+
+	md, err := ConvertHTML(page, "https://example.com/docs/page", AnchorNone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(md, "<a id=") || strings.Contains(md, "{#") {
+		t.Errorf("AnchorNone kept ids:\n%s", md)
+	}
+}
+
+func TestValidateAnchors(t *testing.T) {
+	for _, s := range []string{"", AnchorHTML, AnchorAttr, AnchorNone} {
+		if err := ValidateAnchors(s); err != nil {
+			t.Errorf("ValidateAnchors(%q) = %v", s, err)
+		}
+	}
+	if err := ValidateAnchors("pandoc"); err == nil {
+		t.Error("ValidateAnchors(pandoc) succeeded")
+	}
+}
+
+func TestHeadingLevel(t *testing.T) {
+	tests := []struct {
+		line  string
+		level int
+		text  string
+	}{
+		{"# Title", 1, "Title"},
+		{"### <a id=\"x\"></a>Deep", 3, "Deep"},
+		{"## Attr {#x}", 2, "Attr"},
+		{"#hashtag", 0, ""},
+		{"####### seven", 0, ""},
+		{"plain", 0, ""},
+	}
+	for _, tt := range tests {
+		level, text := headingLevel(tt.line)
+		if level != tt.level || text != tt.text {
+			t.Errorf("headingLevel(%q) = %d, %q; want %d, %q", tt.line, level, text, tt.level, tt.text)
+		}
+	}
+}
+
+func TestNormalizeHeadings(t *testing.T) {
+	tests := []struct {
+		name, md, title, want string
+	}{
+		{
+			name:  "already normal",
+			md:    "# Title\n\n## A\n\n### B",
+			title: "Title",
+			want:  "# Title\n\n## A\n\n### B",
+		},
+		{
+			name:  "shifted up to one H1",
+			md:    "## Title\n\n### A\n\n#### B",
+			title: "Title",
+			want:  "# Title\n\n## A\n\n### B",
+		},
+		{
+			name:  "extra H1s move down",
+			md:    "# Title\n\n# A\n\n## B",
+			title: "Title",
+			want:  "# Title\n\n## A\n\n### B",
+		},
+		{
+			name:  "skipped levels closed",
+			md:    "# Title\n\n#### A\n\n## B",
+			title: "Title",
+			want:  "# Title\n\n## A\n\n## B",
+		},
+		{
+			name:  "title prepended",
+			md:    "## A\n\ntext\n\n### B",
+			title: "Title",
+			want:  "# Title\n\n## A\n\ntext\n\n### B",
+		},
+		{
+			name:  "matching first heading promoted instead",
+			md:    "## <a id=\"t\"></a>title\n\n### A",
+			title: "Title",
+			want:  "# <a id=\"t\"></a>title\n\n## A",
+		},
+		{
+			name:  "fenced code untouched",
+			md:    "# Title\n\n```\n# comment\n```\n\n### A",
+			title: "Title",
+			want:  "# Title\n\n```\n# comment\n```\n\n## A",
+		},
+		{
+			name:  "no headings or title",
+			md:    "just text",
+			title: "",
+			want:  "just text",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NormalizeHeadings(tt.md, tt.title); got != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDropRepeatedTitle(t *testing.T) {
+	tests := []struct {
+		name, md, want string
+	}{
+		{"header and article", "# Install\n\n# Install\n\nText", "# Install\n\nText"},
+		{"leading lower heading", "# Install\n\n## install\n\nText", "# Install\n\nText"},
+		{"later section kept", "# Install\n\nText\n\n## Install\n\nMore", "# Install\n\nText\n\n## Install\n\nMore"},
+		{"later H1 dropped", "# Install\n\nText\n\n# Install\n\nMore", "# Install\n\nText\n\nMore"},
+		{"code kept", "# Install\n\n```\n# Install\n```", "# Install\n\n```\n# Install\n```"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DropRepeatedTitle(tt.md, "Install"); got != tt.want {
+				t.Errorf("got:\n%q\nwant:\n%q", got, tt.want)
+			}
+		})
+	}
+	if got := ExtractTitleFromMarkdown("text\n\n## Sub\n\n# <a id=\"i\"></a>Install {#i}"); got != "Install" {
+		t.Errorf("ExtractTitleFromMarkdown = %q", got)
+	}
+}
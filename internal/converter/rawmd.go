@@ -0,0 +1,264 @@
+package converter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/Devon-White/docs-cloner/internal/fetcher"
+)
+
+// AutoPattern is the --fetch-md value that asks for the raw markdown pattern
+// to be detected by probing sample pages.
+const AutoPattern = "auto"
+
+// ErrNotMarkdown is returned by FetchRawMD when the server answered with
+// something other than markdown, such as an HTML error page served with 200.
+var ErrNotMarkdown = errors.New("response is not markdown")
+
+// htmlContentTypes are Content-Type values that disqualify a raw markdown
+// response outright.
+var htmlContentTypes = []string{
+	"text/html",
+	"application/xhtml+xml",
+}
+
+// htmlSniffPrefixes are lowercase prefixes that mark a body as HTML even when
+// the server labels it text/plain or omits the Content-Type.
+var htmlSniffPrefixes = []string{
+	"<!doctype html",
+	"<html",
+	"<head",
+	"<body",
+}
+
+// AcceptMarkdown is the Accept header used to request markdown through
+// content negotiation, with HTML as a fallback.
+const AcceptMarkdown = "text/markdown, text/x-markdown;q=0.95, text/html;q=0.8, */*;q=0.1"
+
+// markdownContentTypes are Content-Type values that identify a markdown body.
+var markdownContentTypes = []string{
+	"text/markdown",
+	"text/x-markdown",
+}
+
+// autoCandidates are the patterns probed, in order, by --fetch-md auto.
+var autoCandidates = []string{
+	"{url}.md",
+	"{scheme}://{host}{path_noext}.md",
+	"{scheme}://{host}{path_noext}/index.md",
+	"{url}?format=md",
+	"{url}?plain=1",
+	"{scheme}://{host}{path_noext}.mdx",
+}
+
+// placeholderPattern matches {name} placeholders, including numbered and
+// named regex captures such as {1} or {slug}.
+var placeholderPattern = regexp.MustCompile(`\{([A-Za-z0-9_]+)\}`)
+
+// PatternSet is an ordered list of raw markdown URL patterns, optionally
+// paired with a regex whose captures are available as placeholders.
+type PatternSet struct {
+	Patterns []string
+	Match    *regexp.Regexp // applied to the page URL; nil = no captures
+}
+
+// Expand builds the raw markdown URL for pageURL from pattern. It reports
+// false if the pattern references a capture and Match did not match.
+//
+// Supported placeholders:
+//
+//	{url}        full page URL
+//	{scheme}     URL scheme
+//	{host}       URL host
+//	{path}       URL path
+//	{path_noext} path without trailing slash or .html/.htm/.php extension
+//	{dir}        path_noext without its last segment
+//	{slug}       last segment of path_noext
+//	{query}      raw query string
+//	{1}, {name}  numbered or named captures from Match
+func (ps *PatternSet) Expand(pattern, pageURL string) (string, bool) {
+	u, err := url.Parse(pageURL)
+	if err != nil {
+		return "", false
+	}
+
+	noext := strings.TrimSuffix(u.Path, "/")
+	for _, ext := range []string{".html", ".htm", ".php"} {
+		noext = strings.TrimSuffix(noext, ext)
+	}
+	dir := path.Dir(noext)
+	if dir == "/" || dir == "." {
+		dir = ""
+	}
+
+	values := map[string]string{
+		"url":        pageURL,
+		"scheme":     u.Scheme,
+		"host":       u.Host,
+		"path":       u.Path,
+		"path_noext": noext,
+		"dir":        dir,
+		"slug":       path.Base("/" + noext),
+		"query":      u.RawQuery,
+	}
+	if values["slug"] == "/" {
+		values["slug"] = ""
+	}
+
+	var captures map[string]string
+	if ps.Match != nil {
+		if m := ps.Match.FindStringSubmatch(pageURL); m != nil {
+			captures = make(map[string]string)
+			for i, name := range ps.Match.SubexpNames() {
+				captures[fmt.Sprint(i)] = m[i]
+				if name != "" {
+					captures[name] = m[i]
+				}
+			}
+		}
+	}
+
+	ok := true
+	out := placeholderPattern.ReplaceAllStringFunc(pattern, func(ph string) string {
+		name := ph[1 : len(ph)-1]
+		if v, found := captures[name]; found {
+			return v
+		}
+		if v, found := values[name]; found {
+			return v
+		}
+		if ps.Match != nil {
+			// An unknown placeholder refers to a capture that did not match.
+			ok = false
+		}
+		return ph
+	})
+	return out, ok
+}
+
+// FetchRawMD fetches raw markdown for a page, trying each pattern in order and
+// returning the first response that validates as markdown. Responses that turn
+// out to be HTML are rejected with ErrNotMarkdown.
+func FetchRawMD(f fetcher.Getter, ctx context.Context, pageURL string, ps *PatternSet) (string, error) {
+	var errs []error
+	for _, pattern := range ps.Patterns {
+		mdURL, ok := ps.Expand(pattern, pageURL)
+		if !ok {
+			continue
+		}
+
+		md, err := fetchOne(f, ctx, mdURL)
+		if err == nil {
+			return md, nil
+		}
+		if ctx.Err() != nil {
+			return "", err
+		}
+		errs = append(errs, err)
+	}
+
+	if len(errs) == 0 {
+		return "", fmt.Errorf("no --fetch-md pattern applies to %s", pageURL)
+	}
+	return "", errors.Join(errs...)
+}
+
+// DetectPattern probes sampleURLs with the built-in candidate patterns and
+// returns the first pattern that yields valid markdown for any sample. Pages
+// where the chosen pattern fails still fall back to HTML, so one success is
+// enough. It returns an empty string if no candidate works.
+func DetectPattern(f fetcher.Getter, ctx context.Context, sampleURLs []string, match *regexp.Regexp) string {
+	ps := &PatternSet{Match: match}
+	for _, pattern := range autoCandidates {
+		for _, pageURL := range sampleURLs {
+			mdURL, ok := ps.Expand(pattern, pageURL)
+			if !ok {
+				continue
+			}
+			if _, err := fetchOne(f, ctx, mdURL); err == nil {
+				return pattern
+			}
+			if ctx.Err() != nil {
+				return ""
+			}
+		}
+	}
+	return ""
+}
+
+// fetchOne fetches a single raw markdown URL and validates the response.
+func fetchOne(f fetcher.Getter, ctx context.Context, mdURL string) (string, error) {
+	resp, err := f.Get(ctx, mdURL, AcceptMarkdown)
+	if err != nil {
+		return "", fmt.Errorf("fetching raw markdown from %s: %w", mdURL, err)
+	}
+
+	if err := validateMarkdown(resp); err != nil {
+		return "", fmt.Errorf("%s: %w", mdURL, err)
+	}
+
+	return CleanMarkdown(string(resp.Body)), nil
+}
+
+// IsMarkdownResponse reports whether the response declares a markdown
+// Content-Type.
+func IsMarkdownResponse(resp *fetcher.Response) bool {
+	ct := resp.ContentType()
+	for _, mdType := range markdownContentTypes {
+		if ct == mdType {
+			return true
+		}
+	}
+	return false
+}
+
+// IsMarkdownDocument reports whether resp is a markdown file: either served
+// with a markdown Content-Type, or requested by a .md/.mdx URL and not HTML.
+// It recognizes pages linked directly as markdown, e.g. from llms.txt.
+func IsMarkdownDocument(resp *fetcher.Response) bool {
+	if IsMarkdownResponse(resp) {
+		return true
+	}
+	u, err := url.Parse(resp.URL)
+	if err != nil {
+		return false
+	}
+	ext := strings.ToLower(path.Ext(u.Path))
+	if ext != ".md" && ext != ".mdx" {
+		return false
+	}
+	return validateMarkdown(resp) == nil
+}
+
+// validateMarkdown checks the Content-Type and sniffs the body to make sure a
+// raw markdown response is not actually an HTML page.
+func validateMarkdown(resp *fetcher.Response) error {
+	ct := resp.ContentType()
+	for _, htmlType := range htmlContentTypes {
+		if ct == htmlType {
+			return fmt.Errorf("%w (Content-Type %s)", ErrNotMarkdown, ct)
+		}
+	}
+
+	head := resp.Body
+	if len(head) > 512 {
+		head = head[:512]
+	}
+	head = bytes.ToLower(bytes.TrimSpace(bytes.TrimPrefix(head, []byte("\xef\xbb\xbf"))))
+	for _, prefix := range htmlSniffPrefixes {
+		if bytes.HasPrefix(head, []byte(prefix)) {
+			return fmt.Errorf("%w (body looks like HTML)", ErrNotMarkdown)
+		}
+	}
+
+	if len(bytes.TrimSpace(resp.Body)) == 0 {
+		return fmt.Errorf("%w (empty body)", ErrNotMarkdown)
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package converter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/Devon-White/docs-cloner/internal/fetcher"
+)
+
+// fakeGetter serves canned responses by URL; other URLs get a 404 error.
+type fakeGetter map[string]*fetcher.Response
+
+func (f fakeGetter) Get(_ context.Context, url string, _ string) (*fetcher.Response, error) {
+	resp, ok := f[url]
+	if !ok {
+		return nil, fmt.Errorf("HTTP 404 for %s", url)
+	}
+	resp.URL = url
+	if resp.Header == nil {
+		resp.Header = http.Header{}
+	}
+	return resp, nil
+}
+
+func response(contentType, body string) *fetcher.Response {
+	return &fetcher.Response{StatusCode: 200, Header: http.Header{"Content-Type": {contentType}}, Body: []byte(body)}
+}
+
+func TestFetchRawMDValidatesResponses(t *testing.T) {
+	const page = "https://example.com/docs/install"
+	tests := []struct {
+		name    string
+		resp    *fetcher.Response
+		wantErr error
+	}{
+		{"markdown", response("text/markdown", "# Install\n\nRun it."), nil},
+		{"plain text markdown", response("text/plain; charset=utf-8", "# Install\n"), nil},
+		{"html content type", response("text/html", "# Install\n"), ErrNotMarkdown},
+		{"html body labeled text", response("text/plain", "\ufeff  <!DOCTYPE html><html></html>"), ErrNotMarkdown},
+		{"html fragment", response("", "<body>Not found</body>"), ErrNotMarkdown},
+		{"empty body", response("text/markdown", " \n"), ErrNotMarkdown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := fakeGetter{page + ".md": tt.resp}
+			md, err := FetchRawMD(f, context.Background(), page, &PatternSet{Patterns: []string{"{url}.md"}})
+			if tt.wantErr == nil {
+				if err != nil || !strings.HasPrefix(md, "# Install") {
+					t.Fatalf("FetchRawMD = %q, %v", md, err)
+				}
+				return
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("FetchRawMD error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFetchRawMDMissingPage(t *testing.T) {
+	_, err := FetchRawMD(fakeGetter{}, context.Background(), "https://example.com/a", &PatternSet{Patterns: []string{"{url}.md"}})
+	if err == nil || !strings.Contains(err.Error(), "HTTP 404") {
+		t.Fatalf("FetchRawMD error = %v, want the 404", err)
+	}
+}
+
+func TestPatternSetExpand(t *testing.T) {
+	const page = "https://example.com/docs/guide/install.html?v=2"
+	tests := []struct {
+		pattern string
+		match   string
+		want    string
+		ok      bool
+	}{
+		{"{url}.md", "", page + ".md", true},
+		{"{scheme}://{host}{path_noext}.md", "", "https://example.com/docs/guide/install.md", true},
+		{"https://raw.example.com{dir}/{slug}.md?{query}", "", "https://raw.example.com/docs/guide/install.md?v=2", true},
+		{"{url}?fields=a,b", "", page + "?fields=a,b", true},
+		{"https://raw.example.com/{1}/{page}.md", `/docs/(\w+)/(?P<page>\w+)`, "https://raw.example.com/guide/install.md", true},
+		{"https://raw.example.com/{page}.md", `/blog/(?P<page>\w+)`, "", false},
+	}
+	for _, tt := range tests {
+		ps := &PatternSet{}
+		if tt.match != "" {
+			ps.Match = regexp.MustCompile(tt.match)
+		}
+		got, ok := ps.Expand(tt.pattern, page)
+		if ok != tt.ok || (ok && got != tt.want) {
+			t.Errorf("Expand(%q) = %q, %v; want %q, %v", tt.pattern, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestFetchRawMDTriesPatternsInOrder(t *testing.T) {
+	const page = "https://example.com/docs/a/"
+	f := fakeGetter{
+		"https://example.com/docs/a/index.md": response("text/markdown", "# From index.md"),
+		"https://example.com/docs/a.md":       response("text/markdown", "# From a.md"),
+	}
+	ps := &PatternSet{Patterns: []string{"{url}.md", "{url}index.md", "{scheme}://{host}{path_noext}.md"}}
+	md, err := FetchRawMD(f, context.Background(), page, ps)
+	if err != nil || md != "# From index.md" {
+		t.Fatalf("FetchRawMD = %q, %v; want the second pattern's markdown", md, err)
+	}
+}
+
+func TestDetectPattern(t *testing.T) {
+	samples := []string{"https://example.com/docs/a.html", "https://example.com/docs/b.html"}
+	f := fakeGetter{
+		// The first candidate serves HTML, so it must not be picked.
+		"https://example.com/docs/a.html.md": response("text/html", "<html></html>"),
+		"https://example.com/docs/b.md":      response("text/plain", "# B"),
+	}
+	if got := DetectPattern(f, context.Background(), samples, nil); got != "{scheme}://{host}{path_noext}.md" {
+		t.Errorf("DetectPattern = %q", got)
+	}
+	if got := DetectPattern(fakeGetter{}, context.Background(), samples, nil); got != "" {
+		t.Errorf("DetectPattern with no markdown = %q, want none", got)
+	}
+}
+
+func TestIsMarkdownDocument(t *testing.T) {
+	tests := []struct {
+		url         string
+		contentType string
+		body        string
+		want        bool
+	}{
+		{"https://example.com/a", "text/markdown", "# A", true},
+		{"https://example.com/a", "text/x-markdown; charset=utf-8", "# A", true},
+		{"https://example.com/a", "text/plain", "# A", false},
+		{"https://example.com/a.md", "text/plain", "# A", true},
+		{"https://example.com/a.MDX", "application/octet-stream", "# A", true},
+		{"https://example.com/a.md", "text/html", "<html></html>", false},
+		{"https://example.com/a.md", "text/plain", "<!DOCTYPE html><p>soft 404</p>", false},
+	}
+	for _, tt := range tests {
+		resp := response(tt.contentType, tt.body)
+		resp.URL = tt.url
+		if got := IsMarkdownDocument(resp); got != tt.want {
+			t.Errorf("IsMarkdownDocument(%s, %s) = %v, want %v", tt.url, tt.contentType, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,223 @@
+// Package dedupe finds pages that are copies of pages seen earlier in a run:
+// pages declaring the same canonical URL, pages with identical content, and
+// near-duplicates whose SimHash fingerprints differ in only a few bits.
+package dedupe
+
+import (
+	"crypto/sha256"
+	"hash/fnv"
+	"math/bits"
+	"net/url"
+	"strings"
+)
+
+// Reasons a page is a duplicate.
+const (
+	Canonical = "same canonical URL"
+	Exact     = "identical content"
+	Near      = "near-duplicate content"
+)
+
+// MaxDistance is the largest SimHash distance the index can search for: the
+// fingerprint is split into MaxDistance+1 bands, so any fingerprint within
+// that distance shares at least one band exactly.
+const MaxDistance = 3
+
+// nearMinWords is the length below which pages aren't compared by SimHash;
+// fingerprints of a handful of words are too coarse.
+const nearMinWords = 30
+
+// shingleSize is the number of words per SimHash feature.
+const shingleSize = 3
+
+// canonicalMaxDistance is the SimHash distance above which two pages sharing
+// a canonical URL are too different to be copies. Sites sometimes point every
+// page's canonical link at the home page or at a translation.
+const canonicalMaxDistance = 16
+
+// Page is what the index looks at.
+type Page struct {
+	URL       string
+	Canonical string // <link rel="canonical">, or ""
+	Group     string // pages are only compared within a group, such as a docs version
+	Markdown  string
+}
+
+// Index remembers the pages of one run. It is not safe for concurrent use.
+type Index struct {
+	distance  int
+	exactOnly bool
+	canonical map[string]claim             // group + URL key -> page holding it
+	exact     map[[sha256.Size]byte]string // group + body hash -> first URL
+	bands     map[band][]entry
+	hashes    map[string]uint64 // first URL -> SimHash, for pages long enough to have one
+}
+
+// claim is the page holding a URL key. alias is set when the key is the
+// page's canonical URL rather than its own, so the page at that URL can take
+// the key over.
+type claim struct {
+	url   string
+	alias bool
+}
+
+type band struct {
+	group string
+	n     int
+	value uint16
+}
+
+type entry struct {
+	hash uint64
+	url  string
+}
+
+// New returns an index that treats pages within distance bits of each other
+// as near-duplicates. distance is capped at MaxDistance; 0 disables
+// near-duplicate detection.
+func New(distance int) *Index {
+	return &Index{
+		distance:  min(distance, MaxDistance),
+		canonical: make(map[string]claim),
+		exact:     make(map[[sha256.Size]byte]string),
+		bands:     make(map[band][]entry),
+		hashes:    make(map[string]uint64),
+	}
+}
+
+// NewExact returns an index that only finds pages with identical content,
+// for runs that keep duplicates but still report the exact copies.
+func NewExact() *Index {
+	x := New(0)
+	x.exactOnly = true
+	return x
+}
+
+// Add records p and returns the URL of an earlier page it duplicates and
+// the reason, or "" if it is the first of its kind. Duplicates are not
+// recorded, so every later copy points at the first page. A page at the
+// canonical URL of pages seen earlier is never their duplicate: it takes
+// their place, and later copies point at it instead.
+func (x *Index) Add(p Page) (dupOf, reason string) {
+	own := Key(p.URL)
+	key := own
+	if p.Canonical != "" && !x.exactOnly {
+		key = Key(p.Canonical)
+	}
+	body := strings.TrimSpace(p.Markdown)
+	words := strings.Fields(strings.ToLower(body))
+	var hash uint64
+	long := len(words) >= nearMinWords
+	if long {
+		hash = SimHash(words)
+	}
+
+	// alias is the earlier page that named p as its canonical URL, if any
+	var alias string
+	if c, ok := x.canonical[p.Group+"\x00"+own]; ok && c.alias {
+		alias = c.url
+	}
+	for _, k := range []string{key, own} {
+		c, ok := x.canonical[p.Group+"\x00"+k]
+		if x.exactOnly || !ok || c.url == alias {
+			continue
+		}
+		if h, ok := x.hashes[c.url]; ok && long && bits.OnesCount64(h^hash) > canonicalMaxDistance {
+			continue
+		}
+		return c.url, Canonical
+	}
+
+	sum := sha256.Sum256([]byte(p.Group + "\x00" + body))
+	if first, ok := x.exact[sum]; ok && body != "" && first != alias {
+		return first, Exact
+	}
+
+	near := x.distance > 0 && long
+	if near {
+		if first := x.nearest(p.Group, hash); first != "" && first != alias {
+			return first, Near
+		}
+	}
+
+	for _, k := range []string{key, own} {
+		if c, ok := x.canonical[p.Group+"\x00"+k]; !ok || k == own && c.alias {
+			x.canonical[p.Group+"\x00"+k] = claim{p.URL, k != own}
+		}
+	}
+	if first, ok := x.exact[sum]; body != "" && (!ok || first == alias) {
+		x.exact[sum] = p.URL
+	}
+	if long {
+		x.hashes[p.URL] = hash
+	}
+	if near {
+		for i := range MaxDistance + 1 {
+			b := band{p.Group, i, uint16(hash >> (16 * i))}
+			x.bands[b] = append(x.bands[b], entry{hash, p.URL})
+		}
+	}
+	return "", ""
+}
+
+// nearest returns the first recorded page within the distance of hash.
+func (x *Index) nearest(group string, hash uint64) string {
+	for i := range MaxDistance + 1 {
+		for _, e := range x.bands[band{group, i, uint16(hash >> (16 * i))}] {
+			if bits.OnesCount64(e.hash^hash) <= x.distance {
+				return e.url
+			}
+		}
+	}
+	return ""
+}
+
+// Key normalizes a URL for comparison: the host is lowercased and the
+// fragment and a trailing slash are dropped.
+func Key(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	u.Host = strings.ToLower(u.Host)
+	u.Fragment = ""
+	u.RawFragment = ""
+	if len(u.Path) > 1 {
+		u.Path = strings.TrimSuffix(u.Path, "/")
+		u.RawPath = ""
+	}
+	return u.String()
+}
+
+// SimHash returns the 64-bit SimHash of words, using overlapping shingles of
+// shingleSize words as features. Similar texts get fingerprints that differ
+// in few bits.
+func SimHash(words []string) uint64 {
+	var weights [64]int
+	add := func(feature string) {
+		h := fnv.New64a()
+		h.Write([]byte(feature))
+		v := h.Sum64()
+		for i := range 64 {
+			if v&(1<<i) != 0 {
+				weights[i]++
+			} else {
+				weights[i]--
+			}
+		}
+	}
+	if len(words) < shingleSize {
+		add(strings.Join(words, " "))
+	}
+	for i := 0; i+shingleSize <= len(words); i++ {
+		add(strings.Join(words[i:i+shingleSize], " "))
+	}
+
+	var hash uint64
+	for i, w := range weights {
+		if w > 0 {
+			hash |= 1 << i
+		}
+	}
+	return hash
+}
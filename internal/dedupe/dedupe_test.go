@@ -0,0 +1,172 @@
+package dedupe
+
+import (
+	"math/bits"
+	"strings"
+	"testing"
+)
+
+// article is a page body long enough to get a SimHash fingerprint.
+var article = strings.Repeat("install the package with go get then import it in your code and call the client constructor with options ", 3)
+
+// edited is article with its first word changed, one bit away by SimHash.
+var edited = "reinstall" + strings.TrimPrefix(article, "install")
+
+func TestIndexAdd(t *testing.T) {
+	tests := []struct {
+		name   string
+		first  Page
+		later  Page
+		dupOf  string
+		reason string
+	}{
+		{
+			name:   "same canonical",
+			first:  Page{URL: "https://example.com/a", Canonical: "https://example.com/a", Markdown: "Alpha"},
+			later:  Page{URL: "https://example.com/a?lang=en", Canonical: "https://EXAMPLE.com/a/", Markdown: "Alpha, again"},
+			dupOf:  "https://example.com/a",
+			reason: Canonical,
+		},
+		{
+			name:   "canonical pointing at an earlier URL",
+			first:  Page{URL: "https://example.com/old", Markdown: "Old"},
+			later:  Page{URL: "https://example.com/new", Canonical: "https://example.com/old#top", Markdown: "New"},
+			dupOf:  "https://example.com/old",
+			reason: Canonical,
+		},
+		{
+			name:  "shared canonical with different content",
+			first: Page{URL: "https://example.com/a", Canonical: "https://example.com/", Markdown: article},
+			later: Page{URL: "https://example.com/b", Canonical: "https://example.com/", Markdown: strings.Repeat("a completely unrelated page about billing invoices and payment methods for teams ", 3)},
+		},
+		{
+			name:   "identical content",
+			first:  Page{URL: "https://example.com/a", Markdown: "Same text\n"},
+			later:  Page{URL: "https://example.com/b", Markdown: "  Same text"},
+			dupOf:  "https://example.com/a",
+			reason: Exact,
+		},
+		{
+			name:  "empty pages aren't copies",
+			first: Page{URL: "https://example.com/a"},
+			later: Page{URL: "https://example.com/b"},
+		},
+		{
+			name:   "near duplicate",
+			first:  Page{URL: "https://example.com/a", Markdown: article},
+			later:  Page{URL: "https://example.com/b", Markdown: edited},
+			dupOf:  "https://example.com/a",
+			reason: Near,
+		},
+		{
+			name:  "short pages aren't compared by SimHash",
+			first: Page{URL: "https://example.com/a", Markdown: "Install the package"},
+			later: Page{URL: "https://example.com/b", Markdown: "Reinstall the package"},
+		},
+		{
+			name:  "other group",
+			first: Page{URL: "https://example.com/v1/a", Group: "v1", Markdown: article},
+			later: Page{URL: "https://example.com/v2/a", Group: "v2", Markdown: article},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			x := New(MaxDistance)
+			if dupOf, _ := x.Add(tt.first); dupOf != "" {
+				t.Fatalf("first page is a duplicate of %s", dupOf)
+			}
+			dupOf, reason := x.Add(tt.later)
+			if dupOf != tt.dupOf || reason != tt.reason {
+				t.Errorf("Add = %q, %q; want %q, %q", dupOf, reason, tt.dupOf, tt.reason)
+			}
+		})
+	}
+}
+
+func TestIndexPointsAtFirstCopy(t *testing.T) {
+	x := New(0)
+	x.Add(Page{URL: "https://example.com/a", Markdown: "Text"})
+	x.Add(Page{URL: "https://example.com/b", Markdown: "Text"})
+	if dupOf, _ := x.Add(Page{URL: "https://example.com/c", Markdown: "Text"}); dupOf != "https://example.com/a" {
+		t.Errorf("third copy points at %q", dupOf)
+	}
+}
+
+func TestIndexPrefersCanonicalPage(t *testing.T) {
+	const oldURL, newURL = "https://example.com/old", "https://example.com/new"
+	for _, canonical := range []string{newURL, ""} {
+		x := New(MaxDistance)
+		if dupOf, _ := x.Add(Page{URL: oldURL, Canonical: newURL, Markdown: article}); dupOf != "" {
+			t.Fatalf("alias is a duplicate of %s", dupOf)
+		}
+		// The page the alias names is kept, though its content is the same
+		if dupOf, reason := x.Add(Page{URL: newURL, Canonical: canonical, Markdown: article}); dupOf != "" {
+			t.Errorf("canonical %q: canonical page is a duplicate of %s (%s)", canonical, dupOf, reason)
+		}
+		// and later copies point at it
+		for _, p := range []Page{
+			{URL: "https://example.com/older", Canonical: newURL, Markdown: "Older"},
+			{URL: "https://example.com/copy", Markdown: article},
+		} {
+			if dupOf, _ := x.Add(p); dupOf != newURL {
+				t.Errorf("canonical %q: %s points at %q", canonical, p.URL, dupOf)
+			}
+		}
+	}
+
+	// In the other order the alias is a duplicate of the canonical page
+	x := New(MaxDistance)
+	x.Add(Page{URL: newURL, Canonical: newURL, Markdown: article})
+	if dupOf, reason := x.Add(Page{URL: oldURL, Canonical: newURL, Markdown: article}); dupOf != newURL || reason != Canonical {
+		t.Errorf("alias = %q, %q; want %q, %q", dupOf, reason, newURL, Canonical)
+	}
+}
+
+func TestNewExact(t *testing.T) {
+	x := NewExact()
+	x.Add(Page{URL: "https://example.com/a", Canonical: "https://example.com/", Markdown: article})
+	if dupOf, reason := x.Add(Page{URL: "https://example.com/b", Canonical: "https://example.com/", Markdown: edited}); dupOf != "" {
+		t.Errorf("exact index found %q (%s)", dupOf, reason)
+	}
+	if dupOf, reason := x.Add(Page{URL: "https://example.com/c", Markdown: article}); dupOf != "https://example.com/a" || reason != Exact {
+		t.Errorf("Add = %q, %q; want the identical page", dupOf, reason)
+	}
+}
+
+func TestNearDuplicatesDisabled(t *testing.T) {
+	x := New(0)
+	x.Add(Page{URL: "https://example.com/a", Markdown: article})
+	if dupOf, reason := x.Add(Page{URL: "https://example.com/b", Markdown: edited}); dupOf != "" {
+		t.Errorf("distance 0 found %q (%s)", dupOf, reason)
+	}
+	if New(10).distance != MaxDistance {
+		t.Error("distance not capped at MaxDistance")
+	}
+}
+
+func TestSimHash(t *testing.T) {
+	a := SimHash(strings.Fields(article))
+	if d := bits.OnesCount64(a ^ SimHash(strings.Fields(edited))); d > MaxDistance {
+		t.Errorf("edited page is %d bits away", d)
+	}
+	if SimHash(strings.Fields(article)) != a {
+		t.Error("SimHash is not deterministic")
+	}
+	if SimHash([]string{"two", "words"}) == 0 {
+		t.Error("short input has no fingerprint")
+	}
+}
+
+func TestKey(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"https://Example.COM/Docs/", "https://example.com/Docs"},
+		{"https://example.com/a#section", "https://example.com/a"},
+		{"https://example.com/", "https://example.com/"},
+		{"https://example.com/a?x=1", "https://example.com/a?x=1"},
+	}
+	for _, tt := range tests {
+		if got := Key(tt.in); got != tt.want {
+			t.Errorf("Key(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,397 @@
+package extractor
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/url"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Formats of an Embedded body.
+const (
+	EmbeddedMarkdown = "markdown" // markdown or MDX source
+	EmbeddedHTML     = "html"
+)
+
+// Embedded is page content found in the data a JavaScript framework ships
+// with a page to hydrate it: Next.js __NEXT_DATA__, Nuxt's window.__NUXT__
+// and __NUXT_DATA__, or a Gatsby page-data.json.
+type Embedded struct {
+	Source string // "next", "nuxt" or "gatsby"
+	Format string // EmbeddedMarkdown or EmbeddedHTML
+	Body   string
+	Title  string // title stored next to the body, if any
+}
+
+// embeddedMinWords is the length below which a string is not taken for the
+// page body; payloads are full of short labels and descriptions.
+const embeddedMinWords = 20
+
+// bodyKeys are the lowercased JSON keys page bodies are stored under by
+// common CMS and content plugins.
+var bodyKeys = []string{
+	"html", "contenthtml", "bodyhtml", "content", "body", "markdown",
+	"rawmarkdown", "rawmarkdownbody", "rawbody", "source", "mdx", "md",
+}
+
+var (
+	// htmlBlock matches the block elements an HTML body is made of.
+	htmlBlock = regexp.MustCompile(`(?i)<(p|h[1-6]|div|ul|ol|pre|table|section|article|blockquote)[\s>]`)
+	// compiledCode matches bodies compiled to JavaScript, such as MDX
+	// compiled by gatsby-plugin-mdx or next-mdx-remote.
+	compiledCode = regexp.MustCompile(`_jsx\(|jsxRuntime|React\.createElement|\bmdx\(|^\s*(?:"use strict"|function\s|var\s|const\s)`)
+	// nuxtAssign matches the script that sets window.__NUXT__.
+	nuxtAssign = regexp.MustCompile(`^\s*window\.__NUXT__\s*=\s*`)
+	// attrName matches props that can be written as HTML attributes.
+	attrName = regexp.MustCompile(`^[A-Za-z][\w:.-]*$`)
+)
+
+// EmbeddedContent looks for a page body in the hydration data of doc. Nuxt
+// data is only read when it is JSON: the minified function form Nuxt 2 uses
+// in production needs a JavaScript engine.
+func EmbeddedContent(doc *goquery.Document) (Embedded, bool) {
+	if data := doc.Find("script#__NEXT_DATA__").First(); data.Length() > 0 {
+		var v any
+		if json.Unmarshal([]byte(data.Text()), &v) == nil {
+			if props := lookup(v, "props", "pageProps"); props != nil {
+				v = props
+			}
+			if e, ok := findBody(v); ok {
+				e.Source = "next"
+				return e, true
+			}
+		}
+	}
+
+	if data := doc.Find("script#__NUXT_DATA__").First(); data.Length() > 0 {
+		var v []any
+		if json.Unmarshal([]byte(data.Text()), &v) == nil && len(v) > 0 {
+			if e, ok := findBody(unflatten(v)); ok {
+				e.Source = "nuxt"
+				return e, true
+			}
+		}
+	}
+
+	var found Embedded
+	ok := false
+	doc.Find("script").EachWithBreak(func(_ int, s *goquery.Selection) bool {
+		src := s.Text()
+		loc := nuxtAssign.FindStringIndex(src)
+		if loc == nil {
+			return true
+		}
+		var v any
+		payload := strings.TrimRight(strings.TrimSpace(src[loc[1]:]), ";")
+		if json.Unmarshal([]byte(payload), &v) == nil {
+			found, ok = findBody(v)
+			found.Source = "nuxt"
+		}
+		return false
+	})
+	return found, ok
+}
+
+// GatsbyPageDataURL returns the URL of the page-data.json file holding the
+// data of a Gatsby page, or "" if doc isn't a Gatsby page. Gatsby preloads
+// the file, which gives its location under any path prefix; otherwise it is
+// derived from pageURL.
+func GatsbyPageDataURL(doc *goquery.Document, pageURL string) string {
+	if doc.Find("#___gatsby").Length() == 0 {
+		return ""
+	}
+	href := ""
+	doc.Find(`link[href*="/page-data/"]`).EachWithBreak(func(_ int, s *goquery.Selection) bool {
+		h := s.AttrOr("href", "")
+		if strings.HasSuffix(h, "/page-data.json") {
+			href = h
+			return false
+		}
+		return true
+	})
+	if href != "" {
+		return resolveURL(pageURL, href)
+	}
+
+	u, err := url.Parse(pageURL)
+	if err != nil {
+		return ""
+	}
+	p := strings.TrimSuffix(u.Path, "index.html")
+	p = strings.Trim(p, "/")
+	if p == "" {
+		p = "index"
+	}
+	return resolveURL(pageURL, "/page-data/"+p+"/page-data.json")
+}
+
+// EmbeddedFromPageData looks for a page body in a Gatsby page-data.json file.
+func EmbeddedFromPageData(data []byte) (Embedded, bool) {
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return Embedded{}, false
+	}
+	if result := lookup(v, "result"); result != nil {
+		v = result
+	}
+	e, ok := findBody(v)
+	e.Source = "gatsby"
+	return e, ok
+}
+
+// lookup follows keys through nested JSON objects and returns the value
+// found, or nil.
+func lookup(v any, keys ...string) any {
+	for _, k := range keys {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil
+		}
+		v = m[k]
+	}
+	return v
+}
+
+// findBody walks a JSON value and returns the longest body stored under one
+// of bodyKeys: an HTML or markdown string, or a Nuxt Content syntax tree.
+func findBody(v any) (Embedded, bool) {
+	var best Embedded
+	bestWords := 0
+	var walk func(v any)
+	walk = func(v any) {
+		switch v := v.(type) {
+		case []any:
+			for _, x := range v {
+				walk(x)
+			}
+		case map[string]any:
+			for _, k := range sortedKeys(v) {
+				x := v[k]
+				if slices.Contains(bodyKeys, strings.ToLower(k)) {
+					if e, words := bodyValue(x); words > bestWords {
+						e.Title = siblingTitle(v)
+						best, bestWords = e, words
+						continue
+					}
+				}
+				walk(x)
+			}
+		}
+	}
+	walk(v)
+	return best, bestWords >= embeddedMinWords
+}
+
+// bodyValue returns v as a page body and its length in words, or 0 words if
+// v isn't one.
+func bodyValue(v any) (Embedded, int) {
+	switch v := v.(type) {
+	case string:
+		if compiledCode.MatchString(v) {
+			return Embedded{}, 0
+		}
+		if htmlBlock.MatchString(v) {
+			doc, err := goquery.NewDocumentFromReader(strings.NewReader(v))
+			if err != nil {
+				return Embedded{}, 0
+			}
+			return Embedded{Format: EmbeddedHTML, Body: v}, len(strings.Fields(doc.Text()))
+		}
+		return Embedded{Format: EmbeddedMarkdown, Body: v}, len(strings.Fields(v))
+	case map[string]any:
+		var b strings.Builder
+		switch v["type"] {
+		case "root":
+			renderNode(&b, v)
+		case "minimark":
+			if nodes, ok := v["value"].([]any); ok {
+				for _, n := range nodes {
+					renderMinimark(&b, n)
+				}
+			}
+		default:
+			return Embedded{}, 0
+		}
+		doc, err := goquery.NewDocumentFromReader(strings.NewReader(b.String()))
+		if err != nil {
+			return Embedded{}, 0
+		}
+		return Embedded{Format: EmbeddedHTML, Body: b.String()}, len(strings.Fields(doc.Text()))
+	}
+	return Embedded{}, 0
+}
+
+// siblingTitle returns the title stored in the object holding a body, either
+// directly or in its frontmatter.
+func siblingTitle(m map[string]any) string {
+	for _, v := range []any{m["title"], lookup(m, "frontmatter", "title"), lookup(m, "meta", "title")} {
+		if s, ok := v.(string); ok && strings.TrimSpace(s) != "" {
+			return strings.Join(strings.Fields(s), " ")
+		}
+	}
+	return ""
+}
+
+// voidElements have no closing tag.
+var voidElements = []string{"area", "br", "col", "hr", "img", "input", "source", "wbr"}
+
+// renderNode writes a Nuxt Content (v1 and v2) syntax tree node as HTML.
+func renderNode(b *strings.Builder, n map[string]any) {
+	switch n["type"] {
+	case "text":
+		s, _ := n["value"].(string)
+		b.WriteString(html.EscapeString(s))
+		return
+	case "element":
+		tag, _ := n["tag"].(string)
+		if !attrName.MatchString(tag) || tag == "script" || tag == "style" {
+			return
+		}
+		props, _ := n["props"].(map[string]any)
+		openTag(b, tag, props)
+		if slices.Contains(voidElements, tag) {
+			return
+		}
+		defer b.WriteString("</" + tag + ">")
+	}
+	children, _ := n["children"].([]any)
+	for _, c := range children {
+		if c, ok := c.(map[string]any); ok {
+			renderNode(b, c)
+		}
+	}
+}
+
+// renderMinimark writes a Nuxt Content v3 "minimark" node, either a text
+// string or [tag, props, ...children], as HTML.
+func renderMinimark(b *strings.Builder, n any) {
+	switch n := n.(type) {
+	case string:
+		b.WriteString(html.EscapeString(n))
+	case []any:
+		if len(n) == 0 {
+			return
+		}
+		tag, _ := n[0].(string)
+		if !attrName.MatchString(tag) || tag == "script" || tag == "style" {
+			return
+		}
+		var props map[string]any
+		if len(n) > 1 {
+			props, _ = n[1].(map[string]any)
+		}
+		openTag(b, tag, props)
+		if slices.Contains(voidElements, tag) {
+			return
+		}
+		for _, c := range n[min(2, len(n)):] {
+			renderMinimark(b, c)
+		}
+		b.WriteString("</" + tag + ">")
+	}
+}
+
+// openTag writes a start tag with props as attributes. className lists
+// become the class attribute; props that aren't strings, numbers or true are
+// dropped.
+func openTag(b *strings.Builder, tag string, props map[string]any) {
+	b.WriteString("<" + tag)
+	for _, k := range sortedKeys(props) {
+		name := k
+		if name == "className" {
+			name = "class"
+		}
+		if !attrName.MatchString(name) {
+			continue
+		}
+		switch v := props[k].(type) {
+		case string:
+			fmt.Fprintf(b, ` %s="%s"`, name, html.EscapeString(v))
+		case float64:
+			fmt.Fprintf(b, ` %s="%g"`, name, v)
+		case bool:
+			if v {
+				b.WriteString(" " + name)
+			}
+		case []any:
+			var parts []string
+			for _, p := range v {
+				if s, ok := p.(string); ok {
+					parts = append(parts, s)
+				}
+			}
+			fmt.Fprintf(b, ` %s="%s"`, name, html.EscapeString(strings.Join(parts, " ")))
+		}
+	}
+	b.WriteString(">")
+}
+
+// unflatten decodes the devalue format of Nuxt 3's __NUXT_DATA__: a flat
+// array whose first element is the root, where objects and arrays refer to
+// their members by index. Vue wrappers such as ["Reactive", i] are unwrapped.
+func unflatten(values []any) any {
+	seen := make(map[int]bool)
+	var hydrate func(i int) any
+	hydrate = func(i int) any {
+		if i < 0 || i >= len(values) || seen[i] {
+			return nil
+		}
+		seen[i] = true
+		defer delete(seen, i)
+
+		switch v := values[i].(type) {
+		case map[string]any:
+			out := make(map[string]any, len(v))
+			for k, ref := range v {
+				if n, ok := ref.(float64); ok {
+					out[k] = hydrate(int(n))
+				}
+			}
+			return out
+		case []any:
+			if len(v) > 0 {
+				if tag, ok := v[0].(string); ok {
+					switch tag {
+					case "Reactive", "ShallowReactive", "Ref", "ShallowRef":
+						if len(v) > 1 {
+							if n, ok := v[1].(float64); ok {
+								return hydrate(int(n))
+							}
+						}
+					case "Date":
+						if len(v) > 1 {
+							return v[1]
+						}
+					}
+					return nil
+				}
+			}
+			out := make([]any, 0, len(v))
+			for _, ref := range v {
+				if n, ok := ref.(float64); ok {
+					out = append(out, hydrate(int(n)))
+				}
+			}
+			return out
+		default:
+			return v
+		}
+	}
+	return hydrate(0)
+}
+
+// sortedKeys returns the keys of m in order, so walks are deterministic.
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
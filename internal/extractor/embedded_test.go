@@ -0,0 +1,200 @@
+package extractor
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// prose is long enough to be taken for a page body.
+const prose = "Install the command line tool with your package manager, then run the setup command once to create a configuration file in your home directory before first use."
+
+func parse(t *testing.T, page string) *goquery.Document {
+	t.Helper()
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(page))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+func TestEmbeddedContent(t *testing.T) {
+	tests := []struct {
+		name   string
+		page   string
+		want   Embedded
+		wantOK bool
+	}{
+		{
+			name: "next markdown",
+			page: `<script id="__NEXT_DATA__" type="application/json">` +
+				`{"props":{"pageProps":{"nav":{"content":"Short label"},"doc":{"title":"Install","markdown":"# Install\n\n` + prose + `"}}}}</script>`,
+			want:   Embedded{Source: "next", Format: EmbeddedMarkdown, Body: "# Install\n\n" + prose, Title: "Install"},
+			wantOK: true,
+		},
+		{
+			name: "next html with frontmatter title",
+			page: `<script id="__NEXT_DATA__" type="application/json">` +
+				`{"props":{"pageProps":{"post":{"frontmatter":{"title":"Setup"},"html":"<p>` + prose + `</p>"}}}}</script>`,
+			want:   Embedded{Source: "next", Format: EmbeddedHTML, Body: "<p>" + prose + "</p>", Title: "Setup"},
+			wantOK: true,
+		},
+		{
+			name: "next compiled mdx rejected",
+			page: `<script id="__NEXT_DATA__" type="application/json">` +
+				`{"props":{"pageProps":{"source":"function MDXContent(){return _jsx(\"p\",{children:\"` + prose + `\"})}"}}}</script>`,
+		},
+		{
+			name: "nuxt 3 devalue",
+			page: `<script type="application/json" id="__NUXT_DATA__">` +
+				`[["Reactive",1],{"data":2},{"page":3},{"title":4,"body":5},"Usage","` + prose + `"]</script>`,
+			want:   Embedded{Source: "nuxt", Format: EmbeddedMarkdown, Body: prose, Title: "Usage"},
+			wantOK: true,
+		},
+		{
+			name:   "nuxt 2 window.__NUXT__ json",
+			page:   `<script>window.__NUXT__ = {"data":[{"page":{"meta":{"title":"Config"},"content":"` + prose + `"}}]};</script>`,
+			want:   Embedded{Source: "nuxt", Format: EmbeddedMarkdown, Body: prose, Title: "Config"},
+			wantOK: true,
+		},
+		{
+			name: "nuxt 2 function form skipped",
+			page: `<script>window.__NUXT__=(function(a,b){return {data:[{content:"` + prose + `"}]}}(1,2));</script>`,
+		},
+		{
+			name: "too short",
+			page: `<script id="__NEXT_DATA__" type="application/json">{"props":{"pageProps":{"content":"Just a few words."}}}</script>`,
+		},
+		{
+			name: "no data",
+			page: `<main><p>` + prose + `</p></main>`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := EmbeddedContent(parse(t, "<html><body>"+tt.page+"</body></html>"))
+			if ok != tt.wantOK {
+				t.Fatalf("ok = %v, want %v (%+v)", ok, tt.wantOK, got)
+			}
+			if ok && got != tt.want {
+				t.Errorf("got %+v\nwant %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEmbeddedPicksLongestBody(t *testing.T) {
+	doc := parse(t, `<script id="__NEXT_DATA__" type="application/json">`+
+		`{"props":{"pageProps":{"a":{"body":"`+prose+`"},"b":{"body":"`+prose+` `+prose+`"}}}}</script>`)
+	got, ok := EmbeddedContent(doc)
+	if !ok || got.Body != prose+" "+prose {
+		t.Errorf("got %q, %v; want the longer body", got.Body, ok)
+	}
+}
+
+func TestNuxtContentTrees(t *testing.T) {
+	const ast = `{"page":{"title":"Guide","body":{"type":"root","children":[` +
+		`{"type":"element","tag":"h2","props":{"id":"intro"},"children":[{"type":"text","value":"Intro & more"}]},` +
+		`{"type":"element","tag":"p","props":{"className":["lead","big"],"hidden":true,"onClick":false},"children":[{"type":"text","value":"` + prose + `"}]},` +
+		`{"type":"element","tag":"img","props":{"src":"/a.png","width":64}},` +
+		`{"type":"element","tag":"script","children":[{"type":"text","value":"alert(1)"}]}]}}}`
+	const minimark = `{"page":{"title":"Guide","body":{"type":"minimark","value":[` +
+		`["h2",{"id":"intro"},"Intro & more"],` +
+		`["p",{"className":["lead","big"],"hidden":true,"onClick":false},"` + prose + `"],` +
+		`["img",{"src":"/a.png","width":64}],` +
+		`["script",{},"alert(1)"]]}}}`
+	const want = `<h2 id="intro">Intro &amp; more</h2>` +
+		`<p class="lead big" hidden>` + prose + `</p>` +
+		`<img src="/a.png" width="64">`
+
+	for name, data := range map[string]string{"ast": ast, "minimark": minimark} {
+		t.Run(name, func(t *testing.T) {
+			got, ok := EmbeddedFromPageData([]byte(data))
+			if !ok {
+				t.Fatal("no body found")
+			}
+			if got.Format != EmbeddedHTML || got.Body != want || got.Title != "Guide" {
+				t.Errorf("got %+v\nwant body %s", got, want)
+			}
+		})
+	}
+}
+
+func TestGatsbyPageDataURL(t *testing.T) {
+	tests := []struct {
+		name, page, pageURL, want string
+	}{
+		{
+			name:    "preload link",
+			page:    `<head><link rel="preload" href="/docs/page-data/app-data.json"><link rel="preload" href="/docs/page-data/guide/install/page-data.json"></head><body><div id="___gatsby"></div></body>`,
+			pageURL: "https://example.com/docs/guide/install/",
+			want:    "https://example.com/docs/page-data/guide/install/page-data.json",
+		},
+		{
+			name:    "derived from the path",
+			page:    `<div id="___gatsby"></div>`,
+			pageURL: "https://example.com/guide/install/index.html",
+			want:    "https://example.com/page-data/guide/install/page-data.json",
+		},
+		{
+			name:    "home page",
+			page:    `<div id="___gatsby"></div>`,
+			pageURL: "https://example.com/",
+			want:    "https://example.com/page-data/index/page-data.json",
+		},
+		{
+			name:    "not gatsby",
+			page:    `<link href="/page-data/x/page-data.json"><div id="root"></div>`,
+			pageURL: "https://example.com/x/",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := GatsbyPageDataURL(parse(t, tt.page), tt.pageURL); got != tt.want {
+				t.Errorf("GatsbyPageDataURL = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEmbeddedFromPageData(t *testing.T) {
+	data := `{"componentChunkName":"component---src-templates-doc-js","result":{"data":{"markdownRemark":{"frontmatter":{"title":"Deploy"},"html":"<h2>Steps</h2><p>` + prose + `</p>"}}}}`
+	got, ok := EmbeddedFromPageData([]byte(data))
+	want := Embedded{Source: "gatsby", Format: EmbeddedHTML, Body: "<h2>Steps</h2><p>" + prose + "</p>", Title: "Deploy"}
+	if !ok || got != want {
+		t.Errorf("got %+v, %v\nwant %+v", got, ok, want)
+	}
+
+	// gatsby-plugin-mdx ships compiled code, which can't be used
+	mdx := `{"result":{"data":{"mdx":{"body":"var _excluded = [\"components\"];\nfunction _extends() {}\nreturn mdx(\"p\", null, \"` + prose + `\")"}}}}`
+	if got, ok := EmbeddedFromPageData([]byte(mdx)); ok {
+		t.Errorf("compiled MDX accepted: %+v", got)
+	}
+	if _, ok := EmbeddedFromPageData([]byte("not json")); ok {
+		t.Error("invalid JSON accepted")
+	}
+}
+
+func TestUnflatten(t *testing.T) {
+	values := []any{
+		map[string]any{"self": float64(0), "list": float64(1), "when": float64(3), "ref": float64(4), "bad": float64(99)},
+		[]any{float64(2), float64(2)},
+		"item",
+		[]any{"Date", "2026-01-02T00:00:00.000Z"},
+		[]any{"ShallowRef", float64(2)},
+	}
+	got, ok := unflatten(values).(map[string]any)
+	if !ok {
+		t.Fatalf("unflatten = %#v", unflatten(values))
+	}
+	if got["self"] != nil || got["bad"] != nil {
+		t.Errorf("cycle or bad index not dropped: %#v", got)
+	}
+	if list, _ := got["list"].([]any); len(list) != 2 || list[0] != "item" || list[1] != "item" {
+		t.Errorf("list = %#v", got["list"])
+	}
+	if got["when"] != "2026-01-02T00:00:00.000Z" || got["ref"] != "item" {
+		t.Errorf("wrappers = %#v, %#v", got["when"], got["ref"])
+	}
+}
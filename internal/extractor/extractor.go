@@ -47,19 +47,14 @@ var noiseSelectors = []string{
 }
 
 // Extract parses the HTML body, isolates the main content area, removes noise,
-// and returns the cleaned inner HTML and the page title.
-func Extract(htmlBody []byte, selector string, sourceURL string) (contentHTML string, title string, err error) {
+// and returns the cleaned inner HTML and the page title. The title is taken
+// from the first of titleFrom (see DefaultTitleFrom) the page provides.
+func Extract(htmlBody []byte, selector string, titleFrom []string, sourceURL string) (contentHTML string, title string, err error) {
 	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(htmlBody))
 	if err != nil {
 		return "", "", err
 	}
 
-	// Extract title
-	title = strings.TrimSpace(doc.Find("title").First().Text())
-	if title == "" {
-		title = strings.TrimSpace(doc.Find("h1").First().Text())
-	}
-
 	// Select main content area
 	var selection *goquery.Selection
 	if selector != "" {
@@ -68,6 +63,8 @@ func Extract(htmlBody []byte, selector string, sourceURL string) (contentHTML st
 		selection = findMainContent(doc)
 	}
 
+	title = pickTitle(doc, selection, titleFrom)
+
 	// Remove noise elements
 	combined := strings.Join(noiseSelectors, ", ")
 	selection.Find(combined).Remove()
@@ -91,3 +88,22 @@ func findMainContent(doc *goquery.Document) *goquery.Selection {
 	}
 	return doc.Find("body")
 }
+
+// appRoots are the elements single-page app frameworks mount into. They are
+// empty in the served HTML of a client-rendered page.
+var appRoots = []string{"#root", "#__next", "#app", "#___gatsby", "#__nuxt", "[data-reactroot]", "[ng-app]"}
+
+// ClientRendered reports whether a page looks rendered by JavaScript in the
+// browser: it has an empty app root, or a body with scripts but no text.
+func ClientRendered(doc *goquery.Document) bool {
+	for _, sel := range appRoots {
+		root := doc.Find(sel).First()
+		if root.Length() > 0 && strings.TrimSpace(root.Text()) == "" {
+			return true
+		}
+	}
+	body := doc.Find("body")
+	text := body.Clone()
+	text.Find("script, style, noscript, template").Remove()
+	return body.Find("script").Length() > 0 && strings.TrimSpace(text.Text()) == ""
+}
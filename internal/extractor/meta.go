@@ -0,0 +1,235 @@
+package extractor
+
+import (
+	"encoding/json"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// PageMeta is page-level metadata collected from an HTML document's head and
+// page chrome. Fields are empty when the page doesn't provide them.
+type PageMeta struct {
+	Title       string // <title>, as written by the site
+	Description string
+	Canonical   string // absolute canonical URL
+	Lang        string // <html lang>
+	Keywords    []string
+	Modified    string            // last modification, RFC 3339 or YYYY-MM-DD
+	Breadcrumbs []string          // breadcrumb trail, outermost first
+	OpenGraph   map[string]string // og:* properties, keyed without the "og:" prefix
+}
+
+// modifiedSelectors name elements whose datetime or content attribute holds
+// the page's last modification time, most specific first.
+var modifiedSelectors = []struct{ selector, attr string }{
+	{`meta[property="article:modified_time"]`, "content"},
+	{`meta[property="og:updated_time"]`, "content"},
+	{`meta[itemprop="dateModified"]`, "content"},
+	{`meta[name="last-modified"]`, "content"},
+	{`time[itemprop="dateModified"]`, "datetime"},
+	{`.last-updated time, .theme-last-updated time, .git-revision-date-localized-plugin time, [class*="lastUpdated"] time`, "datetime"},
+}
+
+// lastUpdatedSelectors name elements that show a "Last updated" note.
+var lastUpdatedSelectors = `.last-updated, .theme-last-updated, .git-revision-date-localized-plugin, [class*="lastUpdated"], [class*="last-updated"]`
+
+// breadcrumbSelectors find breadcrumb containers in common themes.
+var breadcrumbSelectors = `nav[aria-label="breadcrumb"], nav[aria-label="Breadcrumb"], nav[aria-label="breadcrumbs"], nav[aria-label="Breadcrumbs"], .breadcrumb, .breadcrumbs`
+
+// lastUpdatedText matches the date part of "Last updated on March 3, 2026".
+var lastUpdatedText = regexp.MustCompile(`(?i)last\s+updated:?\s*(?:on\s+)?([A-Za-z0-9,./: -]{6,40})`)
+
+// dateLayouts are the date formats recognized in "Last updated" text.
+var dateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+	"January 2, 2006",
+	"Jan 2, 2006",
+	"2 January 2006",
+	"2 Jan 2006",
+	"01/02/2006",
+	"2006/01/02",
+}
+
+// Metadata collects page metadata from a parsed HTML document. sourceURL is
+// used to resolve a relative canonical link.
+func Metadata(doc *goquery.Document, sourceURL string) PageMeta {
+	var m PageMeta
+
+	m.Title = strings.Join(strings.Fields(doc.Find("title").First().Text()), " ")
+	m.Description = metaContent(doc, `meta[name="description"]`)
+	if m.Description == "" {
+		m.Description = metaContent(doc, `meta[property="og:description"]`)
+	}
+	m.Lang = strings.TrimSpace(doc.Find("html").AttrOr("lang", ""))
+
+	if href := strings.TrimSpace(doc.Find(`link[rel="canonical"]`).AttrOr("href", "")); href != "" {
+		m.Canonical = resolveURL(sourceURL, href)
+	}
+
+	for _, k := range strings.Split(metaContent(doc, `meta[name="keywords"]`), ",") {
+		if k = strings.TrimSpace(k); k != "" {
+			m.Keywords = append(m.Keywords, k)
+		}
+	}
+
+	doc.Find(`meta[property^="og:"]`).Each(func(_ int, s *goquery.Selection) {
+		key := strings.TrimPrefix(s.AttrOr("property", ""), "og:")
+		value := strings.TrimSpace(s.AttrOr("content", ""))
+		if key == "" || value == "" {
+			return
+		}
+		if m.OpenGraph == nil {
+			m.OpenGraph = make(map[string]string)
+		}
+		if _, ok := m.OpenGraph[key]; !ok {
+			m.OpenGraph[key] = value
+		}
+	})
+
+	m.Modified = modified(doc)
+	m.Breadcrumbs = breadcrumbs(doc)
+	return m
+}
+
+func metaContent(doc *goquery.Document, selector string) string {
+	return strings.TrimSpace(doc.Find(selector).First().AttrOr("content", ""))
+}
+
+// modified returns the page's last modification date from meta tags, a
+// <time> element, or "Last updated" text.
+func modified(doc *goquery.Document) string {
+	for _, m := range modifiedSelectors {
+		if v := normalizeDate(doc.Find(m.selector).First().AttrOr(m.attr, "")); v != "" {
+			return v
+		}
+	}
+
+	var found string
+	check := func(text string) bool {
+		if match := lastUpdatedText.FindStringSubmatch(text); match != nil {
+			found = normalizeDate(match[1])
+		}
+		return found != ""
+	}
+	doc.Find(lastUpdatedSelectors).EachWithBreak(func(_ int, s *goquery.Selection) bool {
+		return !check(s.Text())
+	})
+	if found == "" {
+		doc.Find("footer, small, span, p, div").EachWithBreak(func(_ int, s *goquery.Selection) bool {
+			if s.Children().Length() > 3 {
+				return true
+			}
+			text := s.Text()
+			return len(text) > 200 || !check(text)
+		})
+	}
+	return found
+}
+
+// normalizeDate parses a date in one of dateLayouts, trying successively
+// shorter prefixes so trailing text is ignored. Dates with a time of day are
+// returned in RFC 3339, dates without as YYYY-MM-DD.
+func normalizeDate(s string) string {
+	s = strings.TrimSpace(s)
+	for end := len(s); end >= 6; end-- {
+		candidate := strings.TrimRight(s[:end], " ,.")
+		for _, layout := range dateLayouts {
+			t, err := time.Parse(layout, candidate)
+			if err != nil {
+				continue
+			}
+			if strings.Contains(layout, "15") {
+				return t.Format(time.RFC3339)
+			}
+			return t.Format("2006-01-02")
+		}
+	}
+	return ""
+}
+
+// breadcrumbs returns the breadcrumb trail from JSON-LD BreadcrumbList data or
+// a breadcrumb navigation element.
+func breadcrumbs(doc *goquery.Document) []string {
+	var trail []string
+	doc.Find(`script[type="application/ld+json"]`).EachWithBreak(func(_ int, s *goquery.Selection) bool {
+		trail = jsonLDBreadcrumbs([]byte(s.Text()))
+		return trail == nil
+	})
+	if trail != nil {
+		return trail
+	}
+
+	nav := doc.Find(breadcrumbSelectors).First()
+	items := nav.Find("li")
+	if items.Length() == 0 {
+		items = nav.Find("a")
+	}
+	items.Each(func(_ int, s *goquery.Selection) {
+		if text := strings.Join(strings.Fields(s.Text()), " "); text != "" {
+			trail = append(trail, text)
+		}
+	})
+	return trail
+}
+
+// jsonLDBreadcrumbs extracts item names from a schema.org BreadcrumbList,
+// which may be the top-level object, inside an array, or in an @graph.
+func jsonLDBreadcrumbs(data []byte) []string {
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil
+	}
+
+	var find func(v any) []string
+	find = func(v any) []string {
+		switch t := v.(type) {
+		case []any:
+			for _, item := range t {
+				if trail := find(item); trail != nil {
+					return trail
+				}
+			}
+		case map[string]any:
+			if t["@type"] == "BreadcrumbList" {
+				var trail []string
+				items, _ := t["itemListElement"].([]any)
+				for _, item := range items {
+					entry, _ := item.(map[string]any)
+					name, _ := entry["name"].(string)
+					if name == "" {
+						inner, _ := entry["item"].(map[string]any)
+						name, _ = inner["name"].(string)
+					}
+					if name = strings.TrimSpace(name); name != "" {
+						trail = append(trail, name)
+					}
+				}
+				return trail
+			}
+			if graph, ok := t["@graph"]; ok {
+				return find(graph)
+			}
+		}
+		return nil
+	}
+	return find(v)
+}
+
+func resolveURL(base, ref string) string {
+	b, err := url.Parse(base)
+	if err != nil {
+		return ref
+	}
+	r, err := url.Parse(ref)
+	if err != nil {
+		return ref
+	}
+	return b.ResolveReference(r).String()
+}
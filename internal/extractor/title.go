@@ -0,0 +1,152 @@
+package extractor
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Title sources for TitleFrom.
+const (
+	TitleH1      = "h1"
+	TitleOG      = "og:title"
+	TitleElement = "title"
+)
+
+// DefaultTitleFrom is the title priority used when none is configured: the
+// page's own heading first, since <title> usually carries the site name.
+var DefaultTitleFrom = []string{TitleH1, TitleOG, TitleElement}
+
+// titleSeparators split a site name from the page part of a title.
+var titleSeparators = []string{" | ", " - ", " – ", " — ", " · ", " :: ", " » ", " : "}
+
+// ValidateTitleFrom checks that every entry names a title source.
+func ValidateTitleFrom(sources []string) error {
+	for _, s := range sources {
+		switch s {
+		case TitleH1, TitleOG, TitleElement:
+		default:
+			return fmt.Errorf("unknown title source %q (want h1, og:title or title)", s)
+		}
+	}
+	return nil
+}
+
+// pickTitle returns the first non-empty title from sources, in order. The
+// H1 is looked up in content first so a site-wide header H1 doesn't win over
+// the page's own.
+func pickTitle(doc *goquery.Document, content *goquery.Selection, sources []string) string {
+	if len(sources) == 0 {
+		sources = DefaultTitleFrom
+	}
+	for _, s := range sources {
+		var title string
+		switch s {
+		case TitleH1:
+			h1 := content.Find("h1").First()
+			if h1.Length() == 0 {
+				h1 = doc.Find("h1").First()
+			}
+			title = h1.Text()
+		case TitleOG:
+			title = doc.Find(`meta[property="og:title"]`).First().AttrOr("content", "")
+		case TitleElement:
+			title = doc.Find("title").First().Text()
+		}
+		if title = strings.Join(strings.Fields(title), " "); title != "" {
+			return title
+		}
+	}
+	return ""
+}
+
+// CommonAffixes returns the site-name prefix and suffix shared by titles,
+// such as " | Acme Docs" in "Install | Acme Docs" and "Usage | Acme Docs".
+// An affix always starts or ends at a separator like " | " or " - ". Every
+// title must carry it, except for one odd error page or placeholder title;
+// titles that are just the site name (a home page) count as carrying it.
+func CommonAffixes(titles []string) (prefix, suffix string) {
+	var sample []string
+	for _, t := range titles {
+		if t = strings.TrimSpace(t); t != "" {
+			sample = append(sample, t)
+		}
+	}
+	if len(sample) < 2 {
+		return "", ""
+	}
+
+	// Candidates come from the first title with a separator, since a shared
+	// affix must be in it or in the one title allowed to differ. The longest
+	// shared one wins.
+	var t string
+	for _, s := range sample {
+		if hasSeparator(s) {
+			t = s
+			break
+		}
+	}
+	for _, sep := range titleSeparators {
+		for i := strings.Index(t, sep); i >= 0; {
+			s := t[i:]
+			if len(s) > len(suffix) && sharedAffix(sample, s, sep, strings.HasSuffix) {
+				suffix = s
+			}
+			p := t[:i+len(sep)]
+			if len(p) > len(prefix) && sharedAffix(sample, p, sep, strings.HasPrefix) {
+				prefix = p
+			}
+			next := strings.Index(t[i+len(sep):], sep)
+			if next < 0 {
+				break
+			}
+			i += len(sep) + next
+		}
+	}
+	return prefix, suffix
+}
+
+func hasSeparator(title string) bool {
+	for _, sep := range titleSeparators {
+		if strings.Contains(title, sep) {
+			return true
+		}
+	}
+	return false
+}
+
+// sharedAffix reports whether all titles but at most one have affix (as
+// tested by has) or are the bare site name, and at least two have it.
+func sharedAffix(titles []string, affix, sep string, has func(s, affix string) bool) bool {
+	mark := strings.TrimSpace(sep)
+	site := strings.TrimSpace(affix)
+	site = strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(site, mark), mark))
+	n, other := 0, 0
+	for _, t := range titles {
+		switch {
+		case has(t, affix) && len(t) > len(affix):
+			n++
+		case t == site:
+		default:
+			other++
+		}
+	}
+	return n >= 2 && other <= 1
+}
+
+// StripAffixes removes prefix and suffix from title, unless that would leave
+// it empty.
+func StripAffixes(title, prefix, suffix string) string {
+	stripped := title
+	if suffix != "" {
+		stripped = strings.TrimSuffix(stripped, suffix)
+	}
+	if prefix != "" {
+		stripped = strings.TrimPrefix(stripped, prefix)
+	}
+	if stripped = strings.TrimSpace(stripped); stripped == "" {
+		return title
+	}
+	return stripped
+}
@@ -0,0 +1,143 @@
+package extractor
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestCommonAffixes(t *testing.T) {
+	tests := []struct {
+		name           string
+		titles         []string
+		prefix, suffix string
+	}{
+		{
+			name:   "shared suffix",
+			titles: []string{"Install | Acme Docs", "Usage | Acme Docs", "API | Acme Docs"},
+			suffix: " | Acme Docs",
+		},
+		{
+			name:   "longest suffix wins",
+			titles: []string{"Install - Guide - Acme", "Usage - Guide - Acme", "Config - Guide - Acme"},
+			suffix: " - Guide - Acme",
+		},
+		{
+			name:   "shared prefix",
+			titles: []string{"Acme :: Install", "Acme :: Usage", "Acme :: API"},
+			prefix: "Acme :: ",
+		},
+		{
+			name:   "bare site name counts",
+			titles: []string{"Acme Docs", "Install | Acme Docs", "Usage | Acme Docs"},
+			suffix: " | Acme Docs",
+		},
+		{
+			name:   "one odd title tolerated",
+			titles: []string{"Page not found", "Install | Acme Docs", "Usage | Acme Docs", "API | Acme Docs"},
+			suffix: " | Acme Docs",
+		},
+		{
+			name:   "two odd titles block",
+			titles: []string{"Install | Acme Docs", "Usage | Acme Docs", "Page not found", "Loading..."},
+		},
+		{
+			name: "half the titles is not enough",
+			titles: []string{
+				"Guide - Install", "Guide - Usage", "Guide - Config", "Guide - Deploy",
+				"Overview", "FAQ", "Changelog", "Support",
+			},
+		},
+		{
+			name:   "needs two carriers",
+			titles: []string{"Install | Acme Docs", "Acme Docs"},
+		},
+		{
+			name:   "title equal to affix doesn't count",
+			titles: []string{"A | B", " | B"},
+		},
+		{
+			name:   "no separators",
+			titles: []string{"Install", "Usage", "API"},
+		},
+		{
+			name:   "single title",
+			titles: []string{"Install | Acme Docs", "", "  "},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prefix, suffix := CommonAffixes(tt.titles)
+			if prefix != tt.prefix || suffix != tt.suffix {
+				t.Errorf("CommonAffixes = %q, %q; want %q, %q", prefix, suffix, tt.prefix, tt.suffix)
+			}
+		})
+	}
+}
+
+func TestStripAffixes(t *testing.T) {
+	tests := []struct {
+		title, prefix, suffix, want string
+	}{
+		{"Install | Acme Docs", "", " | Acme Docs", "Install"},
+		{"Acme :: Install", "Acme :: ", "", "Install"},
+		{"Acme Docs", "", " | Acme Docs", "Acme Docs"},
+		{" | Acme Docs", "", " | Acme Docs", " | Acme Docs"},
+		{"Other - Site", "", " | Acme Docs", "Other - Site"},
+	}
+	for _, tt := range tests {
+		if got := StripAffixes(tt.title, tt.prefix, tt.suffix); got != tt.want {
+			t.Errorf("StripAffixes(%q) = %q, want %q", tt.title, got, tt.want)
+		}
+	}
+}
+
+func TestPickTitle(t *testing.T) {
+	const page = `<html><head>
+<title>Install | Acme</title>
+<meta property="og:title" content="Install Acme">
+</head><body>
+<header><h1>Acme</h1></header>
+<main><h1>  Installing
+ Acme </h1></main>
+</body></html>`
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(page))
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := doc.Find("main")
+
+	tests := []struct {
+		sources []string
+		want    string
+	}{
+		{nil, "Installing Acme"},
+		{[]string{TitleElement, TitleH1}, "Install | Acme"},
+		{[]string{TitleOG}, "Install Acme"},
+	}
+	for _, tt := range tests {
+		if got := pickTitle(doc, content, tt.sources); got != tt.want {
+			t.Errorf("pickTitle(%v) = %q, want %q", tt.sources, got, tt.want)
+		}
+	}
+
+	// The document's H1 is used when the content has none
+	if got := pickTitle(doc, doc.Find("head"), []string{TitleH1}); got != "Acme" {
+		t.Errorf("pickTitle outside content = %q, want %q", got, "Acme")
+	}
+	// Empty sources fall through
+	bare, _ := goquery.NewDocumentFromReader(strings.NewReader("<p>text</p>"))
+	if got := pickTitle(bare, bare.Selection, nil); got != "" {
+		t.Errorf("pickTitle with no title = %q", got)
+	}
+}
+
+func TestValidateTitleFrom(t *testing.T) {
+	if err := ValidateTitleFrom([]string{TitleH1, TitleOG, TitleElement}); err != nil {
+		t.Error(err)
+	}
+	if err := ValidateTitleFrom([]string{"h2"}); err == nil || !strings.Contains(err.Error(), `"h2"`) {
+		t.Errorf("ValidateTitleFrom(h2) = %v", err)
+	}
+}
@@ -0,0 +1,134 @@
+package fetcher
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+)
+
+// DirFetcher serves URLs under a base URL from a local directory, such as the
+// build output of a static site generator. It never touches the network.
+type DirFetcher struct {
+	root *os.Root
+	base *url.URL
+}
+
+// NewDir creates a DirFetcher that maps URLs under baseURL to files in dir.
+// Files are opened through os.Root, so URLs cannot escape dir.
+func NewDir(dir, baseURL string) (*DirFetcher, error) {
+	base, err := url.Parse(baseURL)
+	if err != nil || base.Scheme == "" || base.Host == "" {
+		return nil, fmt.Errorf("invalid base URL %q", baseURL)
+	}
+	root, err := os.OpenRoot(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &DirFetcher{root: root, base: base}, nil
+}
+
+// Get reads the file for url. accept is ignored; the Content-Type is derived
+// from the file extension. Missing files are returned as errors.
+func (d *DirFetcher) Get(ctx context.Context, url string, accept string) (*Response, error) {
+	body, name, err := d.read(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make(http.Header)
+	ct := mime.TypeByExtension(path.Ext(name))
+	if ct == "" {
+		ct = http.DetectContentType(body)
+	}
+	header.Set("Content-Type", ct)
+
+	return &Response{
+		URL:        url,
+		StatusCode: http.StatusOK,
+		Header:     header,
+		Body:       body,
+	}, nil
+}
+
+// Open reads the file for url.
+func (d *DirFetcher) Open(ctx context.Context, url string) (io.ReadCloser, error) {
+	f, _, err := d.open(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Close releases the directory handle.
+func (d *DirFetcher) Close() error {
+	return d.root.Close()
+}
+
+func (d *DirFetcher) read(ctx context.Context, url string) ([]byte, string, error) {
+	f, name, err := d.open(ctx, url)
+	if err != nil {
+		return nil, "", err
+	}
+	defer f.Close()
+
+	body, err := io.ReadAll(f)
+	if err != nil {
+		return nil, "", fmt.Errorf("reading %s: %w", url, err)
+	}
+	return body, name, nil
+}
+
+func (d *DirFetcher) open(ctx context.Context, url string) (*os.File, string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, "", err
+	}
+	name, err := d.resolve(url)
+	if err != nil {
+		return nil, "", err
+	}
+	f, err := d.root.Open(name)
+	if err != nil {
+		return nil, "", fmt.Errorf("no file for %s: %w", url, err)
+	}
+	return f, name, nil
+}
+
+// resolve maps a URL to a slash-separated file name relative to the root.
+// Directory URLs map to their index.html, and extensionless URLs fall back
+// to name.html or name/index.html if no such file exists.
+func (d *DirFetcher) resolve(rawURL string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("parsing URL %q: %w", rawURL, err)
+	}
+	if !strings.EqualFold(u.Host, d.base.Host) {
+		return "", fmt.Errorf("%s is outside the base URL %s", rawURL, d.base)
+	}
+
+	basePath := strings.TrimSuffix(d.base.Path, "/") + "/"
+	rel, ok := strings.CutPrefix(u.Path, basePath)
+	if !ok && u.Path+"/" == basePath {
+		rel, ok = "", true
+	}
+	if !ok {
+		return "", fmt.Errorf("%s is outside the base URL %s", rawURL, d.base)
+	}
+
+	if rel == "" || strings.HasSuffix(rel, "/") {
+		return rel + "index.html", nil
+	}
+	if path.Ext(rel) == "" {
+		for _, candidate := range []string{rel, rel + ".html", rel + "/index.html"} {
+			if fi, err := d.root.Stat(candidate); err == nil && !fi.IsDir() {
+				return candidate, nil
+			}
+		}
+	}
+	return rel, nil
+}
@@ -0,0 +1,132 @@
+package fetcher
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newTestDir returns a DirFetcher over a directory holding files, keyed by
+// slash-separated name.
+func newTestDir(t *testing.T, baseURL string, files map[string]string) *DirFetcher {
+	t.Helper()
+	dir := t.TempDir()
+	for name, body := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	d, err := NewDir(dir, baseURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { d.Close() })
+	return d
+}
+
+func TestDirFetcherResolvesURLs(t *testing.T) {
+	d := newTestDir(t, "https://example.com/docs/", map[string]string{
+		"index.html":         "root",
+		"guide/index.html":   "guide",
+		"guide/install.html": "install",
+		"api.html":           "api",
+		"ref/index.html":     "ref",
+		"llms.txt":           "# Docs",
+	})
+	tests := []struct{ url, want string }{
+		{"https://example.com/docs", "root"},
+		{"https://example.com/docs/", "root"},
+		{"https://example.com/docs/guide/", "guide"},
+		{"https://example.com/docs/guide/install.html", "install"},
+		{"https://example.com/docs/guide/install", "install"},
+		{"https://EXAMPLE.com/docs/api", "api"},
+		{"https://example.com/docs/ref", "ref"},
+	}
+	for _, tt := range tests {
+		resp, err := d.Get(context.Background(), tt.url, "")
+		if err != nil {
+			t.Errorf("Get(%q): %v", tt.url, err)
+			continue
+		}
+		if string(resp.Body) != tt.want || resp.StatusCode != 200 || resp.URL != tt.url {
+			t.Errorf("Get(%q) = %d %q, want 200 %q", tt.url, resp.StatusCode, resp.Body, tt.want)
+		}
+	}
+}
+
+func TestDirFetcherContentType(t *testing.T) {
+	d := newTestDir(t, "https://example.com/", map[string]string{
+		"page.html": "<html></html>",
+		"notes.md":  "# Notes",
+		"data":      "<html><body>sniffed</body></html>",
+	})
+	tests := []struct{ url, want string }{
+		{"https://example.com/page.html", "text/html"},
+		{"https://example.com/notes.md", "text/markdown"},
+		{"https://example.com/data", "text/html"},
+	}
+	for _, tt := range tests {
+		resp, err := d.Get(context.Background(), tt.url, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := resp.ContentType(); got != tt.want {
+			t.Errorf("Get(%q) Content-Type = %q, want %q", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestDirFetcherRejectsOutsideURLs(t *testing.T) {
+	dir := t.TempDir()
+	secret := filepath.Join(filepath.Dir(dir), "secret.html")
+	os.WriteFile(secret, []byte("secret"), 0644)
+	t.Cleanup(func() { os.Remove(secret) })
+
+	d := newTestDir(t, "https://example.com/docs/", map[string]string{"index.html": "root"})
+	for _, u := range []string{
+		"https://other.example.com/docs/",
+		"https://example.com/blog/post",
+		"https://example.com/docsx",
+		"https://example.com/docs/../../secret.html",
+		"https://example.com/docs/missing.html",
+	} {
+		if resp, err := d.Get(context.Background(), u, ""); err == nil {
+			t.Errorf("Get(%q) = %q, want error", u, resp.Body)
+		}
+	}
+}
+
+func TestDirFetcherOpen(t *testing.T) {
+	d := newTestDir(t, "https://example.com/", map[string]string{"sitemap.xml": "<urlset></urlset>"})
+	rc, err := d.Open(context.Background(), "https://example.com/sitemap.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	data, _ := io.ReadAll(rc)
+	if string(data) != "<urlset></urlset>" {
+		t.Errorf("Open read %q", data)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := d.Open(ctx, "https://example.com/sitemap.xml"); err == nil {
+		t.Error("Open with a cancelled context: want error")
+	}
+}
+
+func TestNewDirValidates(t *testing.T) {
+	if _, err := NewDir(t.TempDir(), "/docs"); err == nil || !strings.Contains(err.Error(), "invalid base URL") {
+		t.Errorf("relative base URL: err = %v", err)
+	}
+	if _, err := NewDir(filepath.Join(t.TempDir(), "missing"), "https://example.com/"); err == nil {
+		t.Error("missing directory: want error")
+	}
+}
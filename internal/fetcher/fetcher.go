@@ -1,37 +1,191 @@
 package fetcher
 
 import (
+	"cmp"
 	"compress/gzip"
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"strings"
 	"time"
 )
 
+// Default timeouts, used for zero Options fields.
+const (
+	DefaultConnectTimeout = 10 * time.Second
+	DefaultHeaderTimeout  = 15 * time.Second
+	DefaultTimeout        = 30 * time.Second
+)
+
+// ErrBodyTooLarge is returned for responses larger than Options.MaxBodySize.
+var ErrBodyTooLarge = errors.New("response body too large")
+
+// StatusError is returned for responses with a non-2xx status. It carries
+// the response, body included, so wrappers such as WARCRecorder can record
+// it.
+type StatusError struct {
+	Response *Response
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("HTTP %d for %s", e.Response.StatusCode, e.Response.URL)
+}
+
+// Options configures a Fetcher.
+type Options struct {
+	UserAgent      string
+	DelayMS        int           // delay before each request
+	ConnectTimeout time.Duration // TCP connect and TLS handshake
+	HeaderTimeout  time.Duration // from sending the request to the response headers
+	Timeout        time.Duration // whole request, including reading the body
+	MaxBodySize    int64         // largest body read, after decompression; 0 = no limit
+}
+
 // Fetcher wraps an HTTP client with rate-limiting, User-Agent, and gzip support.
 type Fetcher struct {
-	client    *http.Client
-	userAgent string
-	delay     time.Duration
+	client      *http.Client
+	stream      *http.Client // for Open; no whole-request timeout
+	idle        time.Duration
+	userAgent   string
+	delay       time.Duration
+	maxBodySize int64
+}
+
+// Getter is implemented by anything that can fetch a URL. *Fetcher is the
+// HTTP implementation.
+type Getter interface {
+	Get(ctx context.Context, url string, accept string) (*Response, error)
+}
+
+// Opener is implemented by fetchers that can stream a response body instead
+// of buffering it.
+type Opener interface {
+	Open(ctx context.Context, url string) (io.ReadCloser, error)
+}
+
+// Response is a fetched document along with the response metadata callers
+// need to decide how to process it.
+type Response struct {
+	URL        string
+	StatusCode int
+	Header     http.Header
+	Body       []byte
 }
 
-// New creates a Fetcher with the given User-Agent and per-call delay.
-func New(userAgent string, delayMS int) *Fetcher {
+// ContentType returns the media type of the response without parameters,
+// lowercased (e.g. "text/html").
+func (r *Response) ContentType() string {
+	ct := r.Header.Get("Content-Type")
+	if i := strings.IndexByte(ct, ';'); i >= 0 {
+		ct = ct[:i]
+	}
+	return strings.ToLower(strings.TrimSpace(ct))
+}
+
+// New creates a Fetcher from opts.
+func New(opts Options) *Fetcher {
+	connect := cmp.Or(opts.ConnectTimeout, DefaultConnectTimeout)
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = (&net.Dialer{Timeout: connect, KeepAlive: 30 * time.Second}).DialContext
+	transport.TLSHandshakeTimeout = connect
+	transport.ResponseHeaderTimeout = cmp.Or(opts.HeaderTimeout, DefaultHeaderTimeout)
+	timeout := cmp.Or(opts.Timeout, DefaultTimeout)
+
 	return &Fetcher{
-		client: &http.Client{
-			Timeout: 30 * time.Second,
-		},
-		userAgent: userAgent,
-		delay:     time.Duration(delayMS) * time.Millisecond,
+		client:      &http.Client{Transport: transport, Timeout: timeout},
+		stream:      &http.Client{Transport: transport},
+		idle:        timeout,
+		userAgent:   opts.UserAgent,
+		delay:       time.Duration(opts.DelayMS) * time.Millisecond,
+		maxBodySize: opts.MaxBodySize,
 	}
 }
 
 // Fetch retrieves the body of the given URL. It automatically decompresses
 // gzip responses and URLs ending in .gz.
 func (f *Fetcher) Fetch(ctx context.Context, url string) ([]byte, error) {
-	time.Sleep(f.delay)
+	resp, err := f.Get(ctx, url, "")
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// Open retrieves the given URL and returns a reader over the decompressed
+// body, without buffering it. The caller must close the reader.
+//
+// A streamed body may take longer than Options.Timeout to consume, since
+// the caller reads it at its own pace. Instead, a read that waits longer
+// than Options.Timeout for data fails.
+func (f *Fetcher) Open(ctx context.Context, url string) (io.ReadCloser, error) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	resp, err := f.do(ctx, f.stream, url, "")
+	if err != nil {
+		cancel(nil)
+		return nil, err
+	}
+
+	stalled := fmt.Errorf("reading %s: no data for %s", url, f.idle)
+	idle := &idleBody{ReadCloser: resp.Body, ctx: ctx, cancel: cancel, timeout: f.idle, err: stalled}
+	idle.timer = time.AfterFunc(f.idle, func() { cancel(stalled) })
+	idle.timer.Stop()
+	resp.Body = idle
+
+	body, err := decompress(resp, url)
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	return f.limit(body, url), nil
+}
+
+// Get retrieves the given URL and returns the decompressed body together with
+// the status code and headers. accept, if non-empty, is sent as the Accept
+// header. Non-2xx responses are returned as a *StatusError.
+func (f *Fetcher) Get(ctx context.Context, url string, accept string) (*Response, error) {
+	resp, err := f.do(ctx, f.client, url, accept)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	reader, err := decompress(resp, url)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	body, err := io.ReadAll(f.limit(reader, url))
+	if err != nil {
+		if errors.Is(err, ErrBodyTooLarge) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("reading body from %s: %w", url, err)
+	}
+
+	return &Response{
+		URL:        url,
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header,
+		Body:       body,
+	}, nil
+}
+
+// do sends a rate-limited GET request with client and checks the status
+// code. The caller must close the response body.
+func (f *Fetcher) do(ctx context.Context, client *http.Client, url string, accept string) (*http.Response, error) {
+	if f.delay > 0 {
+		t := time.NewTimer(f.delay)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return nil, ctx.Err()
+		}
+	}
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
@@ -39,33 +193,124 @@ func (f *Fetcher) Fetch(ctx context.Context, url string) ([]byte, error) {
 	}
 	req.Header.Set("User-Agent", f.userAgent)
 	req.Header.Set("Accept-Encoding", "gzip")
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
 
-	resp, err := f.client.Do(req)
+	resp, err := client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("fetching %s: %w", url, err)
 	}
-	defer resp.Body.Close()
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return nil, fmt.Errorf("HTTP %d for %s", resp.StatusCode, url)
+		defer resp.Body.Close()
+		return nil, &StatusError{Response: f.errorResponse(resp, url)}
+	}
+	if f.maxBodySize > 0 && resp.ContentLength > f.maxBodySize {
+		resp.Body.Close()
+		return nil, f.tooLarge(url)
 	}
 
-	var reader io.Reader = resp.Body
+	return resp, nil
+}
 
-	// Decompress if gzip content-encoding or .gz URL
-	if resp.Header.Get("Content-Encoding") == "gzip" || strings.HasSuffix(url, ".gz") {
-		gz, err := gzip.NewReader(resp.Body)
-		if err != nil {
-			return nil, fmt.Errorf("decompressing gzip response from %s: %w", url, err)
-		}
-		defer gz.Close()
-		reader = gz
+// errorResponse reads a non-2xx response for a StatusError. The body is read
+// on a best-effort basis, since it is only kept for recording.
+func (f *Fetcher) errorResponse(resp *http.Response, url string) *Response {
+	var body []byte
+	if reader, err := decompress(resp, url); err == nil {
+		body, _ = io.ReadAll(f.limit(reader, url))
+	}
+	return &Response{URL: url, StatusCode: resp.StatusCode, Header: resp.Header, Body: body}
+}
+
+// limit caps the bytes read from body at the maximum body size. Reading past
+// it fails with ErrBodyTooLarge, so an oversize download is abandoned
+// instead of truncated.
+func (f *Fetcher) limit(body io.ReadCloser, url string) io.ReadCloser {
+	if f.maxBodySize <= 0 {
+		return body
 	}
+	return &limitedBody{ReadCloser: body, left: f.maxBodySize, err: f.tooLarge(url)}
+}
 
-	body, err := io.ReadAll(reader)
+func (f *Fetcher) tooLarge(url string) error {
+	return fmt.Errorf("%w: %s is over %d bytes", ErrBodyTooLarge, url, f.maxBodySize)
+}
+
+// limitedBody fails with err once more than left bytes have been read.
+type limitedBody struct {
+	io.ReadCloser
+	left int64
+	err  error
+}
+
+func (l *limitedBody) Read(p []byte) (int, error) {
+	if l.left < 0 {
+		return 0, l.err
+	}
+	// Read one byte past the limit to tell a body of exactly the limit from
+	// a larger one.
+	if int64(len(p)) > l.left+1 {
+		p = p[:l.left+1]
+	}
+	n, err := l.ReadCloser.Read(p)
+	l.left -= int64(n)
+	if l.left < 0 {
+		return 0, l.err
+	}
+	return n, err
+}
+
+// idleBody fails a read that waits longer than timeout for data by
+// cancelling the request with err. Time spent between reads doesn't count.
+type idleBody struct {
+	io.ReadCloser
+	ctx     context.Context
+	cancel  context.CancelCauseFunc
+	timer   *time.Timer
+	timeout time.Duration
+	err     error
+}
+
+func (b *idleBody) Read(p []byte) (int, error) {
+	b.timer.Reset(b.timeout)
+	n, err := b.ReadCloser.Read(p)
+	b.timer.Stop()
+	if err != nil && context.Cause(b.ctx) == b.err {
+		return n, b.err
+	}
+	return n, err
+}
+
+func (b *idleBody) Close() error {
+	b.timer.Stop()
+	b.cancel(nil)
+	return b.ReadCloser.Close()
+}
+
+// decompress wraps the response body in a gzip reader if the response is
+// gzip-encoded or the URL ends in .gz. Closing the returned reader closes
+// the response body.
+func decompress(resp *http.Response, url string) (io.ReadCloser, error) {
+	if resp.Header.Get("Content-Encoding") != "gzip" && !strings.HasSuffix(url, ".gz") {
+		return resp.Body, nil
+	}
+
+	gz, err := gzip.NewReader(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("reading body from %s: %w", url, err)
+		return nil, fmt.Errorf("decompressing gzip response from %s: %w", url, err)
 	}
+	return &gzipBody{Reader: gz, body: resp.Body}, nil
+}
+
+// gzipBody closes both the gzip reader and the underlying response body.
+type gzipBody struct {
+	*gzip.Reader
+	body io.ReadCloser
+}
 
-	return body, nil
+func (g *gzipBody) Close() error {
+	g.Reader.Close()
+	return g.body.Close()
 }
@@ -0,0 +1,190 @@
+package fetcher
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOpenOutlivesTimeoutWhileConsumerIsSlow(t *testing.T) {
+	body := strings.Repeat("<url><loc>https://example.com/</loc></url>\n", 1000)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, body)
+	}))
+	defer srv.Close()
+
+	f := New(Options{Timeout: 100 * time.Millisecond})
+	rc, err := f.Open(context.Background(), srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+
+	var got bytes.Buffer
+	buf := make([]byte, len(body)/4)
+	for {
+		n, err := rc.Read(buf)
+		got.Write(buf[:n])
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("read after %d bytes: %v", got.Len(), err)
+		}
+		time.Sleep(60 * time.Millisecond)
+	}
+	if got.String() != body {
+		t.Errorf("read %d bytes, want %d", got.Len(), len(body))
+	}
+}
+
+func TestOpenFailsWhenStreamStalls(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "<urlset>")
+		w.(http.Flusher).Flush()
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	f := New(Options{Timeout: 100 * time.Millisecond})
+	rc, err := f.Open(context.Background(), srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+
+	start := time.Now()
+	_, err = io.ReadAll(rc)
+	if err == nil || !strings.Contains(err.Error(), "no data for 100ms") {
+		t.Fatalf("ReadAll error = %v, want a stall error", err)
+	}
+	if d := time.Since(start); d > 2*time.Second {
+		t.Errorf("stall detected after %s", d)
+	}
+}
+
+func TestOpenDecompressesGzip(t *testing.T) {
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	io.WriteString(zw, "<urlset></urlset>")
+	zw.Close()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(gz.Bytes())
+	}))
+	defer srv.Close()
+
+	rc, err := New(Options{}).Open(context.Background(), srv.URL+"/sitemap.xml.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(rc)
+	if err != nil || string(data) != "<urlset></urlset>" {
+		t.Errorf("ReadAll = %q, %v", data, err)
+	}
+}
+
+func TestGetIsBoundByTimeout(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "<html>")
+		w.(http.Flusher).Flush()
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	f := New(Options{Timeout: 100 * time.Millisecond})
+	if _, err := f.Get(context.Background(), srv.URL, ""); err == nil {
+		t.Fatal("Get of a body that never finishes succeeded")
+	}
+}
+
+func TestMaxBodySize(t *testing.T) {
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	io.WriteString(zw, strings.Repeat("x", 100))
+	zw.Close()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/exact":
+			io.WriteString(w, strings.Repeat("x", 10))
+		case "/declared":
+			w.Header().Set("Content-Length", "11")
+			io.WriteString(w, strings.Repeat("x", 11))
+		case "/chunked":
+			// No Content-Length; the limit is found while reading
+			for range 11 {
+				io.WriteString(w, "x")
+				w.(http.Flusher).Flush()
+			}
+		case "/bomb.gz":
+			// Small on the wire, over the limit once decompressed
+			w.Write(gz.Bytes())
+		}
+	}))
+	defer srv.Close()
+
+	f := New(Options{MaxBodySize: 10})
+	resp, err := f.Get(context.Background(), srv.URL+"/exact", "")
+	if err != nil || len(resp.Body) != 10 {
+		t.Fatalf("body of exactly the limit: %v", err)
+	}
+	for _, path := range []string{"/declared", "/chunked", "/bomb.gz"} {
+		if _, err := f.Get(context.Background(), srv.URL+path, ""); !errors.Is(err, ErrBodyTooLarge) {
+			t.Errorf("Get %s error = %v, want ErrBodyTooLarge", path, err)
+		}
+		rc, err := f.Open(context.Background(), srv.URL+path)
+		if err == nil {
+			_, err = io.ReadAll(rc)
+			rc.Close()
+		}
+		if !errors.Is(err, ErrBodyTooLarge) {
+			t.Errorf("Open %s error = %v, want ErrBodyTooLarge", path, err)
+		}
+	}
+
+	// No limit by default
+	if resp, err := New(Options{}).Get(context.Background(), srv.URL+"/bomb.gz", ""); err != nil || len(resp.Body) != 100 {
+		t.Errorf("unlimited Get = %v", err)
+	}
+}
+
+func TestLimitedBody(t *testing.T) {
+	tests := []struct {
+		body    string
+		limit   int64
+		wantErr bool
+	}{
+		{"", 0, false},
+		{"abc", 3, false},
+		{"abcd", 3, true},
+		{"a", 0, true},
+	}
+	for _, tt := range tests {
+		l := &limitedBody{ReadCloser: io.NopCloser(strings.NewReader(tt.body)), left: tt.limit, err: ErrBodyTooLarge}
+		data, err := io.ReadAll(l)
+		if got := errors.Is(err, ErrBodyTooLarge); got != tt.wantErr {
+			t.Errorf("%q with limit %d: err = %v", tt.body, tt.limit, err)
+		}
+		if !tt.wantErr && string(data) != tt.body {
+			t.Errorf("%q with limit %d: read %q", tt.body, tt.limit, data)
+		}
+	}
+}
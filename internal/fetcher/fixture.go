@@ -0,0 +1,187 @@
+package fetcher
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"unicode/utf8"
+)
+
+// fixture is the on-disk form of a recorded response. Text bodies are stored
+// as-is so fixtures can be read and diffed; binary bodies are base64-encoded.
+type fixture struct {
+	URL        string      `json:"url"`
+	Accept     string      `json:"accept,omitempty"`
+	StatusCode int         `json:"status"`
+	Header     http.Header `json:"header"`
+	Body       string      `json:"body,omitempty"`
+	BodyBase64 string      `json:"body_base64,omitempty"`
+}
+
+// fixtureName returns the file name a URL and Accept header are stored under.
+func fixtureName(url, accept string) string {
+	key := url
+	if accept != "" {
+		key += "\n" + accept
+	}
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:8]) + ".json"
+}
+
+// FixtureRecorder wraps a Getter and saves every response, including non-2xx
+// ones, to a directory, one JSON file per URL and Accept header.
+type FixtureRecorder struct {
+	inner Getter
+	dir   string
+}
+
+// NewFixtureRecorder creates dir if needed and records into it.
+func NewFixtureRecorder(inner Getter, dir string) (*FixtureRecorder, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &FixtureRecorder{inner: inner, dir: dir}, nil
+}
+
+// Get fetches url through the wrapped Getter and saves the response.
+func (r *FixtureRecorder) Get(ctx context.Context, url string, accept string) (*Response, error) {
+	resp, err := r.inner.Get(ctx, url, accept)
+	var statusErr *StatusError
+	switch {
+	case err == nil:
+	case errors.As(err, &statusErr):
+		resp = statusErr.Response
+	default:
+		return nil, err
+	}
+	if saveErr := r.save(resp, accept); saveErr != nil {
+		return nil, fmt.Errorf("recording %s: %w", url, saveErr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// Open fetches and records url. The body is buffered so it can be recorded.
+func (r *FixtureRecorder) Open(ctx context.Context, url string) (io.ReadCloser, error) {
+	resp, err := r.Get(ctx, url, "")
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(resp.Body)), nil
+}
+
+func (r *FixtureRecorder) save(resp *Response, accept string) error {
+	header := resp.Header.Clone()
+	header.Del("Content-Encoding")
+	header.Del("Content-Length")
+	header.Del("Date")
+
+	fx := fixture{URL: resp.URL, Accept: accept, StatusCode: resp.StatusCode, Header: header}
+	if utf8.Valid(resp.Body) {
+		fx.Body = string(resp.Body)
+	} else {
+		fx.BodyBase64 = base64.StdEncoding.EncodeToString(resp.Body)
+	}
+
+	var data bytes.Buffer
+	enc := json.NewEncoder(&data)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(fx); err != nil {
+		return err
+	}
+
+	// Write to a temp file and rename so concurrent workers never leave a
+	// partial fixture behind.
+	tmp, err := os.CreateTemp(r.dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data.Bytes()); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(r.dir, fixtureName(resp.URL, accept)))
+}
+
+// FixtureReplayer serves every fetch from a directory written by
+// FixtureRecorder. A request with no fixture fails, so a replayed run never
+// silently depends on the network.
+type FixtureReplayer struct {
+	dir string
+}
+
+// NewFixtureReplayer replays from dir, which must exist.
+func NewFixtureReplayer(dir string) (*FixtureReplayer, error) {
+	fi, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+	if !fi.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", dir)
+	}
+	return &FixtureReplayer{dir: dir}, nil
+}
+
+// Get returns the recorded response for url and accept. Recorded non-2xx
+// responses are returned as a *StatusError, like Fetcher.Get does.
+func (r *FixtureReplayer) Get(ctx context.Context, url string, accept string) (*Response, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(filepath.Join(r.dir, fixtureName(url, accept)))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("no fixture for %s in %s", url, r.dir)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var fx fixture
+	if err := json.Unmarshal(data, &fx); err != nil {
+		return nil, fmt.Errorf("reading fixture for %s: %w", url, err)
+	}
+	body := []byte(fx.Body)
+	if fx.BodyBase64 != "" {
+		if body, err = base64.StdEncoding.DecodeString(fx.BodyBase64); err != nil {
+			return nil, fmt.Errorf("reading fixture for %s: %w", url, err)
+		}
+	}
+	resp := &Response{
+		URL:        url,
+		StatusCode: fx.StatusCode,
+		Header:     fx.Header,
+		Body:       body,
+	}
+	if fx.StatusCode < 200 || fx.StatusCode >= 300 {
+		return nil, &StatusError{Response: resp}
+	}
+	return resp, nil
+}
+
+// Open returns a reader over the recorded body of url.
+func (r *FixtureReplayer) Open(ctx context.Context, url string) (io.ReadCloser, error) {
+	resp, err := r.Get(ctx, url, "")
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(resp.Body)), nil
+}
@@ -0,0 +1,130 @@
+package fetcher
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFixtureRoundTrip(t *testing.T) {
+	srv := testSite(t)
+	dir := filepath.Join(t.TempDir(), "fixtures")
+	rec, err := NewFixtureRecorder(New(Options{}), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	live, err := rec.Get(ctx, srv.URL+"/page", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	liveMD, err := rec.Get(ctx, srv.URL+"/page", "text/markdown")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, liveErr := rec.Get(ctx, srv.URL+"/page.md", "")
+	if liveErr == nil {
+		t.Fatal("live 404: want error")
+	}
+
+	replay, err := NewFixtureReplayer(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Responses are keyed by URL and Accept header
+	for _, tt := range []struct {
+		accept string
+		want   *Response
+	}{{"", live}, {"text/markdown", liveMD}} {
+		got, err := replay.Get(ctx, srv.URL+"/page", tt.accept)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got.Body) != string(tt.want.Body) || got.StatusCode != 200 || got.ContentType() != "text/html" {
+			t.Errorf("replay with Accept %q = %d %q %q, want %q", tt.accept, got.StatusCode, got.ContentType(), got.Body, tt.want.Body)
+		}
+	}
+
+	_, replayErr := replay.Get(ctx, srv.URL+"/page.md", "")
+	var statusErr *StatusError
+	if !errors.As(replayErr, &statusErr) || replayErr.Error() != liveErr.Error() {
+		t.Fatalf("replayed 404: err = %v, want %v", replayErr, liveErr)
+	}
+	if statusErr.Response.StatusCode != 404 || statusErr.Response.ContentType() != "text/html" ||
+		!strings.Contains(string(statusErr.Response.Body), "Not found") {
+		t.Errorf("replayed 404 response = %+v", statusErr.Response)
+	}
+
+	if _, err := replay.Get(ctx, srv.URL+"/page", "application/json"); err == nil || !strings.Contains(err.Error(), "no fixture") {
+		t.Errorf("unrecorded Accept header: err = %v", err)
+	}
+}
+
+func TestFixtureFileFormat(t *testing.T) {
+	dir := t.TempDir()
+	rec, err := NewFixtureRecorder(memGetter{
+		"https://example.com/text": {
+			URL: "https://example.com/text", StatusCode: 200,
+			Header: http.Header{"Content-Type": {"text/plain"}, "Date": {"today"}, "Content-Encoding": {"gzip"}},
+			Body:   []byte("<p>hello & goodbye</p>"),
+		},
+		"https://example.com/image": {
+			URL: "https://example.com/image", StatusCode: 200,
+			Header: http.Header{"Content-Type": {"image/png"}},
+			Body:   []byte{0x89, 'P', 'N', 'G', 0xff, 0x00},
+		},
+	}, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	rec.Get(ctx, "https://example.com/text", "")
+	rec.Get(ctx, "https://example.com/image", "")
+
+	var fx fixture
+	data, err := os.ReadFile(filepath.Join(dir, fixtureName("https://example.com/text", "")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"body": "<p>hello & goodbye</p>"`) {
+		t.Errorf("text body is not stored readably:\n%s", data)
+	}
+	json.Unmarshal(data, &fx)
+	if fx.Header.Get("Date") != "" || fx.Header.Get("Content-Encoding") != "" {
+		t.Errorf("volatile headers were saved: %v", fx.Header)
+	}
+
+	replay, _ := NewFixtureReplayer(dir)
+	img, err := replay.Get(ctx, "https://example.com/image", "")
+	if err != nil || string(img.Body) != string([]byte{0x89, 'P', 'N', 'G', 0xff, 0x00}) {
+		t.Errorf("binary body = %v, %v", img.Body, err)
+	}
+}
+
+func TestNewFixtureReplayerValidates(t *testing.T) {
+	if _, err := NewFixtureReplayer(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("missing directory: want error")
+	}
+	file := filepath.Join(t.TempDir(), "file")
+	os.WriteFile(file, nil, 0644)
+	if _, err := NewFixtureReplayer(file); err == nil {
+		t.Error("file instead of directory: want error")
+	}
+}
+
+// memGetter serves canned responses by URL.
+type memGetter map[string]*Response
+
+func (m memGetter) Get(_ context.Context, url string, _ string) (*Response, error) {
+	if resp, ok := m[url]; ok {
+		return resp, nil
+	}
+	return nil, errors.New("not found")
+}
@@ -0,0 +1,355 @@
+package fetcher
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/textproto"
+	neturl "net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WARCRecorder wraps a Getter and appends every request that got a response
+// to a WARC file, together with the response, including non-2xx ones. Paths
+// ending in .gz are written as one gzip member per record, as is
+// conventional for .warc.gz files. Bodies are stored decompressed.
+type WARCRecorder struct {
+	inner     Getter
+	userAgent string
+
+	mu   sync.Mutex
+	file *os.File
+	gzip bool
+}
+
+// NewWARCRecorder creates path and starts it with a warcinfo record.
+// userAgent is recorded in the request records.
+func NewWARCRecorder(inner Getter, path, userAgent string) (*WARCRecorder, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	r := &WARCRecorder{
+		inner:     inner,
+		userAgent: userAgent,
+		file:      f,
+		gzip:      strings.HasSuffix(path, ".gz"),
+	}
+
+	info := "software: docs-cloner\r\nformat: WARC File Format 1.1\r\n"
+	err = r.writeRecord(warcHeader{
+		"WARC-Type":     "warcinfo",
+		"WARC-Filename": filepath.Base(path),
+		"Content-Type":  "application/warc-fields",
+	}, []byte(info))
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+// Get fetches url through the wrapped Getter and records the exchange.
+func (r *WARCRecorder) Get(ctx context.Context, url string, accept string) (*Response, error) {
+	resp, err := r.inner.Get(ctx, url, accept)
+	var statusErr *StatusError
+	switch {
+	case err == nil:
+	case errors.As(err, &statusErr):
+		resp = statusErr.Response
+	default:
+		return nil, err
+	}
+	if recErr := r.record(resp, accept); recErr != nil {
+		return nil, fmt.Errorf("recording %s: %w", url, recErr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// Open fetches and records url. The body is buffered so it can be recorded.
+func (r *WARCRecorder) Open(ctx context.Context, url string) (io.ReadCloser, error) {
+	resp, err := r.Get(ctx, url, "")
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(resp.Body)), nil
+}
+
+// Close closes the WARC file.
+func (r *WARCRecorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.file.Close()
+}
+
+// record writes a request record and the matching response record.
+func (r *WARCRecorder) record(resp *Response, accept string) error {
+	now := time.Now().UTC().Format(time.RFC3339)
+	reqID, respID := newRecordID(), newRecordID()
+
+	var req bytes.Buffer
+	target := resp.URL
+	host := ""
+	if u, err := neturl.Parse(resp.URL); err == nil {
+		target = u.RequestURI()
+		host = u.Host
+	}
+	fmt.Fprintf(&req, "GET %s HTTP/1.1\r\nHost: %s\r\nUser-Agent: %s\r\n", target, host, r.userAgent)
+	if accept != "" {
+		fmt.Fprintf(&req, "Accept: %s\r\n", accept)
+	}
+	req.WriteString("\r\n")
+
+	header := resp.Header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	header.Del("Content-Encoding")
+	header.Del("Transfer-Encoding")
+	header.Set("Content-Length", strconv.Itoa(len(resp.Body)))
+
+	var block bytes.Buffer
+	fmt.Fprintf(&block, "HTTP/1.1 %d %s\r\n", resp.StatusCode, http.StatusText(resp.StatusCode))
+	header.Write(&block)
+	block.WriteString("\r\n")
+	block.Write(resp.Body)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	err := r.writeRecord(warcHeader{
+		"WARC-Type":           "response",
+		"WARC-Record-ID":      respID,
+		"WARC-Date":           now,
+		"WARC-Target-URI":     resp.URL,
+		"Content-Type":        "application/http;msgtype=response",
+		"WARC-Payload-Digest": warcDigest(resp.Body),
+	}, block.Bytes())
+	if err != nil {
+		return err
+	}
+	return r.writeRecord(warcHeader{
+		"WARC-Type":          "request",
+		"WARC-Record-ID":     reqID,
+		"WARC-Date":          now,
+		"WARC-Target-URI":    resp.URL,
+		"WARC-Concurrent-To": respID,
+		"Content-Type":       "application/http;msgtype=request",
+	}, req.Bytes())
+}
+
+// warcHeader holds the named fields of a WARC record header.
+type warcHeader map[string]string
+
+// warcFieldOrder is the order header fields are written in. The digest and
+// length fields are computed and always come last.
+var warcFieldOrder = []string{
+	"WARC-Type", "WARC-Record-ID", "WARC-Date", "WARC-Target-URI",
+	"WARC-Concurrent-To", "WARC-Filename", "Content-Type", "WARC-Payload-Digest",
+}
+
+// writeRecord writes a single WARC record. The caller must hold r.mu, except
+// during construction.
+func (r *WARCRecorder) writeRecord(h warcHeader, block []byte) error {
+	if h["WARC-Record-ID"] == "" {
+		h["WARC-Record-ID"] = newRecordID()
+	}
+	if h["WARC-Date"] == "" {
+		h["WARC-Date"] = time.Now().UTC().Format(time.RFC3339)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("WARC/1.1\r\n")
+	for _, k := range warcFieldOrder {
+		if v := h[k]; v != "" {
+			fmt.Fprintf(&buf, "%s: %s\r\n", k, v)
+		}
+	}
+	fmt.Fprintf(&buf, "WARC-Block-Digest: %s\r\n", warcDigest(block))
+	fmt.Fprintf(&buf, "Content-Length: %d\r\n\r\n", len(block))
+	buf.Write(block)
+	buf.WriteString("\r\n\r\n")
+
+	if !r.gzip {
+		_, err := r.file.Write(buf.Bytes())
+		return err
+	}
+	gz := gzip.NewWriter(r.file)
+	if _, err := gz.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// WARCReplayer serves responses recorded in a WARC file instead of fetching
+// them. Only response records are used; the first record for a URL wins.
+// Requests for URLs that are not in the file fail.
+type WARCReplayer struct {
+	responses map[string]*Response
+}
+
+// LoadWARC reads every response record from a .warc or .warc.gz file.
+func LoadWARC(path string) (*WARCReplayer, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	br := bufio.NewReader(f)
+	var src io.Reader = br
+	if magic, _ := br.Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", path, err)
+		}
+		defer gz.Close()
+		src = gz
+	}
+
+	r := &WARCReplayer{responses: make(map[string]*Response)}
+	if err := r.load(bufio.NewReader(src)); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	return r, nil
+}
+
+// Len returns the number of URLs available for replay.
+func (r *WARCReplayer) Len() int {
+	return len(r.responses)
+}
+
+// Get returns the recorded response for url. accept is ignored. Recorded
+// non-2xx responses are returned as a *StatusError, like Fetcher.Get does.
+func (r *WARCReplayer) Get(ctx context.Context, url string, accept string) (*Response, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	resp, ok := r.responses[url]
+	if !ok {
+		return nil, fmt.Errorf("%s is not in the WARC file", url)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, &StatusError{Response: resp}
+	}
+	return resp, nil
+}
+
+// Open returns a reader over the recorded body of url.
+func (r *WARCReplayer) Open(ctx context.Context, url string) (io.ReadCloser, error) {
+	resp, err := r.Get(ctx, url, "")
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(resp.Body)), nil
+}
+
+// load reads records until the end of the stream.
+func (r *WARCReplayer) load(br *bufio.Reader) error {
+	tp := textproto.NewReader(br)
+	for {
+		version, err := tp.ReadLine()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if version == "" {
+			continue // padding between records
+		}
+		if !strings.HasPrefix(version, "WARC/") {
+			return fmt.Errorf("unexpected line %q, want a WARC record", version)
+		}
+
+		h, err := tp.ReadMIMEHeader()
+		if err != nil {
+			return err
+		}
+		n, err := strconv.ParseInt(h.Get("Content-Length"), 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid WARC Content-Length %q", h.Get("Content-Length"))
+		}
+		block := make([]byte, n)
+		if _, err := io.ReadFull(br, block); err != nil {
+			return err
+		}
+
+		if h.Get("Warc-Type") != "response" {
+			continue
+		}
+		target := strings.Trim(h.Get("Warc-Target-Uri"), "<>")
+		if _, seen := r.responses[target]; seen || !strings.HasPrefix(h.Get("Content-Type"), "application/http") {
+			continue
+		}
+		resp, err := parseHTTPResponse(target, block)
+		if err != nil {
+			continue // not an HTTP response we can use; skip it
+		}
+		r.responses[target] = resp
+	}
+}
+
+// parseHTTPResponse decodes a WARC response block, undoing chunked and gzip
+// encodings so the body matches what Fetcher.Get returns.
+func parseHTTPResponse(url string, block []byte) (*Response, error) {
+	httpResp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(block)), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer httpResp.Body.Close()
+
+	body, err := io.ReadAll(httpResp.Body)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	isGzip := httpResp.Header.Get("Content-Encoding") == "gzip" || strings.HasSuffix(url, ".gz")
+	if isGzip && len(body) > 2 && body[0] == 0x1f && body[1] == 0x8b {
+		gz, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		if body, err = io.ReadAll(gz); err != nil {
+			return nil, err
+		}
+		httpResp.Header.Del("Content-Encoding")
+	}
+
+	return &Response{
+		URL:        url,
+		StatusCode: httpResp.StatusCode,
+		Header:     httpResp.Header,
+		Body:       body,
+	}, nil
+}
+
+// warcDigest returns a WARC sha1 digest of data.
+func warcDigest(data []byte) string {
+	sum := sha1.Sum(data)
+	return "sha1:" + base32.StdEncoding.EncodeToString(sum[:])
+}
+
+// newRecordID returns a random urn:uuid record ID.
+func newRecordID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("<urn:uuid:%x-%x-%x-%x-%x>", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
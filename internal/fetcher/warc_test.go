@@ -0,0 +1,151 @@
+package fetcher
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testSite serves a page, a gzip-encoded page, and 404s with a body for
+// everything else.
+func testSite(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/page":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Write([]byte("<html><body>Page, accept=" + r.Header.Get("Accept") + "</body></html>"))
+		case "/gzipped":
+			var buf bytes.Buffer
+			zw := gzip.NewWriter(&buf)
+			zw.Write([]byte("<html><body>Compressed</body></html>"))
+			zw.Close()
+			w.Header().Set("Content-Type", "text/html")
+			w.Header().Set("Content-Encoding", "gzip")
+			w.Write(buf.Bytes())
+		default:
+			w.Header().Set("Content-Type", "text/html")
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte("<html><body>Not found</body></html>"))
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestWARCRoundTrip(t *testing.T) {
+	for _, name := range []string{"crawl.warc", "crawl.warc.gz"} {
+		t.Run(name, func(t *testing.T) {
+			srv := testSite(t)
+			path := filepath.Join(t.TempDir(), name)
+			rec, err := NewWARCRecorder(New(Options{}), path, "test-agent")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ctx := context.Background()
+			live, err := rec.Get(ctx, srv.URL+"/page", "text/markdown")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := rec.Get(ctx, srv.URL+"/gzipped", ""); err != nil {
+				t.Fatal(err)
+			}
+			_, liveErr := rec.Get(ctx, srv.URL+"/page.md", "")
+			var statusErr *StatusError
+			if !errors.As(liveErr, &statusErr) || statusErr.Response.StatusCode != 404 {
+				t.Fatalf("live 404: err = %v, want a *StatusError", liveErr)
+			}
+			if err := rec.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			replay, err := LoadWARC(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if replay.Len() != 3 {
+				t.Errorf("Len() = %d, want 3", replay.Len())
+			}
+
+			got, err := replay.Get(ctx, srv.URL+"/page", "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got.Body) != string(live.Body) || got.ContentType() != "text/html" || got.StatusCode != 200 {
+				t.Errorf("replayed page = %d %q %q, want %q", got.StatusCode, got.ContentType(), got.Body, live.Body)
+			}
+			if !strings.Contains(string(got.Body), "accept=text/markdown") {
+				t.Errorf("replayed body %q does not reflect the recorded Accept header", got.Body)
+			}
+
+			got, err = replay.Get(ctx, srv.URL+"/gzipped", "")
+			if err != nil || string(got.Body) != "<html><body>Compressed</body></html>" {
+				t.Errorf("replayed gzip page = %q, %v", got.Body, err)
+			}
+
+			_, replayErr := replay.Get(ctx, srv.URL+"/page.md", "")
+			if !errors.As(replayErr, &statusErr) || replayErr.Error() != liveErr.Error() {
+				t.Errorf("replayed 404: err = %v, want %v", replayErr, liveErr)
+			}
+			if body := string(statusErr.Response.Body); !strings.Contains(body, "Not found") {
+				t.Errorf("replayed 404 body = %q", body)
+			}
+
+			if _, err := replay.Get(ctx, srv.URL+"/unrecorded", ""); err == nil || !strings.Contains(err.Error(), "not in the WARC file") {
+				t.Errorf("unrecorded URL: err = %v", err)
+			}
+		})
+	}
+}
+
+func TestWARCRecordFormat(t *testing.T) {
+	srv := testSite(t)
+	path := filepath.Join(t.TempDir(), "crawl.warc")
+	rec, err := NewWARCRecorder(New(Options{}), path, "test-agent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rec.Get(context.Background(), srv.URL+"/page?x=1", "text/markdown"); err != nil {
+		t.Fatal(err)
+	}
+	rec.Close()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	warc := string(data)
+	for _, want := range []string{
+		"WARC-Type: warcinfo",
+		"WARC-Filename: crawl.warc",
+		"WARC-Type: response",
+		"WARC-Target-URI: " + srv.URL + "/page?x=1",
+		"Content-Type: application/http;msgtype=response",
+		"WARC-Type: request",
+		"WARC-Concurrent-To: <urn:uuid:",
+		"GET /page?x=1 HTTP/1.1\r\n",
+		"User-Agent: test-agent\r\n",
+		"Accept: text/markdown\r\n",
+		"HTTP/1.1 200 OK\r\n",
+	} {
+		if !strings.Contains(warc, want) {
+			t.Errorf("WARC file is missing %q", want)
+		}
+	}
+}
+
+func TestLoadWARCRejectsOtherFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "not.warc")
+	os.WriteFile(path, []byte("<html></html>\n"), 0644)
+	if _, err := LoadWARC(path); err == nil {
+		t.Error("LoadWARC of an HTML file: want error")
+	}
+}
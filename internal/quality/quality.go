@@ -0,0 +1,88 @@
+// Package quality flags converted pages that are probably not real content:
+// empty or near-empty pages, soft 404s and JavaScript-required notices, and
+// extractions that caught only a sliver of the page.
+package quality
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DefaultPhrases are soft-404 and placeholder phrases checked in short pages.
+var DefaultPhrases = []string{
+	"page not found",
+	"404 not found",
+	"this page could not be found",
+	"the page you are looking for",
+	"page does not exist",
+	"page doesn't exist",
+	"no longer exists",
+	"loading...",
+	"please enable javascript",
+	"javascript is required",
+	"you need to enable javascript",
+	"javascript must be enabled",
+	"requires javascript",
+}
+
+// phraseMaxWords is the length above which pages aren't checked for
+// phrases; real pages can mention "page not found" in passing.
+const phraseMaxWords = 150
+
+// ratioMinPageWords is the page length below which the text ratio isn't
+// checked, since short pages are mostly navigation.
+const ratioMinPageWords = 100
+
+// Options configures the checks. A zero value disables a check.
+type Options struct {
+	MinWords     int      // minimum words in the converted body
+	MinTextRatio float64  // minimum share of the page's words that were extracted
+	Phrases      []string // lowercase soft-404 phrases; nil = none
+}
+
+// Checker runs the checks over converted pages. It keeps no state, so it is
+// safe for concurrent use.
+type Checker struct {
+	opts Options
+}
+
+// New returns a Checker with the given options.
+func New(opts Options) *Checker {
+	return &Checker{opts: opts}
+}
+
+// Page is what the checks look at.
+type Page struct {
+	URL       string
+	Title     string
+	Markdown  string // converted body, without frontmatter
+	PageWords int    // words in the whole HTML page; 0 if not converted from HTML
+}
+
+// Check returns the checks p fails, as human-readable reasons, or nil.
+func (c *Checker) Check(p Page) []string {
+	var issues []string
+	words := len(strings.Fields(p.Markdown))
+
+	if c.opts.MinWords > 0 && words < c.opts.MinWords {
+		issues = append(issues, fmt.Sprintf("too short (%d words, minimum %d)", words, c.opts.MinWords))
+	}
+
+	if words <= phraseMaxWords {
+		text := strings.ToLower(p.Title + "\n" + p.Markdown)
+		for _, phrase := range c.opts.Phrases {
+			if strings.Contains(text, phrase) {
+				issues = append(issues, fmt.Sprintf("soft 404 (%q)", phrase))
+				break
+			}
+		}
+	}
+
+	if c.opts.MinTextRatio > 0 && p.PageWords >= ratioMinPageWords {
+		ratio := float64(words) / float64(p.PageWords)
+		if ratio < c.opts.MinTextRatio {
+			issues = append(issues, fmt.Sprintf("low text ratio (%.2f of the page, minimum %.2f)", ratio, c.opts.MinTextRatio))
+		}
+	}
+	return issues
+}
@@ -0,0 +1,70 @@
+package quality
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	long := strings.Repeat("word ", 200)
+	c := New(Options{MinWords: 5, MinTextRatio: 0.2, Phrases: DefaultPhrases})
+
+	tests := []struct {
+		name string
+		page Page
+		want []string
+	}{
+		{
+			name: "good page",
+			page: Page{Title: "Install", Markdown: "Run the installer and follow the prompts.", PageWords: 50},
+		},
+		{
+			name: "too short",
+			page: Page{Title: "Install", Markdown: "Coming soon"},
+			want: []string{"too short (2 words, minimum 5)"},
+		},
+		{
+			name: "soft 404 in the title",
+			page: Page{Title: "Page Not Found", Markdown: "Try the search box to find what you need."},
+			want: []string{`soft 404 ("page not found")`},
+		},
+		{
+			name: "javascript notice",
+			page: Page{Title: "App", Markdown: "Loading... Please enable JavaScript to view this site."},
+			want: []string{`soft 404 ("loading...")`},
+		},
+		{
+			name: "phrase in a long page ignored",
+			page: Page{Title: "Errors", Markdown: "A page not found error means " + long},
+		},
+		{
+			name: "low text ratio",
+			page: Page{Title: "API", Markdown: "Only the first sentence was extracted here.", PageWords: 400},
+			want: []string{"low text ratio (0.02 of the page, minimum 0.20)"},
+		},
+		{
+			name: "ratio skipped for short pages",
+			page: Page{Title: "API", Markdown: "Only a short sentence here.", PageWords: 99},
+		},
+		{
+			name: "several issues",
+			page: Page{Title: "404", Markdown: "404 Not Found", PageWords: 120},
+			want: []string{"too short (3 words, minimum 5)", `soft 404 ("404 not found")`, "low text ratio (0.03 of the page, minimum 0.20)"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := c.Check(tt.page)
+			if strings.Join(got, "; ") != strings.Join(tt.want, "; ") {
+				t.Errorf("Check = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckDisabled(t *testing.T) {
+	c := New(Options{})
+	if got := c.Check(Page{Title: "Page not found", Markdown: "", PageWords: 1000}); got != nil {
+		t.Errorf("Check with zero options = %q, want nil", got)
+	}
+}
@@ -0,0 +1,191 @@
+// Package render obtains the DOM of a page after its JavaScript has run,
+// through an external command (a headless browser) or an HTTP rendering
+// service. docs-cloner doesn't bundle a browser.
+package render
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Timeout bounds a single render.
+const Timeout = 60 * time.Second
+
+// waitDelay is how long a render command's output is waited for after the
+// command is killed. A wrapper script's browser can outlive it and keep
+// stdout open.
+const waitDelay = time.Second
+
+// URLEnv is the environment variable a render command receives the page URL
+// in, for scripts that would rather not take it as an argument.
+const URLEnv = "DOCS_CLONER_URL"
+
+// Command renders pages by running a command that prints the rendered DOM
+// to standard output, such as "chromium --headless --dump-dom {url}".
+type Command struct {
+	args []string
+}
+
+// NewCommand parses cmdline into arguments, honoring single and double
+// quotes and backslash escapes. No shell is involved. "{url}" in any argument
+// is replaced with the page URL; without it, the URL is appended as the last
+// argument.
+func NewCommand(cmdline string) (*Command, error) {
+	args, err := splitArgs(cmdline)
+	if err != nil {
+		return nil, err
+	}
+	if len(args) == 0 {
+		return nil, fmt.Errorf("empty render command")
+	}
+	if !strings.Contains(cmdline, "{url}") {
+		args = append(args, "{url}")
+	}
+	return &Command{args: args}, nil
+}
+
+// Render runs the command for pageURL and returns its output.
+func (c *Command) Render(ctx context.Context, pageURL string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, Timeout)
+	defer cancel()
+
+	args := make([]string, len(c.args))
+	for i, a := range c.args {
+		args[i] = strings.ReplaceAll(a, "{url}", pageURL)
+	}
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Env = append(os.Environ(), URLEnv+"="+pageURL)
+	cmd.WaitDelay = waitDelay
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := lastLine(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%s: %w: %s", args[0], err, msg)
+		}
+		return nil, fmt.Errorf("%s: %w", args[0], err)
+	}
+	if stdout.Len() == 0 {
+		return nil, fmt.Errorf("%s printed nothing", args[0])
+	}
+	return stdout.Bytes(), nil
+}
+
+// Endpoint renders pages through an HTTP rendering service. If the endpoint
+// contains "{url}", it is replaced with the escaped page URL and fetched with
+// GET (prerender and Splash style); otherwise {"url": ...} is POSTed to it as
+// JSON (Browserless style). The response body is the rendered DOM.
+type Endpoint struct {
+	endpoint string
+	client   *http.Client
+}
+
+// NewEndpoint checks that endpoint is an http(s) URL.
+func NewEndpoint(endpoint string) (*Endpoint, error) {
+	u, err := url.Parse(strings.ReplaceAll(endpoint, "{url}", ""))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("render endpoint must be an http(s) URL, got %q", endpoint)
+	}
+	return &Endpoint{endpoint: endpoint, client: &http.Client{Timeout: Timeout}}, nil
+}
+
+// Render asks the endpoint for the rendered DOM of pageURL.
+func (e *Endpoint) Render(ctx context.Context, pageURL string) ([]byte, error) {
+	var req *http.Request
+	var err error
+	if strings.Contains(e.endpoint, "{url}") {
+		target := strings.ReplaceAll(e.endpoint, "{url}", url.QueryEscape(pageURL))
+		req, err = http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	} else {
+		body, _ := json.Marshal(map[string]string{"url": pageURL})
+		req, err = http.NewRequestWithContext(ctx, http.MethodPost, e.endpoint, bytes.NewReader(body))
+		if req != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("render endpoint returned HTTP %d: %s", resp.StatusCode, lastLine(string(body)))
+	}
+	if len(body) == 0 {
+		return nil, fmt.Errorf("render endpoint returned an empty body")
+	}
+	return body, nil
+}
+
+// splitArgs splits a command line into arguments like a POSIX shell would,
+// without expansions.
+func splitArgs(s string) ([]string, error) {
+	var args []string
+	var cur strings.Builder
+	inArg := false
+	var quote rune
+	escaped := false
+	for _, r := range s {
+		switch {
+		case escaped:
+			cur.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped = true
+			inArg = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				cur.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inArg {
+				args = append(args, cur.String())
+				cur.Reset()
+				inArg = false
+			}
+		default:
+			cur.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 || escaped {
+		return nil, fmt.Errorf("unterminated quote or escape in %q", s)
+	}
+	if inArg {
+		args = append(args, cur.String())
+	}
+	return args, nil
+}
+
+// lastLine returns the last non-empty line of s, shortened for an error
+// message.
+func lastLine(s string) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	line := strings.TrimSpace(lines[len(lines)-1])
+	if len(line) > 200 {
+		line = line[:200] + "..."
+	}
+	return line
+}
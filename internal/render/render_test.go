@@ -0,0 +1,236 @@
+package render
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSplitArgs(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"chromium --headless --dump-dom", []string{"chromium", "--headless", "--dump-dom"}},
+		{"  spaced\t out\n ", []string{"spaced", "out"}},
+		{`render "my page" 'it''s'`, []string{"render", "my page", "its"}},
+		{`a "" b`, []string{"a", "", "b"}},
+		{`say "a \"quoted\" word"`, []string{"say", `a "quoted" word`}},
+		{`'no \escape'`, []string{`no \escape`}},
+		{`one\ arg`, []string{"one arg"}},
+		{`--url={url} "{url}"`, []string{"--url={url}", "{url}"}},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		got, err := splitArgs(tt.in)
+		if err != nil {
+			t.Errorf("splitArgs(%q): %v", tt.in, err)
+			continue
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("splitArgs(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+
+	for _, in := range []string{`"open`, `'open`, `trailing\`} {
+		if _, err := splitArgs(in); err == nil {
+			t.Errorf("splitArgs(%q) succeeded", in)
+		}
+	}
+}
+
+func TestNewCommand(t *testing.T) {
+	tests := []struct {
+		cmdline string
+		want    []string
+	}{
+		{"browser --dump", []string{"browser", "--dump", "{url}"}},
+		{"browser --target={url} --dump", []string{"browser", "--target={url}", "--dump"}},
+	}
+	for _, tt := range tests {
+		c, err := NewCommand(tt.cmdline)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(c.args, tt.want) {
+			t.Errorf("NewCommand(%q) args = %q, want %q", tt.cmdline, c.args, tt.want)
+		}
+	}
+	for _, bad := range []string{"", "   ", `"open`} {
+		if _, err := NewCommand(bad); err == nil {
+			t.Errorf("NewCommand(%q) succeeded", bad)
+		}
+	}
+}
+
+// script writes an executable shell script and returns its path.
+func script(t *testing.T, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "render.sh")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+body+"\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestCommandRender(t *testing.T) {
+	const page = "https://example.com/docs/a?x=1&y=2"
+	tests := []struct {
+		name    string
+		body    string
+		flags   string
+		want    string
+		wantErr string
+	}{
+		{
+			name: "url as last argument",
+			body: `echo "<html>$1</html>"`,
+			want: "<html>" + page + "</html>\n",
+		},
+		{
+			name:  "url placeholder and env",
+			body:  `echo "$1 $2 $DOCS_CLONER_URL"`,
+			flags: "--url={url} 'two words'",
+			want:  "--url=" + page + " two words " + page + "\n",
+		},
+		{
+			name:    "non-zero exit",
+			body:    "echo 'partial'; echo 'warming up' >&2; echo 'browser crashed' >&2; exit 3",
+			wantErr: "exit status 3: browser crashed",
+		},
+		{
+			name:    "no output",
+			body:    "exit 0",
+			wantErr: "printed nothing",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := script(t, tt.body)
+			c, err := NewCommand(path + " " + tt.flags)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := c.Render(context.Background(), page)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Render error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Render = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCommandRenderTimeout(t *testing.T) {
+	// The script's child keeps stdout open after the script is killed
+	c, err := NewCommand(script(t, "sleep 30"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = c.Render(ctx, "https://example.com/")
+	if err == nil {
+		t.Fatal("Render succeeded")
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("Render returned after %v", d)
+	}
+}
+
+func TestEndpointRender(t *testing.T) {
+	const page = "https://example.com/docs/a?x=1&y=2"
+	var method, query, contentType, body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		method, query, contentType, body = r.Method, r.URL.Query().Get("url"), r.Header.Get("Content-Type"), string(b)
+		switch r.URL.Path {
+		case "/fail":
+			http.Error(w, "upstream\nbrowser pool exhausted", http.StatusServiceUnavailable)
+		case "/empty":
+		default:
+			w.Write([]byte("<html>rendered</html>"))
+		}
+	}))
+	defer srv.Close()
+
+	t.Run("get", func(t *testing.T) {
+		e, err := NewEndpoint(srv.URL + "/render?url={url}")
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := e.Render(context.Background(), page)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != "<html>rendered</html>" || method != http.MethodGet || query != page {
+			t.Errorf("got %q via %s with url=%q", got, method, query)
+		}
+	})
+
+	t.Run("post", func(t *testing.T) {
+		e, err := NewEndpoint(srv.URL + "/content")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := e.Render(context.Background(), page); err != nil {
+			t.Fatal(err)
+		}
+		var req map[string]string
+		if err := json.Unmarshal([]byte(body), &req); err != nil || req["url"] != page {
+			t.Errorf("POST body = %q (%v)", body, err)
+		}
+		if method != http.MethodPost || contentType != "application/json" {
+			t.Errorf("request = %s with %q", method, contentType)
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		for path, want := range map[string]string{
+			"/fail":  "HTTP 503: browser pool exhausted",
+			"/empty": "empty body",
+		} {
+			e, _ := NewEndpoint(srv.URL + path)
+			if _, err := e.Render(context.Background(), page); err == nil || !strings.Contains(err.Error(), want) {
+				t.Errorf("%s: error = %v, want %q", path, err, want)
+			}
+		}
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		e, _ := NewEndpoint(srv.URL)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if _, err := e.Render(ctx, page); !errors.Is(err, context.Canceled) {
+			t.Errorf("error = %v, want context.Canceled", err)
+		}
+	})
+}
+
+func TestNewEndpointValidates(t *testing.T) {
+	for _, bad := range []string{"", "localhost:3000", "ftp://example.com/render", "http:///render"} {
+		if _, err := NewEndpoint(bad); err == nil {
+			t.Errorf("NewEndpoint(%q) succeeded", bad)
+		}
+	}
+	if _, err := NewEndpoint("http://localhost:3000/render?url={url}"); err != nil {
+		t.Error(err)
+	}
+}
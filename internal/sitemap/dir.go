@@ -0,0 +1,65 @@
+package sitemap
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// FormatDir is the Format reported for a local directory of HTML files.
+const FormatDir Format = "HTML directory"
+
+// WalkDir lists the .html and .htm files under dir, in lexical order, as page
+// URLs below baseURL, so a static site build can be cloned without a server.
+// index.html files map to their directory URL, and each file's modification
+// time becomes the entry's lastmod. Hidden files and directories are skipped.
+func WalkDir(ctx context.Context, dir, baseURL string, page func(URL) error) error {
+	base, err := url.Parse(baseURL)
+	if err != nil || base.Scheme == "" || base.Host == "" {
+		return fmt.Errorf("invalid base URL %q", baseURL)
+	}
+	base.Path = strings.TrimSuffix(base.Path, "/") + "/"
+	base.RawPath = ""
+
+	return filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if p != dir && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		ext := strings.ToLower(filepath.Ext(p))
+		if d.IsDir() || (ext != ".html" && ext != ".htm") {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if rel == "index.html" {
+			rel = ""
+		} else if strings.HasSuffix(rel, "/index.html") {
+			rel = strings.TrimSuffix(rel, "index.html")
+		}
+
+		u := *base
+		u.Path += rel
+		entry := URL{Loc: u.String()}
+		if info, err := d.Info(); err == nil {
+			entry.LastMod = info.ModTime().UTC().Format(time.RFC3339)
+		}
+		return page(entry)
+	})
+}
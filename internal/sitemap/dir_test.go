@@ -0,0 +1,84 @@
+package sitemap
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// writeFiles creates files under dir, making parent directories as needed.
+func writeFiles(t *testing.T, dir string, names ...string) {
+	t.Helper()
+	for _, name := range names {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte("<html></html>"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestWalkDir(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir,
+		"index.html",
+		"guide/index.html",
+		"guide/install.html",
+		"api/Ref.HTM",
+		"assets/app.js",
+		"notes.md",
+		".hidden/page.html",
+		"guide/.draft.html",
+	)
+	mtime := time.Date(2024, 3, 5, 10, 0, 0, 0, time.UTC)
+	if err := os.Chtimes(filepath.Join(dir, "guide", "install.html"), mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+
+	var locs []string
+	lastmod := make(map[string]string)
+	err := WalkDir(context.Background(), dir, "https://example.com/docs", func(u URL) error {
+		locs = append(locs, u.Loc)
+		lastmod[u.Loc] = u.LastMod
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"https://example.com/docs/api/Ref.HTM",
+		"https://example.com/docs/guide/",
+		"https://example.com/docs/guide/install.html",
+		"https://example.com/docs/",
+	}
+	if !slices.Equal(locs, want) {
+		t.Errorf("WalkDir = %v, want %v", locs, want)
+	}
+	if got := lastmod["https://example.com/docs/guide/install.html"]; got != "2024-03-05T10:00:00Z" {
+		t.Errorf("lastmod = %q, want the file's modification time", got)
+	}
+}
+
+func TestWalkDirInvalidBase(t *testing.T) {
+	for _, base := range []string{"", "/docs", "example.com/docs"} {
+		if err := WalkDir(context.Background(), t.TempDir(), base, func(URL) error { return nil }); err == nil {
+			t.Errorf("WalkDir with base %q: want error", base)
+		}
+	}
+}
+
+func TestWalkDirCancelled(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, "a.html", "b.html")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := WalkDir(ctx, dir, "https://example.com/", func(URL) error { return nil }); err != context.Canceled {
+		t.Errorf("WalkDir = %v, want context.Canceled", err)
+	}
+}
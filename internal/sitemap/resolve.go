@@ -0,0 +1,238 @@
+package sitemap
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// DefaultMaxDepth is the sub-sitemap nesting limit used when
+// Resolver.MaxDepth is zero.
+const DefaultMaxDepth = 5
+
+// Resolver walks a sitemap and its sub-sitemaps, streaming deduplicated page
+// entries to a callback. Any document StreamSource understands can be the
+// root or a sub-sitemap. Sub-sitemaps are fetched concurrently; every sitemap
+// URL is fetched at most once, so self-referencing indexes terminate.
+type Resolver struct {
+	// Open fetches a sitemap URL and returns a reader over its body.
+	Open func(ctx context.Context, url string) (io.ReadCloser, error)
+	// MaxDepth limits sub-sitemap nesting below the root; 0 = DefaultMaxDepth.
+	MaxDepth int
+	// Concurrency is the number of sub-sitemaps fetched in parallel; values
+	// below 1 mean 1.
+	Concurrency int
+	// Allow, if set, decides whether a sub-sitemap URL is fetched at all.
+	Allow func(url string) bool
+	// Logf receives warnings about failed or skipped sub-sitemaps.
+	Logf func(format string, args ...any)
+}
+
+// ResolveStats summarizes a Resolve call.
+type ResolveStats struct {
+	Format     Format // format detected for the root document
+	Sitemaps   int    // sitemaps fetched successfully, including the root
+	Failed     int    // sub-sitemaps that could not be fetched or parsed
+	Filtered   int    // sub-sitemaps skipped by Allow
+	TooDeep    int    // sub-sitemaps skipped because of MaxDepth
+	Repeated   int    // sub-sitemap references to an already visited sitemap
+	Duplicates int    // page entries dropped because their URL was already seen
+}
+
+// Resolve fetches rootURL and all reachable sub-sitemaps, calling emit once
+// per unique page URL. emit is never called concurrently. A failure to fetch
+// or parse the root is returned as an error; sub-sitemap failures are logged
+// and counted. An error returned by emit stops resolution and is returned.
+func (r *Resolver) Resolve(ctx context.Context, rootURL string, emit func(URL) error) (ResolveStats, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	s := &resolveState{
+		r:       r,
+		emit:    emit,
+		cancel:  cancel,
+		visited: map[string]bool{rootURL: true},
+		seen:    make(map[string]bool),
+	}
+
+	subs, err := s.fetch(ctx, rootURL)
+	if err != nil {
+		if emitErr := s.emitError(); emitErr != nil {
+			return s.stats, emitErr
+		}
+		return s.stats, err
+	}
+
+	conc := r.Concurrency
+	if conc < 1 {
+		conc = 1
+	}
+	s.sem = make(chan struct{}, conc)
+
+	for _, sub := range subs {
+		s.schedule(ctx, sub, 1)
+	}
+	s.wg.Wait()
+
+	if emitErr := s.emitError(); emitErr != nil {
+		return s.stats, emitErr
+	}
+	return s.stats, ctx.Err()
+}
+
+type resolveState struct {
+	r      *Resolver
+	emit   func(URL) error
+	cancel context.CancelFunc
+	sem    chan struct{}
+	wg     sync.WaitGroup
+
+	// emitMu serializes emit. emit may block while the consumer is busy, so
+	// it is held apart from mu: other sitemaps keep deduplicating entries
+	// and recording stats in the meantime.
+	emitMu sync.Mutex
+
+	mu      sync.Mutex // guards everything below
+	visited map[string]bool
+	seen    map[string]bool
+	stats   ResolveStats
+	emitErr error
+}
+
+// schedule starts fetching a sub-sitemap in the background unless it was
+// already visited, is filtered out, or is nested too deeply.
+func (s *resolveState) schedule(ctx context.Context, sitemapURL string, depth int) {
+	maxDepth := s.r.MaxDepth
+	if maxDepth == 0 {
+		maxDepth = DefaultMaxDepth
+	}
+
+	s.mu.Lock()
+	switch {
+	case s.visited[sitemapURL]:
+		s.stats.Repeated++
+		s.mu.Unlock()
+		return
+	case s.r.Allow != nil && !s.r.Allow(sitemapURL):
+		s.stats.Filtered++
+		s.mu.Unlock()
+		return
+	case depth > maxDepth:
+		s.stats.TooDeep++
+		s.mu.Unlock()
+		s.logf("WARNING: sub-sitemap %s exceeds max depth %d; skipping", sitemapURL, maxDepth)
+		return
+	}
+	s.visited[sitemapURL] = true
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		select {
+		case s.sem <- struct{}{}:
+		case <-ctx.Done():
+			return
+		}
+		subs, err := s.fetch(ctx, sitemapURL)
+		<-s.sem
+
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			s.mu.Lock()
+			s.stats.Failed++
+			s.mu.Unlock()
+			s.logf("WARNING: sub-sitemap %s failed: %v", sitemapURL, err)
+			return
+		}
+
+		for _, sub := range subs {
+			s.schedule(ctx, sub, depth+1)
+		}
+	}()
+}
+
+// fetch streams one sitemap, emitting new page entries as they are decoded,
+// and returns the sub-sitemap URLs it lists.
+func (s *resolveState) fetch(ctx context.Context, sitemapURL string) ([]string, error) {
+	body, err := s.r.Open(ctx, sitemapURL)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	var subs []string
+	format, err := StreamSource(body, sitemapURL,
+		func(u URL) error {
+			return s.emitOnce(u)
+		},
+		func(loc string) error {
+			subs = append(subs, loc)
+			return nil
+		},
+	)
+	if err != nil {
+		if s.emitError() != nil || ctx.Err() != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("parsing %s: %w", sitemapURL, err)
+	}
+
+	s.mu.Lock()
+	s.stats.Sitemaps++
+	if s.stats.Format == "" {
+		s.stats.Format = format
+	}
+	s.mu.Unlock()
+	return subs, nil
+}
+
+// emitOnce passes u to emit unless its URL (ignoring any fragment) has been
+// emitted before.
+func (s *resolveState) emitOnce(u URL) error {
+	key, _, _ := strings.Cut(u.Loc, "#")
+
+	s.mu.Lock()
+	if err := s.emitErr; err != nil {
+		s.mu.Unlock()
+		return err
+	}
+	if s.seen[key] {
+		s.stats.Duplicates++
+		s.mu.Unlock()
+		return nil
+	}
+	s.seen[key] = true
+	s.mu.Unlock()
+
+	s.emitMu.Lock()
+	defer s.emitMu.Unlock()
+	if err := s.emitError(); err != nil {
+		return err
+	}
+	if err := s.emit(u); err != nil {
+		s.mu.Lock()
+		s.emitErr = err
+		s.mu.Unlock()
+		s.cancel()
+		return err
+	}
+	return nil
+}
+
+func (s *resolveState) emitError() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.emitErr
+}
+
+func (s *resolveState) logf(format string, args ...any) {
+	if s.r.Logf != nil {
+		s.r.Logf(format, args...)
+	}
+}
@@ -0,0 +1,230 @@
+package sitemap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// siteOpener serves sitemap documents by URL.
+type siteOpener map[string]string
+
+func (o siteOpener) Open(_ context.Context, url string) (io.ReadCloser, error) {
+	doc, ok := o[url]
+	if !ok {
+		return nil, fmt.Errorf("HTTP 404 for %s", url)
+	}
+	return io.NopCloser(strings.NewReader(doc)), nil
+}
+
+func urlset(locs ...string) string {
+	var b strings.Builder
+	b.WriteString(`<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">`)
+	for _, l := range locs {
+		fmt.Fprintf(&b, "<url><loc>%s</loc></url>", l)
+	}
+	b.WriteString("</urlset>")
+	return b.String()
+}
+
+func sitemapIndex(locs ...string) string {
+	var b strings.Builder
+	b.WriteString(`<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">`)
+	for _, l := range locs {
+		fmt.Fprintf(&b, "<sitemap><loc>%s</loc></sitemap>", l)
+	}
+	b.WriteString("</sitemapindex>")
+	return b.String()
+}
+
+// resolve runs r over rootURL and returns the emitted URLs, sorted.
+func resolve(t *testing.T, r *Resolver, rootURL string) ([]string, ResolveStats) {
+	t.Helper()
+	var mu sync.Mutex
+	var locs []string
+	stats, err := r.Resolve(context.Background(), rootURL, func(u URL) error {
+		mu.Lock()
+		defer mu.Unlock()
+		locs = append(locs, u.Loc)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(locs)
+	return locs, stats
+}
+
+func TestResolveCyclesAndDuplicates(t *testing.T) {
+	site := siteOpener{
+		"https://example.com/index.xml": sitemapIndex("https://example.com/a.xml", "https://example.com/b.xml", "https://example.com/index.xml"),
+		"https://example.com/a.xml":     urlset("https://example.com/1", "https://example.com/2"),
+		"https://example.com/b.xml":     sitemapIndex("https://example.com/a.xml", "https://example.com/c.xml"),
+		"https://example.com/c.xml":     urlset("https://example.com/2#section", "https://example.com/3"),
+	}
+	r := &Resolver{Open: site.Open, Concurrency: 3}
+	locs, stats := resolve(t, r, "https://example.com/index.xml")
+
+	// Either variant of page 2 may win, depending on which sitemap is read first
+	for i, l := range locs {
+		locs[i], _, _ = strings.Cut(l, "#")
+	}
+	want := []string{"https://example.com/1", "https://example.com/2", "https://example.com/3"}
+	if !slices.Equal(locs, want) {
+		t.Errorf("emitted %v, want %v", locs, want)
+	}
+	if stats.Sitemaps != 4 || stats.Repeated != 2 || stats.Duplicates != 1 || stats.Format != FormatSitemap {
+		t.Errorf("stats = %+v", stats)
+	}
+}
+
+func TestResolveMaxDepth(t *testing.T) {
+	site := siteOpener{
+		"https://example.com/0.xml": sitemapIndex("https://example.com/1.xml"),
+		"https://example.com/1.xml": sitemapIndex("https://example.com/2.xml"),
+		"https://example.com/2.xml": sitemapIndex("https://example.com/3.xml"),
+		"https://example.com/3.xml": urlset("https://example.com/deep"),
+	}
+	var warnings []string
+	r := &Resolver{Open: site.Open, MaxDepth: 2, Logf: func(format string, args ...any) {
+		warnings = append(warnings, fmt.Sprintf(format, args...))
+	}}
+	locs, stats := resolve(t, r, "https://example.com/0.xml")
+
+	if len(locs) != 0 || stats.TooDeep != 1 || stats.Sitemaps != 3 {
+		t.Errorf("emitted %v, stats %+v; want nothing past depth 2", locs, stats)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "3.xml exceeds max depth 2") {
+		t.Errorf("warnings = %q", warnings)
+	}
+}
+
+func TestResolveFilteredAndFailedSubSitemaps(t *testing.T) {
+	site := siteOpener{
+		"https://example.com/index.xml": sitemapIndex("https://example.com/en.xml", "https://example.com/de.xml", "https://example.com/missing.xml", "https://example.com/bad.xml"),
+		"https://example.com/en.xml":    urlset("https://example.com/en/a"),
+		"https://example.com/de.xml":    urlset("https://example.com/de/a"),
+		"https://example.com/bad.xml":   "<urlset><url><loc>https://example.com/x",
+	}
+	r := &Resolver{
+		Open:  site.Open,
+		Allow: func(url string) bool { return !strings.HasSuffix(url, "/de.xml") },
+		Logf:  func(string, ...any) {},
+	}
+	locs, stats := resolve(t, r, "https://example.com/index.xml")
+
+	if !slices.Equal(locs, []string{"https://example.com/en/a"}) {
+		t.Errorf("emitted %v", locs)
+	}
+	if stats.Filtered != 1 || stats.Failed != 2 || stats.Sitemaps != 2 {
+		t.Errorf("stats = %+v", stats)
+	}
+}
+
+func TestResolveRootFailure(t *testing.T) {
+	r := &Resolver{Open: siteOpener{}.Open}
+	_, err := r.Resolve(context.Background(), "https://example.com/sitemap.xml", func(URL) error { return nil })
+	if err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("Resolve = %v, want the fetch error", err)
+	}
+}
+
+func TestResolveStopsOnEmitError(t *testing.T) {
+	site := siteOpener{
+		"https://example.com/index.xml": sitemapIndex("https://example.com/a.xml", "https://example.com/b.xml"),
+		"https://example.com/a.xml":     urlset("https://example.com/1", "https://example.com/2"),
+		"https://example.com/b.xml":     urlset("https://example.com/3", "https://example.com/4"),
+	}
+	stop := errors.New("budget reached")
+	calls := 0
+	r := &Resolver{Open: site.Open, Concurrency: 2, Logf: func(string, ...any) {}}
+	_, err := r.Resolve(context.Background(), "https://example.com/index.xml", func(URL) error {
+		calls++
+		return stop
+	})
+	if err != stop || calls != 1 {
+		t.Errorf("Resolve = %v after %d calls, want the emit error after 1", err, calls)
+	}
+}
+
+func TestResolveEmitIsNotConcurrent(t *testing.T) {
+	site := siteOpener{}
+	var subs []string
+	for i := range 8 {
+		sub := fmt.Sprintf("https://example.com/%d.xml", i)
+		subs = append(subs, sub)
+		site[sub] = urlset(fmt.Sprintf("https://example.com/%d/a", i), fmt.Sprintf("https://example.com/%d/b", i))
+	}
+	site["https://example.com/index.xml"] = sitemapIndex(subs...)
+
+	var active, overlaps atomic.Int32
+	n := 0
+	r := &Resolver{Open: site.Open, Concurrency: 4}
+	_, err := r.Resolve(context.Background(), "https://example.com/index.xml", func(URL) error {
+		if active.Add(1) > 1 {
+			overlaps.Add(1)
+		}
+		time.Sleep(time.Millisecond)
+		n++
+		active.Add(-1)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if overlaps.Load() != 0 || n != 16 {
+		t.Errorf("emit ran concurrently %d times, %d calls", overlaps.Load(), n)
+	}
+}
+
+// notifyBody signals closed when it is closed.
+type notifyBody struct {
+	io.Reader
+	closed chan struct{}
+}
+
+func (b notifyBody) Close() error {
+	close(b.closed)
+	return nil
+}
+
+func TestResolveBlockedEmitDoesNotStallOtherSitemaps(t *testing.T) {
+	site := siteOpener{
+		"https://example.com/index.xml": sitemapIndex("https://example.com/a.xml", "https://example.com/b.xml"),
+		"https://example.com/a.xml":     urlset("https://example.com/page"),
+	}
+	entered := make(chan struct{})
+	bClosed := make(chan struct{})
+	open := func(ctx context.Context, url string) (io.ReadCloser, error) {
+		if url == "https://example.com/b.xml" {
+			// b lists only a duplicate; open it once emit is blocked on a's page
+			<-entered
+			return notifyBody{Reader: strings.NewReader(urlset("https://example.com/page")), closed: bClosed}, nil
+		}
+		return site.Open(ctx, url)
+	}
+
+	r := &Resolver{Open: open, Concurrency: 2}
+	stats, err := r.Resolve(context.Background(), "https://example.com/index.xml", func(URL) error {
+		close(entered)
+		select {
+		case <-bClosed:
+			return nil
+		case <-time.After(2 * time.Second):
+			return errors.New("b.xml was not read while emit was blocked")
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Duplicates != 1 || stats.Sitemaps != 3 {
+		t.Errorf("stats = %+v", stats)
+	}
+}
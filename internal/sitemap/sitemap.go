@@ -1,22 +1,83 @@
 package sitemap
 
-import "encoding/xml"
-
-// URLSet represents a standard sitemap <urlset>.
-type URLSet struct {
-	XMLName xml.Name `xml:"urlset"`
-	URLs    []URL    `xml:"url"`
-}
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
 
 // URL is a single <url> entry in a sitemap.
 type URL struct {
-	Loc string `xml:"loc"`
+	Loc        string      `xml:"loc"`
+	LastMod    string      `xml:"lastmod"`
+	ChangeFreq string      `xml:"changefreq"`
+	Priority   string      `xml:"priority"`
+	Alternates []Alternate `xml:"link"`
 }
 
-// SitemapIndex represents a <sitemapindex> that links to sub-sitemaps.
-type SitemapIndex struct {
-	XMLName  xml.Name  `xml:"sitemapindex"`
-	Sitemaps []Sitemap `xml:"sitemap"`
+// Alternate is an <xhtml:link rel="alternate" hreflang="..."> entry that
+// points at a localized version of a page.
+type Alternate struct {
+	Rel      string `xml:"rel,attr"`
+	Hreflang string `xml:"hreflang,attr"`
+	Href     string `xml:"href,attr"`
+}
+
+// lastModLayouts are the W3C Datetime forms allowed for <lastmod>.
+var lastModLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02",
+	"2006-01",
+	"2006",
+}
+
+// LastModified parses LastMod. It reports false if the entry has no lastmod
+// or the value is not a valid W3C Datetime.
+func (u URL) LastModified() (time.Time, bool) {
+	return ParseDate(u.LastMod)
+}
+
+// Lang returns the locale of the page itself, taken from the hreflang
+// alternate that points back at Loc. It returns "" if the sitemap does not
+// say.
+func (u URL) Lang() string {
+	for _, alt := range u.Alternates {
+		if alt.Href == u.Loc && alt.Hreflang != "x-default" {
+			return alt.Hreflang
+		}
+	}
+	return ""
+}
+
+// MatchesLang reports whether the page's locale is lang or a regional
+// variant of it ("en" matches "en", "en-US" and "en_GB"). Pages whose locale
+// is unknown always match.
+func (u URL) MatchesLang(lang string) bool {
+	pageLang := u.Lang()
+	if pageLang == "" {
+		return true
+	}
+	pageLang = strings.ToLower(strings.ReplaceAll(pageLang, "_", "-"))
+	lang = strings.ToLower(strings.ReplaceAll(lang, "_", "-"))
+	return pageLang == lang || strings.HasPrefix(pageLang, lang+"-")
+}
+
+// ParseDate parses a W3C Datetime as used by sitemaps and by the --since flag.
+func ParseDate(s string) (time.Time, bool) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}, false
+	}
+	for _, layout := range lastModLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
 }
 
 // Sitemap is a single <sitemap> entry in a sitemap index.
@@ -26,36 +87,124 @@ type Sitemap struct {
 
 // ParseResult holds the output of parsing a sitemap document.
 type ParseResult struct {
-	PageURLs    []string // page URLs from a <urlset>
+	Pages       []URL    // page entries from a <urlset>
 	SubSitemaps []string // sub-sitemap URLs from a <sitemapindex>
 }
 
 // Parse parses raw XML bytes as either a sitemap index or a urlset.
-// It returns page URLs and/or sub-sitemap URLs depending on the document type.
+// It returns page entries and/or sub-sitemap URLs depending on the document type.
 func Parse(data []byte) (*ParseResult, error) {
-	// Try sitemap index first
-	var idx SitemapIndex
-	if err := xml.Unmarshal(data, &idx); err == nil && idx.XMLName.Local == "sitemapindex" {
-		result := &ParseResult{}
-		for _, s := range idx.Sitemaps {
-			if s.Loc != "" {
-				result.SubSitemaps = append(result.SubSitemaps, s.Loc)
+	result := &ParseResult{}
+	err := Stream(bytes.NewReader(data),
+		func(u URL) error {
+			result.Pages = append(result.Pages, u)
+			return nil
+		},
+		func(loc string) error {
+			result.SubSitemaps = append(result.SubSitemaps, loc)
+			return nil
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Stream decodes a sitemap index or urlset from r in a single pass, calling
+// page for each <url> entry and sub for each <sitemap> entry as soon as it
+// is decoded. It never holds more than one entry in memory. An error
+// returned by a callback stops decoding and is returned as-is.
+func Stream(r io.Reader, page func(URL) error, sub func(loc string) error) error {
+	dec := xml.NewDecoder(r)
+
+	root, err := rootElement(dec)
+	if err != nil {
+		return err
+	}
+	switch root.Name.Local {
+	case "urlset", "sitemapindex":
+	default:
+		return fmt.Errorf("unexpected root element <%s>, want <urlset> or <sitemapindex>", root.Name.Local)
+	}
+	return streamSitemap(dec, root, page, sub)
+}
+
+// streamSitemap decodes the children of a <urlset> or <sitemapindex> root.
+func streamSitemap(dec *xml.Decoder, root xml.StartElement, page func(URL) error, sub func(loc string) error) error {
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch {
+			case root.Name.Local == "urlset" && t.Name.Local == "url":
+				var u URL
+				if err := dec.DecodeElement(&u, &t); err != nil {
+					return err
+				}
+				if u, ok := cleanURL(u); ok {
+					if err := page(u); err != nil {
+						return err
+					}
+				}
+			case root.Name.Local == "sitemapindex" && t.Name.Local == "sitemap":
+				var s Sitemap
+				if err := dec.DecodeElement(&s, &t); err != nil {
+					return err
+				}
+				if loc := strings.TrimSpace(s.Loc); loc != "" {
+					if err := sub(loc); err != nil {
+						return err
+					}
+				}
+			default:
+				if err := dec.Skip(); err != nil {
+					return err
+				}
 			}
+		case xml.EndElement:
+			// End of the root element
+			return nil
 		}
-		return result, nil
 	}
+}
 
-	// Otherwise parse as urlset
-	var urlset URLSet
-	if err := xml.Unmarshal(data, &urlset); err != nil {
-		return nil, err
+// rootElement advances dec past the prolog to the document's root element.
+func rootElement(dec *xml.Decoder) (xml.StartElement, error) {
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return xml.StartElement{}, fmt.Errorf("empty sitemap document")
+		}
+		if err != nil {
+			return xml.StartElement{}, err
+		}
+		if se, ok := tok.(xml.StartElement); ok {
+			return se, nil
+		}
 	}
+}
 
-	result := &ParseResult{}
-	for _, u := range urlset.URLs {
-		if u.Loc != "" {
-			result.PageURLs = append(result.PageURLs, u.Loc)
+// cleanURL trims whitespace from a decoded entry and keeps only hreflang
+// alternates. It reports false for entries without a <loc>.
+func cleanURL(u URL) (URL, bool) {
+	u.Loc = strings.TrimSpace(u.Loc)
+	if u.Loc == "" {
+		return u, false
+	}
+	alts := u.Alternates[:0]
+	for _, alt := range u.Alternates {
+		if alt.Rel == "alternate" && alt.Hreflang != "" && alt.Href != "" {
+			alts = append(alts, alt)
 		}
 	}
-	return result, nil
+	u.Alternates = alts
+	return u, true
 }
@@ -0,0 +1,173 @@
+package sitemap
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const urlsetXML = `<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9"
+        xmlns:xhtml="http://www.w3.org/1999/xhtml">
+  <url>
+    <loc> https://example.com/en/guide </loc>
+    <lastmod>2024-03-01T10:00:00+00:00</lastmod>
+    <changefreq>weekly</changefreq>
+    <priority>0.8</priority>
+    <xhtml:link rel="alternate" hreflang="en" href="https://example.com/en/guide"/>
+    <xhtml:link rel="alternate" hreflang="de" href="https://example.com/de/guide"/>
+    <xhtml:link rel="alternate" hreflang="x-default" href="https://example.com/guide"/>
+    <xhtml:link rel="canonical" href="https://example.com/en/guide"/>
+  </url>
+  <url>
+    <loc></loc>
+  </url>
+  <url>
+    <loc>https://example.com/about</loc>
+  </url>
+</urlset>`
+
+func TestParseURLSet(t *testing.T) {
+	res, err := Parse([]byte(urlsetXML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.SubSitemaps) != 0 {
+		t.Errorf("SubSitemaps = %v, want none", res.SubSitemaps)
+	}
+	want := []URL{
+		{
+			Loc:        "https://example.com/en/guide",
+			LastMod:    "2024-03-01T10:00:00+00:00",
+			ChangeFreq: "weekly",
+			Priority:   "0.8",
+			Alternates: []Alternate{
+				{Rel: "alternate", Hreflang: "en", Href: "https://example.com/en/guide"},
+				{Rel: "alternate", Hreflang: "de", Href: "https://example.com/de/guide"},
+				{Rel: "alternate", Hreflang: "x-default", Href: "https://example.com/guide"},
+			},
+		},
+		{Loc: "https://example.com/about"},
+	}
+	if !reflect.DeepEqual(res.Pages, want) {
+		t.Errorf("Pages =\n  %+v\nwant\n  %+v", res.Pages, want)
+	}
+}
+
+func TestParseIndex(t *testing.T) {
+	res, err := Parse([]byte(`<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <sitemap><loc>https://example.com/a.xml</loc><lastmod>2024-01-01</lastmod></sitemap>
+  <sitemap><loc> </loc></sitemap>
+  <sitemap><loc>https://example.com/b.xml</loc></sitemap>
+</sitemapindex>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"https://example.com/a.xml", "https://example.com/b.xml"}
+	if !reflect.DeepEqual(res.SubSitemaps, want) || len(res.Pages) != 0 {
+		t.Errorf("got pages %v, sub-sitemaps %v; want sub-sitemaps %v", res.Pages, res.SubSitemaps, want)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+	}{
+		{"empty", ""},
+		{"wrong root", "<html><body></body></html>"},
+		{"truncated", "<urlset><url><loc>https://example.com/</loc></url>"},
+		{"malformed", "<urlset><url><loc>x</url></urlset>"},
+	}
+	for _, tt := range tests {
+		if _, err := Parse([]byte(tt.doc)); err == nil {
+			t.Errorf("%s: want error", tt.name)
+		}
+	}
+}
+
+func TestStreamStopsOnCallbackError(t *testing.T) {
+	stop := errors.New("stop")
+	calls := 0
+	err := Stream(strings.NewReader(urlsetXML), func(URL) error {
+		calls++
+		return stop
+	}, nil)
+	if err != stop || calls != 1 {
+		t.Errorf("Stream = %v after %d calls, want the callback error after 1", err, calls)
+	}
+}
+
+func TestLang(t *testing.T) {
+	res, err := Parse([]byte(urlsetXML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := res.Pages[0].Lang(); got != "en" {
+		t.Errorf("Lang() = %q, want en", got)
+	}
+	if got := res.Pages[1].Lang(); got != "" {
+		t.Errorf("Lang() without alternates = %q, want empty", got)
+	}
+
+	// x-default pointing back at the page is not a locale
+	u := URL{Loc: "https://example.com/", Alternates: []Alternate{
+		{Rel: "alternate", Hreflang: "x-default", Href: "https://example.com/"},
+	}}
+	if got := u.Lang(); got != "" {
+		t.Errorf("Lang() for x-default = %q, want empty", got)
+	}
+}
+
+func TestMatchesLang(t *testing.T) {
+	page := func(lang string) URL {
+		return URL{Loc: "https://example.com/p", Alternates: []Alternate{
+			{Rel: "alternate", Hreflang: lang, Href: "https://example.com/p"},
+		}}
+	}
+	tests := []struct {
+		pageLang, want string
+		match          bool
+	}{
+		{"en", "en", true},
+		{"en-US", "en", true},
+		{"en_GB", "en", true},
+		{"EN-us", "en-US", true},
+		{"en-US", "en-GB", false},
+		{"eng", "en", false},
+		{"de", "en", false},
+	}
+	for _, tt := range tests {
+		if got := page(tt.pageLang).MatchesLang(tt.want); got != tt.match {
+			t.Errorf("page %q MatchesLang(%q) = %v, want %v", tt.pageLang, tt.want, got, tt.match)
+		}
+	}
+	if !(URL{Loc: "https://example.com/p"}).MatchesLang("fr") {
+		t.Error("page without a locale should match any language")
+	}
+}
+
+func TestParseDate(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Time
+		ok   bool
+	}{
+		{"2024-03-01T10:20:30.5Z", time.Date(2024, 3, 1, 10, 20, 30, 5e8, time.UTC), true},
+		{"2024-03-01T10:20:30+02:00", time.Date(2024, 3, 1, 8, 20, 30, 0, time.UTC), true},
+		{"2024-03-01T10:20Z", time.Date(2024, 3, 1, 10, 20, 0, 0, time.UTC), true},
+		{" 2024-03-01 ", time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), true},
+		{"2024-03", time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), true},
+		{"2024", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), true},
+		{"", time.Time{}, false},
+		{"01/03/2024", time.Time{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := ParseDate(tt.in)
+		if ok != tt.ok || !got.Equal(tt.want) {
+			t.Errorf("ParseDate(%q) = %v, %v; want %v, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
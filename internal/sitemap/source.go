@@ -0,0 +1,229 @@
+package sitemap
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Format identifies the kind of document a list of page URLs was read from.
+type Format string
+
+// Supported source formats.
+const (
+	FormatSitemap Format = "XML sitemap"
+	FormatRSS     Format = "RSS feed"
+	FormatAtom    Format = "Atom feed"
+	FormatText    Format = "text URL list"
+	FormatLLMsTxt Format = "llms.txt"
+)
+
+// sniffLen is how much of a document is inspected to detect its format.
+const sniffLen = 4096
+
+// markdownLink matches inline markdown links and captures the target.
+var markdownLink = regexp.MustCompile(`\[[^\]]*\]\(\s*<?([^)\s>]+)>?(?:\s+"[^"]*")?\s*\)`)
+
+// feedDateLayouts are the date formats used by RSS <pubDate>.
+var feedDateLayouts = []string{
+	time.RFC1123Z,
+	time.RFC1123,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"2 Jan 2006 15:04:05 -0700",
+}
+
+// StreamSource detects the format of r by sniffing its content and streams
+// the page URLs it lists, like Stream does for XML sitemaps. Besides sitemap
+// urlsets and indexes it understands RSS and Atom feeds, plain-text lists
+// with one URL per line, and llms.txt files whose markdown links point at
+// pages. base is used to resolve relative links and may be empty.
+func StreamSource(r io.Reader, base string, page func(URL) error, sub func(loc string) error) (Format, error) {
+	br := bufio.NewReaderSize(r, sniffLen)
+	head, err := br.Peek(sniffLen)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return "", err
+	}
+	head = bytes.TrimLeft(bytes.TrimPrefix(head, []byte("\xef\xbb\xbf")), " \t\r\n")
+
+	if bytes.HasPrefix(head, []byte("<")) {
+		return streamXML(br, base, page, sub)
+	}
+
+	baseURL, _ := url.Parse(base)
+	if isLLMsTxt(head) {
+		return FormatLLMsTxt, streamLines(br, func(line string) error {
+			for _, m := range markdownLink.FindAllStringSubmatch(line, -1) {
+				if loc, ok := resolveLink(baseURL, m[1]); ok {
+					if err := page(URL{Loc: loc}); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		})
+	}
+
+	return FormatText, streamLines(br, func(line string) error {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			return nil
+		}
+		if loc, ok := resolveLink(nil, line); ok {
+			return page(URL{Loc: loc})
+		}
+		return nil
+	})
+}
+
+// isLLMsTxt reports whether a text document looks like llms.txt: markdown
+// with links. A leading "# Title" line alone isn't enough, since URL lists
+// can start with a comment.
+func isLLMsTxt(head []byte) bool {
+	return markdownLink.Match(head)
+}
+
+// streamXML dispatches on the root element of an XML document.
+func streamXML(r io.Reader, base string, page func(URL) error, sub func(loc string) error) (Format, error) {
+	dec := xml.NewDecoder(r)
+	root, err := rootElement(dec)
+	if err != nil {
+		return "", err
+	}
+
+	switch root.Name.Local {
+	case "urlset", "sitemapindex":
+		return FormatSitemap, streamSitemap(dec, root, page, sub)
+	case "rss", "RDF":
+		return FormatRSS, streamRSS(dec, page)
+	case "feed":
+		baseURL, _ := url.Parse(base)
+		return FormatAtom, streamAtom(dec, baseURL, page)
+	default:
+		return "", fmt.Errorf("unexpected root element <%s>, want a sitemap, RSS or Atom document", root.Name.Local)
+	}
+}
+
+// rssItem is an RSS 2.0 or RSS 1.0 <item>.
+type rssItem struct {
+	Link    string `xml:"link"`
+	PubDate string `xml:"pubDate"`
+	DCDate  string `xml:"http://purl.org/dc/elements/1.1/ date"`
+}
+
+// streamRSS emits the link of every <item>, wherever it appears.
+func streamRSS(dec *xml.Decoder, page func(URL) error) error {
+	return forEachElement(dec, "item", func(start xml.StartElement) error {
+		var item rssItem
+		if err := dec.DecodeElement(&item, &start); err != nil {
+			return err
+		}
+		loc, ok := resolveLink(nil, strings.TrimSpace(item.Link))
+		if !ok {
+			return nil
+		}
+		return page(URL{Loc: loc, LastMod: feedDate(item.PubDate, item.DCDate)})
+	})
+}
+
+// atomEntry is an Atom <entry>.
+type atomEntry struct {
+	Links []struct {
+		Href string `xml:"href,attr"`
+		Rel  string `xml:"rel,attr"`
+	} `xml:"link"`
+	Updated string `xml:"updated"`
+}
+
+// streamAtom emits the alternate link of every <entry>.
+func streamAtom(dec *xml.Decoder, base *url.URL, page func(URL) error) error {
+	return forEachElement(dec, "entry", func(start xml.StartElement) error {
+		var entry atomEntry
+		if err := dec.DecodeElement(&entry, &start); err != nil {
+			return err
+		}
+		for _, link := range entry.Links {
+			if link.Rel != "" && link.Rel != "alternate" {
+				continue
+			}
+			if loc, ok := resolveLink(base, link.Href); ok {
+				return page(URL{Loc: loc, LastMod: strings.TrimSpace(entry.Updated)})
+			}
+		}
+		return nil
+	})
+}
+
+// forEachElement calls fn for every start element named local until the end
+// of the document. fn must consume the element.
+func forEachElement(dec *xml.Decoder, local string, fn func(xml.StartElement) error) error {
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if se, ok := tok.(xml.StartElement); ok && se.Name.Local == local {
+			if err := fn(se); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// streamLines calls fn for every line of r.
+func streamLines(r io.Reader, fn func(line string) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if err := fn(scanner.Text()); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// resolveLink resolves ref against base and reports whether the result is an
+// absolute http(s) URL. Fragments are dropped.
+func resolveLink(base *url.URL, ref string) (string, bool) {
+	u, err := url.Parse(ref)
+	if err != nil {
+		return "", false
+	}
+	if base != nil {
+		u = base.ResolveReference(u)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", false
+	}
+	u.Fragment = ""
+	return u.String(), true
+}
+
+// feedDate converts the first parseable feed date to a sitemap-style
+// lastmod, or returns "".
+func feedDate(values ...string) string {
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		if _, ok := ParseDate(v); ok {
+			return v
+		}
+		for _, layout := range feedDateLayouts {
+			if t, err := time.Parse(layout, v); err == nil {
+				return t.Format(time.RFC3339)
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,161 @@
+package sitemap
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// streamSource runs StreamSource over doc and returns the format and entries.
+func streamSource(t *testing.T, doc, base string) (Format, []URL) {
+	t.Helper()
+	var pages []URL
+	format, err := StreamSource(strings.NewReader(doc), base, func(u URL) error {
+		pages = append(pages, u)
+		return nil
+	}, func(string) error { return nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	return format, pages
+}
+
+func TestStreamSourceFormats(t *testing.T) {
+	tests := []struct {
+		name   string
+		doc    string
+		base   string
+		format Format
+		want   []URL
+	}{
+		{
+			name:   "sitemap with BOM and whitespace",
+			doc:    "\ufeff\n  " + urlset("https://example.com/a"),
+			format: FormatSitemap,
+			want:   []URL{{Loc: "https://example.com/a"}},
+		},
+		{
+			name: "text list",
+			doc: "https://example.com/a\n\n# a comment\n  https://example.com/b#top  \n" +
+				"/relative\nftp://example.com/c\nnot a url\r\nhttps://example.com/d\r\n",
+			format: FormatText,
+			want: []URL{
+				{Loc: "https://example.com/a"},
+				{Loc: "https://example.com/b"},
+				{Loc: "https://example.com/d"},
+			},
+		},
+		{
+			name:   "text list starting with a comment",
+			doc:    "# Pages to clone\n# one per line\nhttps://example.com/a\nhttps://example.com/b\n",
+			format: FormatText,
+			want:   []URL{{Loc: "https://example.com/a"}, {Loc: "https://example.com/b"}},
+		},
+		{
+			name: "llms.txt",
+			doc: "# Example\n\n> Docs for Example.\n\n## Docs\n\n" +
+				"- [Install](https://example.com/install.md): how to install\n" +
+				"- [Guide](/guide.md \"The guide\") and [API](<api/index.md#methods>)\n" +
+				"- [Mail](mailto:docs@example.com)\n",
+			base:   "https://example.com/llms.txt",
+			format: FormatLLMsTxt,
+			want: []URL{
+				{Loc: "https://example.com/install.md"},
+				{Loc: "https://example.com/guide.md"},
+				{Loc: "https://example.com/api/index.md"},
+			},
+		},
+		{
+			name:   "llms.txt without a title",
+			doc:    "See [the docs](https://example.com/docs).\n",
+			format: FormatLLMsTxt,
+			want:   []URL{{Loc: "https://example.com/docs"}},
+		},
+		{
+			name: "RSS 2.0",
+			doc: `<?xml version="1.0"?><rss version="2.0"><channel><title>Blog</title>
+<link>https://example.com/</link>
+<item><title>One</title><link> https://example.com/one </link><pubDate>Tue, 05 Mar 2024 10:00:00 +0000</pubDate></item>
+<item><title>No link</title></item>
+<item><link>https://example.com/two</link><pubDate>garbage</pubDate></item>
+</channel></rss>`,
+			format: FormatRSS,
+			want: []URL{
+				{Loc: "https://example.com/one", LastMod: "2024-03-05T10:00:00Z"},
+				{Loc: "https://example.com/two"},
+			},
+		},
+		{
+			name: "RSS 1.0",
+			doc: `<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#" xmlns="http://purl.org/rss/1.0/" xmlns:dc="http://purl.org/dc/elements/1.1/">
+<channel><link>https://example.com/</link></channel>
+<item><link>https://example.com/one</link><dc:date>2024-03-05</dc:date></item>
+</rdf:RDF>`,
+			format: FormatRSS,
+			want:   []URL{{Loc: "https://example.com/one", LastMod: "2024-03-05"}},
+		},
+		{
+			name: "Atom",
+			doc: `<feed xmlns="http://www.w3.org/2005/Atom"><title>Changelog</title>
+<link rel="self" href="https://example.com/feed.xml"/>
+<entry><link rel="edit" href="https://example.com/edit/1"/><link href="/posts/1"/><updated>2024-03-05T10:00:00Z</updated></entry>
+<entry><link rel="alternate" href="https://example.com/posts/2"/></entry>
+</feed>`,
+			base:   "https://example.com/feed.xml",
+			format: FormatAtom,
+			want: []URL{
+				{Loc: "https://example.com/posts/1", LastMod: "2024-03-05T10:00:00Z"},
+				{Loc: "https://example.com/posts/2"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			format, pages := streamSource(t, tt.doc, tt.base)
+			if format != tt.format {
+				t.Errorf("format = %q, want %q", format, tt.format)
+			}
+			if !reflect.DeepEqual(pages, tt.want) {
+				t.Errorf("pages =\n  %+v\nwant\n  %+v", pages, tt.want)
+			}
+		})
+	}
+}
+
+func TestStreamSourceSitemapIndex(t *testing.T) {
+	var subs []string
+	format, err := StreamSource(strings.NewReader(sitemapIndex("https://example.com/a.xml")), "",
+		func(URL) error { return nil },
+		func(loc string) error {
+			subs = append(subs, loc)
+			return nil
+		})
+	if err != nil || format != FormatSitemap || len(subs) != 1 {
+		t.Errorf("StreamSource = %q, %v; sub-sitemaps %v", format, err, subs)
+	}
+}
+
+func TestStreamSourceUnknownXML(t *testing.T) {
+	_, err := StreamSource(strings.NewReader("<html><body>Not found</body></html>"), "",
+		func(URL) error { return nil }, func(string) error { return nil })
+	if err == nil || !strings.Contains(err.Error(), "<html>") {
+		t.Errorf("StreamSource = %v, want an unexpected root element error", err)
+	}
+}
+
+func TestFeedDate(t *testing.T) {
+	tests := []struct {
+		values []string
+		want   string
+	}{
+		{[]string{"Tue, 05 Mar 2024 10:00:00 GMT"}, "2024-03-05T10:00:00Z"},
+		{[]string{"Tue, 5 Mar 2024 10:00:00 +0200"}, "2024-03-05T10:00:00+02:00"},
+		{[]string{"", "2024-03-05"}, "2024-03-05"},
+		{[]string{"yesterday"}, ""},
+	}
+	for _, tt := range tests {
+		if got := feedDate(tt.values...); got != tt.want {
+			t.Errorf("feedDate(%q) = %q, want %q", tt.values, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,230 @@
+// Package urlfilter decides which page URLs a run keeps. It combines
+// substring, glob, regex and host rules, evaluates each URL once, and counts
+// how many URLs every rule dropped.
+package urlfilter
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Rules lists the raw filter patterns by kind, as given on the command line.
+type Rules struct {
+	Include      []string // substrings of the full URL
+	Exclude      []string
+	IncludeGlob  []string // globs over the URL path; ** crosses directories
+	ExcludeGlob  []string
+	IncludeRegex []string // regular expressions over the URL path
+	ExcludeRegex []string
+	IncludeHost  []string // host names; *.example.com matches subdomains
+	ExcludeHost  []string
+}
+
+// Set is a compiled set of rules. A URL is kept if, for every kind of
+// include rule that was given, it matches at least one rule of that kind, and
+// it matches no exclude rule. Set is not safe for concurrent use.
+type Set struct {
+	includes []*group
+	excludes []*rule
+}
+
+// group is the include rules of one kind. A URL must match one of them.
+type group struct {
+	flag    string
+	rules   []*rule
+	dropped int
+}
+
+// rule is a single compiled pattern.
+type rule struct {
+	flag    string
+	pattern string
+	match   func(raw string, u *url.URL) bool
+	dropped int
+}
+
+// Drop reports how many URLs a rule, or a group of include rules, dropped.
+type Drop struct {
+	Rule  string // e.g. "--exclude-glob /blog/**"
+	Count int
+}
+
+// Compile builds a Set from r.
+func Compile(r Rules) (*Set, error) {
+	s := &Set{}
+	kinds := []struct {
+		flag             string
+		include, exclude []string
+		compile          func(string) (func(string, *url.URL) bool, error)
+	}{
+		{"include", r.Include, r.Exclude, substring},
+		{"include-glob", r.IncludeGlob, r.ExcludeGlob, glob},
+		{"include-regex", r.IncludeRegex, r.ExcludeRegex, pathRegex},
+		{"include-host", r.IncludeHost, r.ExcludeHost, host},
+	}
+
+	for _, k := range kinds {
+		excludeFlag := "exclude" + strings.TrimPrefix(k.flag, "include")
+		if len(k.include) > 0 {
+			g := &group{flag: "--" + k.flag}
+			for _, p := range k.include {
+				m, err := k.compile(p)
+				if err != nil {
+					return nil, fmt.Errorf("invalid --%s %q: %w", k.flag, p, err)
+				}
+				g.rules = append(g.rules, &rule{flag: g.flag, pattern: p, match: m})
+			}
+			s.includes = append(s.includes, g)
+		}
+		for _, p := range k.exclude {
+			m, err := k.compile(p)
+			if err != nil {
+				return nil, fmt.Errorf("invalid --%s %q: %w", excludeFlag, p, err)
+			}
+			s.excludes = append(s.excludes, &rule{flag: "--" + excludeFlag, pattern: p, match: m})
+		}
+	}
+	return s, nil
+}
+
+// Empty reports whether the set has no rules at all.
+func (s *Set) Empty() bool {
+	return len(s.includes) == 0 && len(s.excludes) == 0
+}
+
+// Keep reports whether rawURL passes the filters and records which rule
+// dropped it otherwise. URLs that cannot be parsed only match substring
+// rules.
+func (s *Set) Keep(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		u = &url.URL{}
+	}
+
+	for _, g := range s.includes {
+		matched := false
+		for _, r := range g.rules {
+			if r.match(rawURL, u) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			g.dropped++
+			return false
+		}
+	}
+	for _, r := range s.excludes {
+		if r.match(rawURL, u) {
+			r.dropped++
+			return false
+		}
+	}
+	return true
+}
+
+// Report lists every include group and exclude rule with the number of URLs
+// it dropped, in the order they were given.
+func (s *Set) Report() []Drop {
+	var drops []Drop
+	for _, g := range s.includes {
+		patterns := make([]string, len(g.rules))
+		for i, r := range g.rules {
+			patterns[i] = r.pattern
+		}
+		drops = append(drops, Drop{Rule: g.flag + " " + strings.Join(patterns, ", "), Count: g.dropped})
+	}
+	for _, r := range s.excludes {
+		drops = append(drops, Drop{Rule: r.flag + " " + r.pattern, Count: r.dropped})
+	}
+	return drops
+}
+
+func substring(p string) (func(string, *url.URL) bool, error) {
+	return func(raw string, _ *url.URL) bool {
+		return strings.Contains(raw, p)
+	}, nil
+}
+
+func pathRegex(p string) (func(string, *url.URL) bool, error) {
+	re, err := regexp.Compile(p)
+	if err != nil {
+		return nil, err
+	}
+	return func(_ string, u *url.URL) bool {
+		return re.MatchString(u.Path)
+	}, nil
+}
+
+func glob(p string) (func(string, *url.URL) bool, error) {
+	re, err := compileGlob(p)
+	if err != nil {
+		return nil, err
+	}
+	return func(_ string, u *url.URL) bool {
+		return re.MatchString(u.Path)
+	}, nil
+}
+
+func host(p string) (func(string, *url.URL) bool, error) {
+	p = strings.ToLower(p)
+	if p == "" {
+		return nil, fmt.Errorf("empty host")
+	}
+	return func(_ string, u *url.URL) bool {
+		h := strings.ToLower(u.Hostname())
+		if suffix, ok := strings.CutPrefix(p, "*."); ok {
+			return strings.HasSuffix(h, "."+suffix)
+		}
+		return h == p
+	}, nil
+}
+
+// compileGlob translates a path glob into an anchored regular expression.
+// "*" and "?" match within one path segment, "**" matches across segments,
+// a trailing "/**" also matches the directory itself, and "[...]" is a
+// character class. Patterns without a leading "/" are anchored at the root,
+// which also sidesteps Git Bash rewriting arguments that start with "/".
+func compileGlob(p string) (*regexp.Regexp, error) {
+	if !strings.HasPrefix(p, "/") {
+		p = "/" + p
+	}
+
+	var sb strings.Builder
+	sb.WriteString("^")
+	for i := 0; i < len(p); i++ {
+		c := p[i]
+		switch {
+		case strings.HasPrefix(p[i:], "/**") && i+3 == len(p):
+			sb.WriteString("(/.*)?")
+			i += 2
+		case strings.HasPrefix(p[i:], "**/"):
+			sb.WriteString("(.*/)?")
+			i += 2
+		case strings.HasPrefix(p[i:], "**"):
+			sb.WriteString(".*")
+			i++
+		case c == '*':
+			sb.WriteString("[^/]*")
+		case c == '?':
+			sb.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(p[i+1:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated [ in glob")
+			}
+			class := p[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			sb.WriteString("[" + class + "]")
+			i += end + 1
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	sb.WriteString("$")
+	return regexp.Compile(sb.String())
+}
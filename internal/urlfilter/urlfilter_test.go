@@ -0,0 +1,147 @@
+package urlfilter
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCompileGlob(t *testing.T) {
+	tests := []struct {
+		glob  string
+		path  string
+		match bool
+	}{
+		{"/docs/*", "/docs/install", true},
+		{"/docs/*", "/docs/guide/install", false},
+		{"/docs/*", "/docs", false},
+		{"/docs/**", "/docs", true},
+		{"/docs/**", "/docs/", true},
+		{"/docs/**", "/docs/guide/install", true},
+		{"/docs/**", "/docsearch", false},
+		{"docs/**", "/docs/a", true},
+		{"**/changelog", "/changelog", true},
+		{"**/changelog", "/v2/docs/changelog", true},
+		{"**/changelog", "/v2/docs/changelog/old", false},
+		{"/api/**/ref", "/api/v1/users/ref", true},
+		{"/api/**/ref", "/api/ref", true},
+		{"/v?/guide", "/v2/guide", true},
+		{"/v?/guide", "/v10/guide", false},
+		{"/v[0-9]/*", "/v3/x", true},
+		{"/v[!0-9]/*", "/va/x", true},
+		{"/v[!0-9]/*", "/v3/x", false},
+		{"/a.b/*.html", "/a.b/page.html", true},
+		{"/a.b/*.html", "/axb/page.html", false},
+		{"/docs/**.md", "/docs/a/b.md", true},
+	}
+	for _, tt := range tests {
+		re, err := compileGlob(tt.glob)
+		if err != nil {
+			t.Fatalf("compileGlob(%q): %v", tt.glob, err)
+		}
+		if got := re.MatchString(tt.path); got != tt.match {
+			t.Errorf("glob %q on %q = %v, want %v", tt.glob, tt.path, got, tt.match)
+		}
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		rules Rules
+		want  string
+	}{
+		{Rules{IncludeGlob: []string{"/docs/[a-z"}}, "invalid --include-glob"},
+		{Rules{ExcludeGlob: []string{"/docs/[a-z"}}, "invalid --exclude-glob"},
+		{Rules{IncludeRegex: []string{"("}}, "invalid --include-regex"},
+		{Rules{ExcludeRegex: []string{"a{2,1}"}}, "invalid --exclude-regex"},
+		{Rules{IncludeHost: []string{""}}, "invalid --include-host"},
+	}
+	for _, tt := range tests {
+		_, err := Compile(tt.rules)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Compile(%+v) = %v, want %q", tt.rules, err, tt.want)
+		}
+	}
+}
+
+func TestKeep(t *testing.T) {
+	s, err := Compile(Rules{
+		Include:      []string{"/docs/", "/guides/"},
+		Exclude:      []string{"?print="},
+		IncludeGlob:  []string{"/*/en/**"},
+		ExcludeGlob:  []string{"**/changelog"},
+		ExcludeRegex: []string{`/v[0-9]+/`},
+		IncludeHost:  []string{"example.com", "*.example.org"},
+		ExcludeHost:  []string{"old.example.org"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		url  string
+		keep bool
+	}{
+		{"https://example.com/docs/en/install", true},
+		{"https://EXAMPLE.com/guides/en/start", true},
+		{"https://docs.example.org/docs/en/a", true},
+		{"https://example.org/docs/en/a", false},          // *.example.org needs a subdomain
+		{"https://old.example.org/docs/en/a", false},      // excluded host
+		{"https://example.com/blog/en/post", false},       // no include substring
+		{"https://example.com/docs/de/install", false},    // glob
+		{"https://example.com/docs/en/changelog", false},  // exclude glob
+		{"https://example.com/docs/en/v2/install", false}, // exclude regex
+		{"https://example.com/docs/en/a?print=1", false},  // exclude substring
+		{"https://example.com/docs/en/a?v2=/v2/", true},   // regex only sees the path
+		{"https://example.com:8080/docs/en/a", true},      // port is not part of the host
+		{"https://other.com/docs/en/install", false},      // host
+	}
+	for _, tt := range tests {
+		if got := s.Keep(tt.url); got != tt.keep {
+			t.Errorf("Keep(%q) = %v, want %v", tt.url, got, tt.keep)
+		}
+	}
+}
+
+func TestReportCountsDrops(t *testing.T) {
+	s, err := Compile(Rules{
+		Include:     []string{"/docs/", "/api/"},
+		ExcludeGlob: []string{"/docs/old/**", "/api/internal/**"},
+		ExcludeHost: []string{"staging.example.com"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Empty() {
+		t.Error("Empty() = true")
+	}
+	for _, u := range []string{
+		"https://example.com/blog/a",
+		"https://example.com/blog/b",
+		"https://example.com/docs/old/a",
+		"https://example.com/docs/new/a",
+		"https://staging.example.com/docs/a",
+		"https://staging.example.com/docs/old/b",
+	} {
+		s.Keep(u)
+	}
+
+	want := []Drop{
+		{Rule: "--include /docs/, /api/", Count: 2},
+		{Rule: "--exclude-glob /docs/old/**", Count: 2},
+		{Rule: "--exclude-glob /api/internal/**", Count: 0},
+		{Rule: "--exclude-host staging.example.com", Count: 1},
+	}
+	if got := s.Report(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Report() =\n  %+v\nwant\n  %+v", got, want)
+	}
+}
+
+func TestEmpty(t *testing.T) {
+	s, err := Compile(Rules{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.Empty() || !s.Keep("https://example.com/anything") || len(s.Report()) != 0 {
+		t.Error("an empty set should keep everything and report nothing")
+	}
+}
@@ -0,0 +1,254 @@
+// Package versions detects documentation versions in page URLs and HTML and
+// selects which versions of a versioned docs site to clone.
+package versions
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Selection modes for --versions.
+const (
+	All    = "all"
+	Latest = "latest"
+)
+
+// numericVersion matches version path segments such as v2, 1.x, 2.3 and
+// v3.1.4, optionally with a pre-release suffix (2.0-beta). Bare numbers are
+// not versions, so date segments like /2024/05/ don't match.
+var numericVersion = regexp.MustCompile(`^(v\d+(\.(\d+|x))*|\d+(\.(\d+|x))+)(-[a-z0-9.]+)?$`)
+
+// latestAliases name the current release; they win over any numbered version.
+var latestAliases = map[string]bool{"latest": true, "stable": true, "current": true}
+
+// channels name unreleased or moving versions; they are never the latest.
+var channels = map[string]bool{"next": true, "dev": true, "nightly": true, "canary": true, "unstable": true}
+
+// htmlMarkers are selectors and attributes that name the version of a page
+// in common documentation generators' output.
+var htmlMarkers = []struct{ selector, attr string }{
+	{`meta[name="docsearch:version"]`, "content"},
+	{`meta[name="docusaurus_version"]`, "content"},
+	{`meta[name="docs-version"]`, "content"},
+	{`meta[name="version"]`, "content"},
+	{`html[data-version]`, "data-version"},
+	{`[data-docs-version]`, "data-docs-version"},
+	{`select.version-select option[selected]`, "value"},
+	{`.version-dropdown [aria-current]`, ""},
+}
+
+// FromPath returns the first version segment of a URL path and its index in
+// the slash-separated, non-empty segments, or "" and -1 if there is none.
+func FromPath(p string) (string, int) {
+	i := 0
+	for _, seg := range strings.Split(p, "/") {
+		if seg == "" {
+			continue
+		}
+		if IsVersion(seg) {
+			return strings.ToLower(seg), i
+		}
+		i++
+	}
+	return "", -1
+}
+
+// IsVersion reports whether a path segment names a version.
+func IsVersion(seg string) bool {
+	seg = strings.ToLower(seg)
+	return numericVersion.MatchString(seg) || latestAliases[seg] || channels[seg]
+}
+
+// StripSegment returns u with its version segment at index idx (as returned
+// by FromPath) removed, so the versions of a page share a path.
+func StripSegment(u *url.URL, idx int) *url.URL {
+	out := *u
+	var kept []string
+	i := 0
+	for _, seg := range strings.Split(u.Path, "/") {
+		if seg == "" {
+			kept = append(kept, seg)
+			continue
+		}
+		if i != idx {
+			kept = append(kept, seg)
+		}
+		i++
+	}
+	out.Path = strings.Join(kept, "/")
+	out.RawPath = ""
+	if !strings.HasPrefix(out.Path, "/") {
+		out.Path = "/" + out.Path
+	}
+	return &out
+}
+
+// FromDocument returns the version a page declares through a version meta
+// tag or the selected entry of a version dropdown, or "". It is only known
+// once the page is fetched, so it labels pages but never selects them.
+func FromDocument(doc *goquery.Document) string {
+	for _, m := range htmlMarkers {
+		sel := doc.Find(m.selector).First()
+		if sel.Length() == 0 {
+			continue
+		}
+		v := strings.TrimSpace(sel.Text())
+		if m.attr != "" {
+			v = strings.TrimSpace(sel.AttrOr(m.attr, ""))
+		}
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// Selector decides which versions to keep: all of them, only the latest, or
+// an explicit list.
+type Selector struct {
+	mode string          // All, Latest, or "" for a list
+	list map[string]bool // lowercased versions; may contain Latest
+}
+
+// ParseSelector parses a --versions value: "all", "latest", or a
+// comma-separated list of versions that may include "latest".
+func ParseSelector(s string) (Selector, error) {
+	s = strings.TrimSpace(strings.ToLower(s))
+	switch s {
+	case "", All:
+		return Selector{mode: All}, nil
+	case Latest:
+		return Selector{mode: Latest}, nil
+	}
+
+	list := make(map[string]bool)
+	for _, v := range strings.Split(s, ",") {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		if v != Latest && !IsVersion(v) {
+			return Selector{}, fmt.Errorf("invalid version %q", v)
+		}
+		list[v] = true
+	}
+	if len(list) == 0 {
+		return Selector{}, fmt.Errorf("empty version list")
+	}
+	return Selector{list: list}, nil
+}
+
+// KeepsAll reports whether the selector keeps every page, so entries can be
+// streamed without looking at the whole sitemap first.
+func (s Selector) KeepsAll() bool {
+	return s.mode == All
+}
+
+// Page is the version information of one URL, as used by Select.
+type Page struct {
+	Version  string // version segment, or "" for unversioned pages
+	Root     string // lowercased URL up to the version segment; the full URL if unversioned
+	Stripped string // lowercased URL without the version segment; unused if unversioned
+}
+
+// Select returns, for each page, whether the selector keeps it. Unversioned
+// pages count as the latest version and are kept by "latest" and by lists
+// that include "latest". Pages of the latest version below their root (see
+// LatestByRoot) are kept too, unless unversioned pages below the same root
+// mirror its versioned pages: sites that serve the current docs without a
+// version segment (/docs/intro next to /docs/2.2/intro) only number older
+// versions. An unrelated unversioned page such as /docs/search doesn't
+// count.
+func (s Selector) Select(pages []Page) []bool {
+	keep := make([]bool, len(pages))
+	if s.mode == All {
+		for i := range keep {
+			keep[i] = true
+		}
+		return keep
+	}
+
+	wantLatest := s.mode == Latest || s.list[Latest]
+	latest := LatestByRoot(pages)
+
+	// Version roots whose versioned pages are mirrored by unversioned ones
+	unversioned := make(map[string]bool)
+	for _, p := range pages {
+		if p.Version == "" {
+			unversioned[p.Root] = true
+		}
+	}
+	mirrored := make(map[string]bool)
+	for _, p := range pages {
+		if p.Version != "" && unversioned[p.Stripped] {
+			mirrored[p.Root] = true
+		}
+	}
+
+	for i, p := range pages {
+		switch {
+		case p.Version == "":
+			keep[i] = wantLatest
+		case s.list[p.Version]:
+			keep[i] = true
+		case wantLatest && p.Version == latest[p.Root]:
+			keep[i] = !mirrored[p.Root]
+		}
+	}
+	return keep
+}
+
+// LatestByRoot returns the latest version below each version root, so
+// sites that version several products independently keep the latest of
+// each. Within a root, a "latest", "stable" or "current" segment wins,
+// otherwise the highest numbered release. Pre-releases and channels such as
+// "next" are never the latest.
+func LatestByRoot(pages []Page) map[string]string {
+	latest := make(map[string]string)
+	for _, p := range pages {
+		v := p.Version
+		if v == "" || channels[v] || strings.Contains(v, "-") {
+			continue
+		}
+		best, ok := latest[p.Root]
+		switch {
+		case latestAliases[best]:
+		case latestAliases[v], !ok, compare(v, best) > 0:
+			latest[p.Root] = v
+		}
+	}
+	return latest
+}
+
+// compare orders numbered versions component by component; "x" sorts above
+// any number.
+func compare(a, b string) int {
+	pa := strings.Split(strings.TrimPrefix(a, "v"), ".")
+	pb := strings.Split(strings.TrimPrefix(b, "v"), ".")
+	for i := 0; i < len(pa) || i < len(pb); i++ {
+		na, nb := component(pa, i), component(pb, i)
+		if na != nb {
+			if na < nb {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+func component(parts []string, i int) int {
+	if i >= len(parts) {
+		return -1
+	}
+	if parts[i] == "x" {
+		return 1 << 30
+	}
+	n, _ := strconv.Atoi(parts[i])
+	return n
+}
@@ -0,0 +1,180 @@
+package versions
+
+import (
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestFromPath(t *testing.T) {
+	tests := []struct {
+		path    string
+		version string
+		idx     int
+	}{
+		{"/docs/v2/intro", "v2", 1},
+		{"/docs/1.x/intro", "1.x", 1},
+		{"/docs/2.3/intro", "2.3", 1},
+		{"/v3.1.4/api", "v3.1.4", 0},
+		{"/docs/2.0-beta.1/intro", "2.0-beta.1", 1},
+		{"/docs/Next/intro", "next", 1},
+		{"/docs/latest/", "latest", 1},
+		{"//docs//stable/x", "stable", 1},
+		{"/blog/2024/05/post", "", -1},
+		{"/docs/intro", "", -1},
+		{"/docs/v/intro", "", -1},
+		{"/docs/version2/intro", "", -1},
+	}
+	for _, tt := range tests {
+		v, idx := FromPath(tt.path)
+		if v != tt.version || idx != tt.idx {
+			t.Errorf("FromPath(%q) = %q, %d; want %q, %d", tt.path, v, idx, tt.version, tt.idx)
+		}
+	}
+}
+
+func TestStripSegment(t *testing.T) {
+	tests := []struct{ url, want string }{
+		{"https://example.com/docs/v2/intro", "https://example.com/docs/intro"},
+		{"https://example.com/v2/", "https://example.com/"},
+		{"https://example.com/v2", "https://example.com/"},
+		{"https://example.com/docs/2.3/a%20b?x=1", "https://example.com/docs/a%20b?x=1"},
+	}
+	for _, tt := range tests {
+		u, _ := url.Parse(tt.url)
+		_, idx := FromPath(u.Path)
+		if got := StripSegment(u, idx).String(); got != tt.want {
+			t.Errorf("StripSegment(%q) = %q, want %q", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestFromDocument(t *testing.T) {
+	tests := []struct{ html, want string }{
+		{`<meta name="docsearch:version" content=" 2.3 ">`, "2.3"},
+		{`<meta name="docusaurus_version" content="current">`, "current"},
+		{`<html data-version="v5"><body></body></html>`, "v5"},
+		{`<select class="version-select"><option value="1.0">1.0</option><option value="2.0" selected>2.0</option></select>`, "2.0"},
+		{`<ul class="version-dropdown"><li>1.0</li><li aria-current="true"> 3.1 </li></ul>`, "3.1"},
+		{`<meta name="version" content="">`, ""},
+		{`<p>Version 2</p>`, ""},
+	}
+	for _, tt := range tests {
+		doc, err := goquery.NewDocumentFromReader(strings.NewReader(tt.html))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := FromDocument(doc); got != tt.want {
+			t.Errorf("FromDocument(%s) = %q, want %q", tt.html, got, tt.want)
+		}
+	}
+}
+
+func TestParseSelector(t *testing.T) {
+	for _, s := range []string{"", "all", "ALL", "latest", "2.3,next", " v1 , latest "} {
+		if _, err := ParseSelector(s); err != nil {
+			t.Errorf("ParseSelector(%q): %v", s, err)
+		}
+	}
+	for _, s := range []string{",", "2.3,docs", "newest"} {
+		if _, err := ParseSelector(s); err == nil {
+			t.Errorf("ParseSelector(%q): want error", s)
+		}
+	}
+	if sel, _ := ParseSelector(""); !sel.KeepsAll() {
+		t.Error(`ParseSelector("").KeepsAll() = false`)
+	}
+	if sel, _ := ParseSelector("latest"); sel.KeepsAll() {
+		t.Error(`ParseSelector("latest").KeepsAll() = true`)
+	}
+}
+
+func TestLatestByRoot(t *testing.T) {
+	pages := []Page{
+		{Version: "1.9", Root: "https://example.com/docs"},
+		{Version: "1.10", Root: "https://example.com/docs"},
+		{Version: "2.0-beta", Root: "https://example.com/docs"},
+		{Version: "next", Root: "https://example.com/docs"},
+		{Version: "v1", Root: "https://example.com/api"},
+		{Version: "stable", Root: "https://example.com/api"},
+		{Version: "v9", Root: "https://example.com/api"},
+		{Version: "2.x", Root: "https://example.com/sdk"},
+		{Version: "2.9", Root: "https://example.com/sdk"},
+		{Root: "https://example.com/blog/post"},
+	}
+	got := LatestByRoot(pages)
+	want := map[string]string{
+		"https://example.com/docs": "1.10",
+		"https://example.com/api":  "stable",
+		"https://example.com/sdk":  "2.x",
+	}
+	if len(got) != len(want) {
+		t.Errorf("LatestByRoot = %v, want %v", got, want)
+	}
+	for root, v := range want {
+		if got[root] != v {
+			t.Errorf("latest under %s = %q, want %q", root, got[root], v)
+		}
+	}
+}
+
+func TestSelect(t *testing.T) {
+	pages := []Page{
+		{Version: "1.0", Root: "https://example.com/docs"},                                               // 0
+		{Version: "2.0", Root: "https://example.com/docs"},                                               // 1
+		{Version: "next", Root: "https://example.com/docs"},                                              // 2
+		{Root: "https://example.com/about"},                                                              // 3
+		{Version: "2.2", Root: "https://example.com/guide", Stripped: "https://example.com/guide/intro"}, // 4: older version of unversioned docs
+		{Root: "https://example.com/guide/intro"},                                                        // 5
+		{Version: "v3", Root: "https://example.com/api"},                                                 // 6
+		{Version: "v2", Root: "https://example.com/api"},                                                 // 7
+	}
+	tests := []struct {
+		selector string
+		want     []int
+	}{
+		{"all", []int{0, 1, 2, 3, 4, 5, 6, 7}},
+		{"latest", []int{1, 3, 5, 6}},
+		{"1.0,next", []int{0, 2}},
+		{"2.2,latest", []int{1, 3, 4, 5, 6}},
+	}
+	for _, tt := range tests {
+		sel, err := ParseSelector(tt.selector)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []int
+		for i, keep := range sel.Select(pages) {
+			if keep {
+				got = append(got, i)
+			}
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("--versions %s keeps %v, want %v", tt.selector, got, tt.want)
+		}
+	}
+}
+
+func TestSelectIgnoresUnrelatedUnversionedPages(t *testing.T) {
+	pages := []Page{
+		{Version: "v1", Root: "https://example.com/docs", Stripped: "https://example.com/docs/intro"},
+		{Version: "v2", Root: "https://example.com/docs", Stripped: "https://example.com/docs/intro"},
+		{Version: "v2", Root: "https://example.com/docs", Stripped: "https://example.com/docs/usage"},
+		{Root: "https://example.com/docs/search"},
+	}
+	sel, _ := ParseSelector(Latest)
+	want := []bool{false, true, true, true}
+	if got := sel.Select(pages); !slices.Equal(got, want) {
+		t.Errorf("Select = %v, want %v", got, want)
+	}
+
+	// Once an unversioned page mirrors a versioned one, v2 is an old version
+	pages = append(pages, Page{Root: "https://example.com/docs/intro"})
+	want = []bool{false, false, false, true, true}
+	if got := sel.Select(pages); !slices.Equal(got, want) {
+		t.Errorf("Select with a mirror = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,86 @@
+package writer
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Heading anchors as written by the converter: <a id="x"></a> or {#x}.
+var (
+	htmlAnchor = regexp.MustCompile(`<a id="([^"]+)"></a>`)
+	attrAnchor = regexp.MustCompile(`\{#([^}\s]+)\}`)
+)
+
+// markdownLink matches the target of an inline link or image: ](target) or
+// ](target "title").
+var markdownLink = regexp.MustCompile(`\]\(([^)\s]+)(\s+"[^"]*")?\)`)
+
+// pageAnchors returns a unique anchor for each page's section in the single
+// file, derived from its title.
+func pageAnchors(pages []PageResult) []string {
+	anchors := make([]string, len(pages))
+	used := make(map[string]int)
+	for i, p := range pages {
+		anchor := slugify(p.Title)
+		if anchor == "" {
+			anchor = fmt.Sprintf("page-%d", i+1)
+		}
+		if n := used[anchor]; n > 0 {
+			used[anchor]++
+			anchor = fmt.Sprintf("%s-%d", anchor, n+1)
+		} else {
+			used[anchor] = 1
+		}
+		anchors[i] = anchor
+	}
+	return anchors
+}
+
+// pageIDs returns the explicit heading ids in a page's markdown.
+func pageIDs(md string) map[string]bool {
+	ids := make(map[string]bool)
+	for _, re := range []*regexp.Regexp{htmlAnchor, attrAnchor} {
+		for _, m := range re.FindAllStringSubmatch(md, -1) {
+			ids[m[1]] = true
+		}
+	}
+	return ids
+}
+
+// namespaceAnchors rewrites a page's markdown for the single file. Heading
+// ids get the page's anchor as a prefix so ids from different pages don't
+// clash, and links to pages in the file (by URL, with or without a fragment)
+// are pointed at the page's section or the prefixed id.
+func namespaceAnchors(md string, page int, urls map[string]int, anchors []string, ids []map[string]bool) string {
+	prefix := anchors[page] + "--"
+	md = htmlAnchor.ReplaceAllString(md, `<a id="`+prefix+`$1"></a>`)
+	md = attrAnchor.ReplaceAllString(md, `{#`+prefix+`$1}`)
+
+	return markdownLink.ReplaceAllStringFunc(md, func(link string) string {
+		m := markdownLink.FindStringSubmatch(link)
+		target, title := m[1], m[2]
+		base, frag, hasFrag := strings.Cut(target, "#")
+
+		i := page
+		if base != "" {
+			var ok bool
+			if i, ok = urls[base]; !ok {
+				return link
+			}
+		} else if !hasFrag {
+			return link
+		}
+
+		switch {
+		case !hasFrag || frag == "":
+			target = "#" + anchors[i]
+		case ids[i][frag]:
+			target = "#" + anchors[i] + "--" + frag
+		default:
+			// An id generated from the heading text; keep it as is
+			target = "#" + frag
+		}
+		return "](" + target + title + ")"
+	})
+}
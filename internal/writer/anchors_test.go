@@ -0,0 +1,78 @@
+package writer
+
+import (
+	"strings"
+	"testing"
+)
+
+// memSink keeps written files in memory.
+type memSink map[string][]byte
+
+func (s memSink) Put(name string, data []byte) error {
+	s[name] = data
+	return nil
+}
+
+func (s memSink) Close() error { return nil }
+
+func TestPageAnchors(t *testing.T) {
+	pages := []PageResult{{Title: "Getting Started"}, {Title: "getting started!"}, {Title: "¿?"}, {Title: "Getting Started"}}
+	got := pageAnchors(pages)
+	want := []string{"getting-started", "getting-started-2", "page-3", "getting-started-3"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("pageAnchors = %v, want %v", got, want)
+	}
+}
+
+func TestNamespaceAnchors(t *testing.T) {
+	urls := map[string]int{"https://example.com/a": 0, "https://example.com/b": 1}
+	anchors := []string{"a", "b"}
+	ids := []map[string]bool{
+		pageIDs(`# <a id="top"></a>A` + "\n\n## Usage {#usage}"),
+		pageIDs(`## <a id="opts"></a>Options`),
+	}
+	if !ids[0]["top"] || !ids[0]["usage"] || len(ids[0]) != 2 {
+		t.Fatalf("pageIDs = %v", ids[0])
+	}
+
+	md := `# <a id="top"></a>A
+
+## Usage {#usage}
+
+[self](#usage) [generated](#some-heading) [page](https://example.com/b) ` +
+		`[option](https://example.com/b#opts "Options") [empty](https://example.com/b#) ` +
+		`[outside](https://other.com/#x) [relative](guide.md)`
+	want := `# <a id="a--top"></a>A
+
+## Usage {#a--usage}
+
+[self](#a--usage) [generated](#some-heading) [page](#b) ` +
+		`[option](#b--opts "Options") [empty](#b) ` +
+		`[outside](https://other.com/#x) [relative](guide.md)`
+	if got := namespaceAnchors(md, 0, urls, anchors, ids); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestWriteSingleFile(t *testing.T) {
+	sink := memSink{}
+	pages := []PageResult{
+		{URL: "https://example.com/a", Title: "Alpha", Markdown: "# Alpha\n\nSee [Beta](https://example.com/b#x).", TitleInBody: true},
+		{URL: "https://example.com/b", Title: "Beta", Markdown: `## <a id="x"></a>Section`},
+	}
+	if err := WriteSingleFile(sink, pages); err != nil {
+		t.Fatal(err)
+	}
+	out := string(sink[SingleFile])
+	for _, want := range []string{
+		"- [Alpha](#alpha)\n- [Beta](#beta)\n",
+		"<a id=\"alpha\"></a>\n\n*Source: https://example.com/a*\n\n# Alpha",
+		"See [Beta](#beta--x).",
+		"<a id=\"beta\"></a>\n\n## Beta\n\n*Source: https://example.com/b*",
+		`## <a id="beta--x"></a>Section`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("single file missing %q:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package writer
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// TarGzSink streams files into a gzipped tar archive as they are written.
+type TarGzSink struct {
+	file *os.File
+	gz   *gzip.Writer
+	tw   *tar.Writer
+}
+
+// NewTarGzSink creates (or truncates) the archive at path.
+func NewTarGzSink(path string) (*TarGzSink, error) {
+	f, err := createArchive(path)
+	if err != nil {
+		return nil, err
+	}
+	gz := gzip.NewWriter(f)
+	return &TarGzSink{file: f, gz: gz, tw: tar.NewWriter(gz)}, nil
+}
+
+// Put appends a file entry to the archive.
+func (s *TarGzSink) Put(name string, data []byte) error {
+	hdr := &tar.Header{
+		Name:    name,
+		Mode:    0644,
+		Size:    int64(len(data)),
+		ModTime: time.Now(),
+		Format:  tar.FormatPAX,
+	}
+	if err := s.tw.WriteHeader(hdr); err != nil {
+		return fmt.Errorf("writing %s to archive: %w", name, err)
+	}
+	if _, err := s.tw.Write(data); err != nil {
+		return fmt.Errorf("writing %s to archive: %w", name, err)
+	}
+	return nil
+}
+
+// Close finishes the archive and closes the file.
+func (s *TarGzSink) Close() error {
+	if err := s.tw.Close(); err != nil {
+		s.file.Close()
+		return fmt.Errorf("finishing tar archive: %w", err)
+	}
+	if err := s.gz.Close(); err != nil {
+		s.file.Close()
+		return fmt.Errorf("finishing tar archive: %w", err)
+	}
+	return s.file.Close()
+}
+
+// ZipSink streams files into a zip archive as they are written.
+type ZipSink struct {
+	file *os.File
+	zw   *zip.Writer
+}
+
+// NewZipSink creates (or truncates) the archive at path.
+func NewZipSink(path string) (*ZipSink, error) {
+	f, err := createArchive(path)
+	if err != nil {
+		return nil, err
+	}
+	return &ZipSink{file: f, zw: zip.NewWriter(f)}, nil
+}
+
+// Put appends a compressed file entry to the archive.
+func (s *ZipSink) Put(name string, data []byte) error {
+	w, err := s.zw.CreateHeader(&zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: time.Now(),
+	})
+	if err != nil {
+		return fmt.Errorf("writing %s to archive: %w", name, err)
+	}
+	if _, err := w.Write(data); err != nil {
+		return fmt.Errorf("writing %s to archive: %w", name, err)
+	}
+	return nil
+}
+
+// Close writes the zip central directory and closes the file.
+func (s *ZipSink) Close() error {
+	if err := s.zw.Close(); err != nil {
+		s.file.Close()
+		return fmt.Errorf("finishing zip archive: %w", err)
+	}
+	return s.file.Close()
+}
+
+func createArchive(path string) (*os.File, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("creating directory for %s: %w", path, err)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("creating archive: %w", err)
+	}
+	return f, nil
+}
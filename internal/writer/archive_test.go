@@ -0,0 +1,113 @@
+package writer
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+var archiveFiles = []struct{ name, body string }{
+	{"index.md", "# Home\n"},
+	{"guide/install.md", "# Install\n\nRun it.\n"},
+	{"manifest.json", `{"pages":[]}`},
+}
+
+func putAll(t *testing.T, s Sink) {
+	t.Helper()
+	for _, f := range archiveFiles {
+		if err := s.Put(f.name, []byte(f.body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func wantArchive() map[string]string {
+	want := make(map[string]string)
+	for _, f := range archiveFiles {
+		want[f.name] = f.body
+	}
+	return want
+}
+
+func TestTarGzSinkRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out", "docs.tar.gz")
+	s, err := NewTarGzSink(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	putAll(t, s)
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(gz)
+	got := make(map[string]string)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hdr.Mode != 0644 {
+			t.Errorf("%s: mode %o, want 644", hdr.Name, hdr.Mode)
+		}
+		body, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got[hdr.Name] = string(body)
+	}
+	if want := wantArchive(); !reflect.DeepEqual(got, want) {
+		t.Errorf("archive = %v, want %v", got, want)
+	}
+}
+
+func TestZipSinkRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "docs.zip")
+	s, err := NewZipSink(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	putAll(t, s)
+
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zr.Close()
+	got := make(map[string]string)
+	for _, f := range zr.File {
+		if f.Method != zip.Deflate {
+			t.Errorf("%s: method %d, want deflate", f.Name, f.Method)
+		}
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got[f.Name] = string(body)
+	}
+	if want := wantArchive(); !reflect.DeepEqual(got, want) {
+		t.Errorf("archive = %v, want %v", got, want)
+	}
+}
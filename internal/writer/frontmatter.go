@@ -0,0 +1,284 @@
+package writer
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Frontmatter formats.
+const (
+	FormatYAML = "yaml"
+	FormatTOML = "toml"
+	FormatJSON = "json"
+	FormatNone = "none"
+)
+
+// FrontmatterFields lists every frontmatter field in output order.
+var FrontmatterFields = []string{
+	"title",
+	"description",
+	"source_url",
+	"canonical_url",
+	"source_mode",
+	"crawl_date",
+	"lang",
+	"lastmod",
+	"modified",
+	"changefreq",
+	"priority",
+	"version",
+	"keywords",
+	"breadcrumbs",
+	"og",
+	"word_count",
+	"tokens",
+	"content_hash",
+}
+
+// requiredFields are written even when empty, so every page has them.
+var requiredFields = map[string]bool{
+	"title":       true,
+	"source_url":  true,
+	"source_mode": true,
+	"crawl_date":  true,
+}
+
+// FrontmatterOptions selects the frontmatter format and the fields written.
+type FrontmatterOptions struct {
+	Format string   // one of the Format* constants; empty = yaml
+	Fields []string // subset of FrontmatterFields; empty = all
+}
+
+// Validate checks the format and field names.
+func (o FrontmatterOptions) Validate() error {
+	switch o.Format {
+	case "", FormatYAML, FormatTOML, FormatJSON, FormatNone:
+	default:
+		return fmt.Errorf("unknown frontmatter format %q (want yaml, toml, json or none)", o.Format)
+	}
+	for _, f := range o.Fields {
+		if !slices.Contains(FrontmatterFields, f) {
+			return fmt.Errorf("unknown frontmatter field %q (known: %s)", f, strings.Join(FrontmatterFields, ", "))
+		}
+	}
+	return nil
+}
+
+// field is a frontmatter key and its value.
+type field struct {
+	key   string
+	value any
+}
+
+// fields returns the fields of m selected by opts, in output order. Empty
+// optional fields are left out.
+func (m Meta) fields(opts FrontmatterOptions) []field {
+	var priority any = m.Priority
+	if p, err := strconv.ParseFloat(m.Priority, 64); err == nil {
+		priority = p
+	}
+	values := map[string]any{
+		"title":         m.Title,
+		"description":   m.Description,
+		"source_url":    m.SourceURL,
+		"canonical_url": m.CanonicalURL,
+		"source_mode":   m.SourceMode,
+		"crawl_date":    m.CrawlDate.Truncate(time.Second),
+		"lang":          m.Lang,
+		"lastmod":       m.LastMod,
+		"modified":      m.Modified,
+		"changefreq":    m.ChangeFreq,
+		"priority":      priority,
+		"version":       m.Version,
+		"keywords":      m.Keywords,
+		"breadcrumbs":   m.Breadcrumbs,
+		"og":            m.OpenGraph,
+		"word_count":    m.WordCount,
+		"tokens":        m.Tokens,
+		"content_hash":  m.ContentHash,
+	}
+
+	var out []field
+	for _, key := range FrontmatterFields {
+		if len(opts.Fields) > 0 && !slices.Contains(opts.Fields, key) {
+			continue
+		}
+		v := values[key]
+		if !requiredFields[key] && isEmpty(v) {
+			continue
+		}
+		out = append(out, field{key, v})
+	}
+	return out
+}
+
+func isEmpty(v any) bool {
+	switch t := v.(type) {
+	case string:
+		return t == ""
+	case int:
+		return t == 0
+	case []string:
+		return len(t) == 0
+	case map[string]string:
+		return len(t) == 0
+	}
+	return false
+}
+
+// Frontmatter returns the frontmatter block for a markdown file in the
+// format selected by opts, followed by a blank line. It returns "" for
+// FormatNone.
+func Frontmatter(m Meta, opts FrontmatterOptions) (string, error) {
+	fields := m.fields(opts)
+	switch opts.Format {
+	case "", FormatYAML:
+		return yamlFrontmatter(fields)
+	case FormatTOML:
+		return tomlFrontmatter(fields)
+	case FormatJSON:
+		return jsonFrontmatter(fields)
+	case FormatNone:
+		return "", nil
+	}
+	return "", fmt.Errorf("unknown frontmatter format %q", opts.Format)
+}
+
+// yamlFrontmatter encodes fields as a YAML mapping between --- lines. The
+// mapping is built node by node to keep the field order.
+func yamlFrontmatter(fields []field) (string, error) {
+	doc := &yaml.Node{Kind: yaml.MappingNode}
+	for _, f := range fields {
+		var value yaml.Node
+		if err := value.Encode(f.value); err != nil {
+			return "", fmt.Errorf("frontmatter %s: %w", f.key, err)
+		}
+		key := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: f.key}
+		doc.Content = append(doc.Content, key, &value)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("---\n")
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(doc); err != nil {
+		return "", err
+	}
+	if err := enc.Close(); err != nil {
+		return "", err
+	}
+	buf.WriteString("---\n\n")
+	return buf.String(), nil
+}
+
+// tomlFrontmatter encodes fields as TOML between +++ lines. Tables (og) come
+// after the plain keys, as TOML requires.
+func tomlFrontmatter(fields []field) (string, error) {
+	var buf bytes.Buffer
+	buf.WriteString("+++\n")
+	var tables []field
+	for _, f := range fields {
+		if _, ok := f.value.(map[string]string); ok {
+			tables = append(tables, f)
+			continue
+		}
+		if err := encodeTOML(&buf, f); err != nil {
+			return "", err
+		}
+	}
+	for _, f := range tables {
+		buf.WriteString("\n")
+		if err := encodeTOML(&buf, f); err != nil {
+			return "", err
+		}
+	}
+	buf.WriteString("+++\n\n")
+	return buf.String(), nil
+}
+
+func encodeTOML(buf *bytes.Buffer, f field) error {
+	enc := toml.NewEncoder(buf)
+	enc.Indent = ""
+	if err := enc.Encode(map[string]any{f.key: f.value}); err != nil {
+		return fmt.Errorf("frontmatter %s: %w", f.key, err)
+	}
+	return nil
+}
+
+// orderedObject is a JSON object that keeps its key order.
+type orderedObject []field
+
+func (o orderedObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, f := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := marshalJSON(f.key)
+		if err != nil {
+			return nil, err
+		}
+		value, err := marshalJSON(f.value)
+		if err != nil {
+			return nil, fmt.Errorf("frontmatter %s: %w", f.key, err)
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// jsonFrontmatter encodes fields as a JSON object, the form Hugo and other
+// static site generators accept without delimiters.
+func jsonFrontmatter(fields []field) (string, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(orderedObject(fields)); err != nil {
+		return "", err
+	}
+	buf.WriteString("\n")
+	return buf.String(), nil
+}
+
+func marshalJSON(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(buf.Bytes(), "\n"), nil
+}
+
+// WordCount returns the number of whitespace-separated words in s.
+func WordCount(s string) int {
+	return len(strings.Fields(s))
+}
+
+// EstimateTokens returns a rough LLM token count for s, at about four
+// characters per token.
+func EstimateTokens(s string) int {
+	return (utf8.RuneCountInString(s) + 3) / 4
+}
+
+// ContentHash returns the SHA-256 of s as "sha256:<hex>".
+func ContentHash(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,200 @@
+package writer
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+func testMeta() Meta {
+	return Meta{
+		Title:       `Install: "quick" <start>`,
+		Description: "How to install",
+		SourceURL:   "https://example.com/docs/install",
+		SourceMode:  "html",
+		CrawlDate:   time.Date(2026, 3, 4, 5, 6, 7, 890, time.UTC),
+		Lang:        "en",
+		Priority:    "0.8",
+		Keywords:    []string{"install", "setup"},
+		OpenGraph:   map[string]string{"type": "article"},
+		WordCount:   42,
+		ContentHash: ContentHash("body"),
+	}
+}
+
+func TestFrontmatterFormats(t *testing.T) {
+	m := testMeta()
+	wantKeys := []string{"title", "description", "source_url", "source_mode", "crawl_date", "lang", "priority", "keywords", "og", "word_count", "content_hash"}
+
+	t.Run("yaml", func(t *testing.T) {
+		out, err := Frontmatter(m, FrontmatterOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, ok := strings.CutPrefix(out, "---\n")
+		if !ok || !strings.HasSuffix(body, "---\n\n") {
+			t.Fatalf("bad delimiters:\n%s", out)
+		}
+		var doc yaml.Node
+		if err := yaml.Unmarshal([]byte(strings.TrimSuffix(body, "---\n\n")), &doc); err != nil {
+			t.Fatal(err)
+		}
+		var keys []string
+		for i, n := range doc.Content[0].Content {
+			if i%2 == 0 {
+				keys = append(keys, n.Value)
+			}
+		}
+		if strings.Join(keys, ",") != strings.Join(wantKeys, ",") {
+			t.Errorf("keys = %v, want %v", keys, wantKeys)
+		}
+		var got struct {
+			Title     string    `yaml:"title"`
+			CrawlDate time.Time `yaml:"crawl_date"`
+			Priority  float64   `yaml:"priority"`
+			Keywords  []string  `yaml:"keywords"`
+			OG        map[string]string
+		}
+		if err := doc.Decode(&got); err != nil {
+			t.Fatal(err)
+		}
+		if got.Title != m.Title || got.Priority != 0.8 || len(got.Keywords) != 2 {
+			t.Errorf("decoded %+v", got)
+		}
+		if !got.CrawlDate.Equal(m.CrawlDate.Truncate(time.Second)) {
+			t.Errorf("crawl_date = %v, want whole seconds", got.CrawlDate)
+		}
+	})
+
+	t.Run("toml", func(t *testing.T) {
+		out, err := Frontmatter(m, FrontmatterOptions{Format: FormatTOML})
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, ok := strings.CutPrefix(out, "+++\n")
+		if !ok || !strings.HasSuffix(body, "+++\n\n") {
+			t.Fatalf("bad delimiters:\n%s", out)
+		}
+		var got map[string]any
+		if _, err := toml.Decode(strings.TrimSuffix(body, "+++\n\n"), &got); err != nil {
+			t.Fatalf("decode: %v\n%s", err, out)
+		}
+		if got["title"] != m.Title || got["priority"] != 0.8 || got["word_count"] != int64(42) {
+			t.Errorf("decoded %v", got)
+		}
+		if og, _ := got["og"].(map[string]any); og["type"] != "article" {
+			t.Errorf("og = %v", got["og"])
+		}
+		if i := strings.Index(out, "[og]"); i < strings.Index(out, "content_hash") {
+			t.Errorf("og table must come after the plain keys:\n%s", out)
+		}
+	})
+
+	t.Run("json", func(t *testing.T) {
+		out, err := Frontmatter(m, FrontmatterOptions{Format: FormatJSON})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(out, "{\n") || !strings.HasSuffix(out, "}\n\n") {
+			t.Fatalf("bad shape:\n%s", out)
+		}
+		if strings.Contains(out, `\u003c`) || !strings.Contains(out, "<start>") {
+			t.Errorf("HTML escaped:\n%s", out)
+		}
+		var got map[string]any
+		if err := json.Unmarshal([]byte(out), &got); err != nil {
+			t.Fatal(err)
+		}
+		if got["title"] != m.Title || got["priority"] != 0.8 {
+			t.Errorf("decoded %v", got)
+		}
+		last := -1
+		for _, k := range wantKeys {
+			i := strings.Index(out, `"`+k+`":`)
+			if i < last {
+				t.Errorf("key %q out of order", k)
+			}
+			last = i
+		}
+	})
+
+	t.Run("none", func(t *testing.T) {
+		out, err := Frontmatter(m, FrontmatterOptions{Format: FormatNone})
+		if err != nil || out != "" {
+			t.Errorf("Frontmatter = %q, %v; want empty", out, err)
+		}
+	})
+}
+
+func TestFrontmatterFields(t *testing.T) {
+	tests := []struct {
+		name   string
+		meta   Meta
+		fields []string
+		want   []string
+	}{
+		{"required kept when empty", Meta{}, nil, []string{"title", "source_url", "source_mode", "crawl_date"}},
+		{"selection keeps output order", testMeta(), []string{"word_count", "title", "lang"}, []string{"title", "lang", "word_count"}},
+		{"selected empty optional omitted", Meta{}, []string{"title", "version"}, []string{"title"}},
+		{"non-numeric priority kept", Meta{Priority: "high"}, []string{"priority"}, []string{"priority"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var keys []string
+			for _, f := range tt.meta.fields(FrontmatterOptions{Fields: tt.fields}) {
+				keys = append(keys, f.key)
+			}
+			if strings.Join(keys, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("fields = %v, want %v", keys, tt.want)
+			}
+		})
+	}
+
+	f := Meta{Priority: "high"}.fields(FrontmatterOptions{Fields: []string{"priority"}})
+	if f[0].value != "high" {
+		t.Errorf("priority = %#v, want the string", f[0].value)
+	}
+}
+
+func TestFrontmatterValidate(t *testing.T) {
+	tests := []struct {
+		opts    FrontmatterOptions
+		wantErr string
+	}{
+		{FrontmatterOptions{}, ""},
+		{FrontmatterOptions{Format: FormatTOML, Fields: []string{"title", "og"}}, ""},
+		{FrontmatterOptions{Format: "xml"}, `unknown frontmatter format "xml"`},
+		{FrontmatterOptions{Fields: []string{"title", "author"}}, `unknown frontmatter field "author"`},
+	}
+	for _, tt := range tests {
+		err := tt.opts.Validate()
+		if tt.wantErr == "" {
+			if err != nil {
+				t.Errorf("Validate(%+v) = %v", tt.opts, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("Validate(%+v) = %v, want %q", tt.opts, err, tt.wantErr)
+		}
+	}
+	if _, err := Frontmatter(Meta{}, FrontmatterOptions{Format: "xml"}); err == nil {
+		t.Error("Frontmatter with an unknown format succeeded")
+	}
+}
+
+func TestCounts(t *testing.T) {
+	if n := WordCount(" one  two\nthree\t"); n != 3 {
+		t.Errorf("WordCount = %d, want 3", n)
+	}
+	if n := EstimateTokens("héllo"); n != 2 {
+		t.Errorf("EstimateTokens = %d, want 2", n)
+	}
+	if h := ContentHash(""); h != "sha256:e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855" {
+		t.Errorf("ContentHash = %s", h)
+	}
+}
@@ -0,0 +1,52 @@
+package writer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// ManifestFile is the name of the run manifest written next to the pages.
+const ManifestFile = "manifest.json"
+
+// Manifest describes the output of a run: every written page and the path it
+// was written to, so other tools can map URLs to files.
+type Manifest struct {
+	Generated time.Time       `json:"generated"`
+	Pages     []ManifestEntry `json:"pages"`
+	Skipped   []ManifestEntry `json:"skipped,omitempty"` // pages left out, with the path they would have had
+}
+
+// ManifestEntry is a single written or skipped page.
+type ManifestEntry struct {
+	URL         string   `json:"url"`
+	Path        string   `json:"path"`
+	Title       string   `json:"title,omitempty"`
+	Mode        string   `json:"mode,omitempty"`
+	Issues      []string `json:"issues,omitempty"`       // failed quality checks
+	Aliases     []string `json:"aliases,omitempty"`      // URLs of duplicate pages not written separately
+	DuplicateOf string   `json:"duplicate_of,omitempty"` // for skipped duplicates whose original isn't listed
+}
+
+// WriteManifest writes the manifest as indented JSON to the sink, with pages
+// sorted by path so the output is stable across runs.
+func WriteManifest(sink Sink, m *Manifest) error {
+	sort.Slice(m.Pages, func(i, j int) bool { return m.Pages[i].Path < m.Pages[j].Path })
+	sort.Slice(m.Skipped, func(i, j int) bool { return m.Skipped[i].Path < m.Skipped[j].Path })
+	for _, entries := range [][]ManifestEntry{m.Pages, m.Skipped} {
+		for _, e := range entries {
+			sort.Strings(e.Aliases)
+		}
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(m); err != nil {
+		return fmt.Errorf("encoding manifest: %w", err)
+	}
+	return sink.Put(ManifestFile, buf.Bytes())
+}
@@ -0,0 +1,257 @@
+package writer
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+const (
+	// maxSegmentLen is the longest path segment (in bytes) written as-is.
+	// Longer segments are truncated and given a hash suffix.
+	maxSegmentLen = 100
+	// maxPathLen keeps full paths under the Windows MAX_PATH limit with
+	// room for an output directory prefix.
+	maxPathLen = 200
+)
+
+// strippedExts are URL path extensions replaced by .md in output paths.
+var strippedExts = []string{".html", ".htm", ".php", ".aspx", ".mdx", ".md"}
+
+// windowsReserved are device names that cannot be used as file names on
+// Windows, with or without an extension.
+var windowsReserved = map[string]bool{
+	"con": true, "prn": true, "aux": true, "nul": true,
+	"com1": true, "com2": true, "com3": true, "com4": true, "com5": true,
+	"com6": true, "com7": true, "com8": true, "com9": true,
+	"lpt1": true, "lpt2": true, "lpt3": true, "lpt4": true, "lpt5": true,
+	"lpt6": true, "lpt7": true, "lpt8": true, "lpt9": true,
+}
+
+// PathMapper assigns each page URL a unique, portable output path. Paths are
+// sanitized for Windows, macOS and Linux, and collisions (including ones that
+// differ only by case or trailing slash) are resolved by adding a hash of the
+// URL, so the result depends only on the URLs and the order they are assigned.
+// The names of the files written next to the pages, manifest.json and
+// all-pages.md, are never assigned to a page.
+type PathMapper struct {
+	hostDirs bool
+
+	mu     sync.Mutex
+	byURL  map[string]string
+	taken  map[string]string // lowercased path or page key -> URL
+	hosts  map[string]bool
+	warned bool
+}
+
+// NewPathMapper creates a mapper. With hostDirs, every path is prefixed with
+// the URL's host so pages from different hosts never share a tree.
+func NewPathMapper(hostDirs bool) *PathMapper {
+	m := &PathMapper{
+		hostDirs: hostDirs,
+		byURL:    make(map[string]string),
+		taken:    make(map[string]string),
+		hosts:    make(map[string]bool),
+	}
+	for _, name := range []string{ManifestFile, SingleFile} {
+		m.taken[name] = ""
+	}
+	return m
+}
+
+// Assign returns the output path for rawURL, assigning a new one on first
+// use. The second return value is true if the path had to be disambiguated
+// because another URL already claimed it.
+func (m *PathMapper) Assign(rawURL string) (string, bool, error) {
+	return m.AssignUnder(rawURL, rawURL, "")
+}
+
+// AssignUnder is like Assign, but builds the path from pathURL instead of
+// rawURL and places it below dir. It is used to write versions of a page
+// into separate trees, with the version segment moved from the URL to dir.
+func (m *PathMapper) AssignUnder(rawURL, pathURL, dir string) (string, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if p, ok := m.byURL[rawURL]; ok {
+		return p, false, nil
+	}
+
+	u, err := url.Parse(pathURL)
+	if err != nil {
+		return "", false, fmt.Errorf("parsing URL %q: %w", pathURL, err)
+	}
+	m.hosts[u.Host] = true
+
+	base, key := m.basePath(u)
+	if dir != "" {
+		dir = sanitizeSegment(dir)
+		base = dir + "/" + base
+		key = dir + "/" + key
+	}
+	p := base + ".md"
+	collided := false
+	if m.isTaken(p, key) {
+		collided = true
+		p = base + "-" + shortHash(rawURL) + ".md"
+	}
+
+	m.taken[strings.ToLower(p)] = rawURL
+	m.taken[key] = rawURL
+	m.byURL[rawURL] = p
+	return p, collided, nil
+}
+
+// MultipleHosts reports whether URLs from more than one host were assigned
+// without host directories. It returns true only once, so callers can warn
+// a single time.
+func (m *PathMapper) MultipleHosts() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.hostDirs || m.warned || len(m.hosts) < 2 {
+		return false
+	}
+	m.warned = true
+	return true
+}
+
+func (m *PathMapper) isTaken(p, key string) bool {
+	if _, ok := m.taken[strings.ToLower(p)]; ok {
+		return true
+	}
+	_, ok := m.taken[key]
+	return ok
+}
+
+// basePath builds the sanitized path for u without the .md extension, and a
+// key identifying the logical page (case-insensitive, ignoring a trailing
+// slash) used to detect URLs that are variants of each other.
+func (m *PathMapper) basePath(u *url.URL) (base string, key string) {
+	p := u.Path
+	dirStyle := p == "" || strings.HasSuffix(p, "/")
+
+	var segments []string
+	if m.hostDirs {
+		segments = append(segments, sanitizeSegment(strings.ReplaceAll(u.Host, ":", "_")))
+	}
+	for _, seg := range strings.Split(p, "/") {
+		if seg == "" {
+			continue
+		}
+		segments = append(segments, seg)
+	}
+	if dirStyle {
+		segments = append(segments, "index")
+	}
+
+	last := segments[len(segments)-1]
+	for _, ext := range strippedExts {
+		if len(last) > len(ext) && strings.EqualFold(last[len(last)-len(ext):], ext) {
+			last = last[:len(last)-len(ext)]
+			break
+		}
+	}
+	if q := canonicalQuery(u.Query()); q != "" {
+		last += "_" + q
+	}
+	segments[len(segments)-1] = last
+
+	for i, seg := range segments {
+		segments[i] = sanitizeSegment(seg)
+	}
+
+	base = strings.Join(segments, "/")
+	if len(base)+len(".md") > maxPathLen {
+		// Keep the first directory for orientation and hash the rest.
+		hashed := longHash(base)
+		if len(segments) > 1 {
+			hashed = segments[0] + "/" + hashed
+		}
+		base = hashed
+	}
+
+	key = strings.TrimSuffix(u.Path, "/") + "?" + u.Query().Encode()
+	if m.hostDirs {
+		key = u.Host + key
+	}
+	return base, "key:" + strings.ToLower(key)
+}
+
+// canonicalQuery renders query parameters in a stable, filename-friendly
+// form: sorted by key, as key-value pairs joined by underscores.
+func canonicalQuery(q url.Values) string {
+	if len(q) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, k := range keys {
+		vals := append([]string(nil), q[k]...)
+		sort.Strings(vals)
+		for _, v := range vals {
+			if v == "" {
+				parts = append(parts, k)
+			} else {
+				parts = append(parts, k+"-"+v)
+			}
+		}
+	}
+	return strings.Join(parts, "_")
+}
+
+// sanitizeSegment makes a single path segment safe on all major file
+// systems: reserved and control characters become '_', trailing dots and
+// spaces are removed, Windows device names are prefixed, and over-long
+// segments are truncated with a hash suffix.
+func sanitizeSegment(seg string) string {
+	seg = strings.Map(func(r rune) rune {
+		switch {
+		case r < 0x20 || r == 0x7f:
+			return '_'
+		case strings.ContainsRune(`<>:"/\|?*%`, r):
+			return '_'
+		}
+		return r
+	}, seg)
+
+	seg = strings.TrimRight(seg, ". ")
+	if seg == "" {
+		seg = "_"
+	}
+
+	name := seg
+	if i := strings.IndexByte(name, '.'); i >= 0 {
+		name = name[:i]
+	}
+	if windowsReserved[strings.ToLower(name)] {
+		seg = "_" + seg
+	}
+
+	if len(seg) > maxSegmentLen {
+		cut := maxSegmentLen - 9
+		for cut > 0 && !utf8.RuneStart(seg[cut]) {
+			cut--
+		}
+		seg = seg[:cut] + "-" + shortHash(seg)
+	}
+	return seg
+}
+
+func shortHash(s string) string {
+	return longHash(s)[:8]
+}
+
+func longHash(s string) string {
+	sum := sha1.Sum([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
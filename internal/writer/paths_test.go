@@ -0,0 +1,146 @@
+package writer
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAssign(t *testing.T) {
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"https://example.com/", "index.md"},
+		{"https://example.com/docs/", "docs/index.md"},
+		{"https://example.com/docs/install", "docs/install.md"},
+		{"https://example.com/docs/setup.html", "docs/setup.md"},
+		{"https://example.com/api/page.MDX", "api/page.md"},
+		{"https://example.com/search?q=go&lang=en", "search_lang-en_q-go.md"},
+		{"https://example.com/a:b/c*d", "a_b/c_d.md"},
+		{"https://example.com/con/aux.txt", "_con/_aux.txt.md"},
+		{"https://example.com/trailing.", "trailing.md"},
+	}
+	for _, tt := range tests {
+		m := NewPathMapper(false)
+		got, collided, err := m.Assign(tt.url)
+		if err != nil {
+			t.Fatalf("Assign(%q): %v", tt.url, err)
+		}
+		if got != tt.want || collided {
+			t.Errorf("Assign(%q) = %q, %v; want %q, false", tt.url, got, collided, tt.want)
+		}
+	}
+}
+
+func TestAssignCollisions(t *testing.T) {
+	m := NewPathMapper(false)
+	first, _, _ := m.Assign("https://example.com/Guide")
+	if first != "Guide.md" {
+		t.Fatalf("first = %q", first)
+	}
+
+	tests := []struct{ url, base string }{
+		{"https://example.com/guide", "guide"},
+		{"https://example.com/Guide/", "Guide/index"},
+		{"https://example.com/guide.html", "guide"},
+	}
+	for _, tt := range tests {
+		p, collided, err := m.Assign(tt.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := tt.base + "-" + shortHash(tt.url) + ".md"; p != want || !collided {
+			t.Errorf("Assign(%q) = %q, %v; want %q, true", tt.url, p, collided, want)
+		}
+	}
+
+	// The same URL always gets the same path
+	again, collided, _ := m.Assign("https://example.com/Guide")
+	if again != first || collided {
+		t.Errorf("reassign = %q, %v; want %q, false", again, collided, first)
+	}
+}
+
+func TestAssignReservesOutputFiles(t *testing.T) {
+	m := NewPathMapper(false)
+	for _, u := range []string{"https://example.com/all-pages", "https://example.com/All-Pages.html"} {
+		p, collided, err := m.Assign(u)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !collided || strings.EqualFold(p, SingleFile) {
+			t.Errorf("Assign(%q) = %q, %v; want a path other than %s", u, p, collided, SingleFile)
+		}
+	}
+
+	// Pages in subdirectories don't clash with the output files
+	p, collided, _ := m.Assign("https://example.com/docs/all-pages")
+	if p != "docs/all-pages.md" || collided {
+		t.Errorf("Assign(docs/all-pages) = %q, %v", p, collided)
+	}
+}
+
+func TestAssignLongPaths(t *testing.T) {
+	m := NewPathMapper(false)
+	long := strings.Repeat("a", 150)
+	p, _, err := m.Assign("https://example.com/docs/" + long)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seg := strings.TrimSuffix(strings.TrimPrefix(p, "docs/"), ".md")
+	if len(seg) > maxSegmentLen {
+		t.Errorf("segment is %d bytes, want at most %d", len(seg), maxSegmentLen)
+	}
+
+	deep := "https://example.com/" + strings.Repeat(strings.Repeat("b", 60)+"/", 5) + "page"
+	p, _, err = m.Assign(deep)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p) > maxPathLen {
+		t.Errorf("path is %d bytes, want at most %d", len(p), maxPathLen)
+	}
+}
+
+func TestAssignHostDirs(t *testing.T) {
+	m := NewPathMapper(true)
+	a, _, _ := m.Assign("https://docs.example.com/guide")
+	b, _, _ := m.Assign("http://localhost:8080/guide")
+	if a != "docs.example.com/guide.md" || b != "localhost_8080/guide.md" {
+		t.Errorf("paths = %q, %q", a, b)
+	}
+	if m.MultipleHosts() {
+		t.Error("MultipleHosts with host directories = true")
+	}
+}
+
+func TestMultipleHostsWarnsOnce(t *testing.T) {
+	m := NewPathMapper(false)
+	m.Assign("https://a.example.com/x")
+	if m.MultipleHosts() {
+		t.Error("MultipleHosts with one host = true")
+	}
+	m.Assign("https://b.example.com/x")
+	if !m.MultipleHosts() {
+		t.Error("MultipleHosts with two hosts = false")
+	}
+	if m.MultipleHosts() {
+		t.Error("MultipleHosts reported twice")
+	}
+}
+
+func TestAssignUnder(t *testing.T) {
+	m := NewPathMapper(false)
+	p, _, err := m.AssignUnder("https://example.com/v2/guide", "https://example.com/guide", "v2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p != "v2/guide.md" {
+		t.Errorf("AssignUnder = %q, want v2/guide.md", p)
+	}
+	// The same page in another version doesn't collide
+	p, collided, _ := m.AssignUnder("https://example.com/v1/guide", "https://example.com/guide", "v1")
+	if p != "v1/guide.md" || collided {
+		t.Errorf("AssignUnder = %q, %v", p, collided)
+	}
+}
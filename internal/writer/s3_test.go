@@ -0,0 +1,196 @@
+package writer
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func setS3Credentials(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY")
+	t.Setenv("AWS_SESSION_TOKEN", "")
+}
+
+func TestS3SignKnownRequest(t *testing.T) {
+	setS3Credentials(t)
+	s, err := NewS3Sink(context.Background(), "s3://docs/site", "https://minio.example.com", "us-east-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payload := []byte("# Hello\n")
+	req, err := http.NewRequest(http.MethodPut, "https://minio.example.com/docs/site/guide/a%20b.md", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "text/markdown; charset=utf-8")
+	s.sign(req, payload, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC))
+
+	want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20240102/us-east-1/s3/aws4_request, " +
+		"SignedHeaders=content-type;host;x-amz-content-sha256;x-amz-date, " +
+		"Signature=481417fa7738c282d408563a55423d48b4c7a5d54861fa4e63bbb9a388773508"
+	if got := req.Header.Get("Authorization"); got != want {
+		t.Errorf("Authorization =\n  %s\nwant\n  %s", got, want)
+	}
+	if got := req.Header.Get("X-Amz-Date"); got != "20240102T030405Z" {
+		t.Errorf("X-Amz-Date = %q", got)
+	}
+}
+
+type s3Upload struct {
+	path, contentType, auth, sha string
+	body                         string
+}
+
+func TestS3SinkPut(t *testing.T) {
+	setS3Credentials(t)
+	t.Setenv("AWS_SESSION_TOKEN", "session")
+
+	var mu sync.Mutex
+	var uploads []s3Upload
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			t.Errorf("method = %s, want PUT", r.Method)
+		}
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		uploads = append(uploads, s3Upload{
+			path:        r.URL.EscapedPath(),
+			contentType: r.Header.Get("Content-Type"),
+			auth:        r.Header.Get("Authorization"),
+			sha:         r.Header.Get("X-Amz-Content-Sha256"),
+			body:        string(body),
+		})
+		mu.Unlock()
+		if r.Header.Get("X-Amz-Security-Token") != "session" {
+			t.Errorf("X-Amz-Security-Token = %q", r.Header.Get("X-Amz-Security-Token"))
+		}
+	}))
+	defer srv.Close()
+
+	s, err := NewS3Sink(context.Background(), "s3://bucket/prefix/", srv.URL, "eu-west-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Put("guide/a b.md", []byte("# A\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Put("manifest.json", []byte("{}")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(uploads) != 2 {
+		t.Fatalf("got %d uploads, want 2", len(uploads))
+	}
+	want := []struct{ path, contentType, body string }{
+		{"/bucket/prefix/guide/a%20b.md", "text/markdown; charset=utf-8", "# A\n"},
+		{"/bucket/prefix/manifest.json", "application/json", "{}"},
+	}
+	for i, w := range want {
+		u := uploads[i]
+		if u.path != w.path || u.contentType != w.contentType || u.body != w.body {
+			t.Errorf("upload %d = %s %q %q, want %s %q %q", i, u.path, u.contentType, u.body, w.path, w.contentType, w.body)
+		}
+		if u.sha != sha256Hex([]byte(w.body)) {
+			t.Errorf("upload %d: X-Amz-Content-Sha256 = %s", i, u.sha)
+		}
+		prefix := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/"
+		if !strings.HasPrefix(u.auth, prefix) ||
+			!strings.Contains(u.auth, "/eu-west-1/s3/aws4_request, SignedHeaders=content-type;host;x-amz-content-sha256;x-amz-date;x-amz-security-token, Signature=") {
+			t.Errorf("upload %d: Authorization = %s", i, u.auth)
+		}
+	}
+}
+
+func TestS3SinkPutError(t *testing.T) {
+	setS3Credentials(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "<Error><Code>AccessDenied</Code></Error>", http.StatusForbidden)
+	}))
+	defer srv.Close()
+
+	s, err := NewS3Sink(context.Background(), "s3://bucket", srv.URL, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Put("a.md", []byte("x"))
+	if err == nil || !strings.Contains(err.Error(), "HTTP 403") || !strings.Contains(err.Error(), "AccessDenied") {
+		t.Errorf("Put error = %v, want HTTP 403 with the response body", err)
+	}
+}
+
+func TestS3SinkPutCancelled(t *testing.T) {
+	setS3Credentials(t)
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s, err := NewS3Sink(ctx, "s3://bucket", srv.URL, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.AfterFunc(50*time.Millisecond, cancel)
+	if err := s.Put("a.md", []byte("x")); !errors.Is(err, context.Canceled) {
+		t.Errorf("Put error = %v, want context.Canceled", err)
+	}
+}
+
+func TestS3SinkSetContext(t *testing.T) {
+	setS3Credentials(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	s, err := NewS3Sink(ctx, "s3://bucket", srv.URL, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Put("a.md", []byte("x")); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Put error = %v, want context.Canceled", err)
+	}
+	s.SetContext(context.Background())
+	if err := s.Put("a.md", []byte("x")); err != nil {
+		t.Errorf("Put after SetContext: %v", err)
+	}
+}
+
+func TestNewS3SinkValidates(t *testing.T) {
+	setS3Credentials(t)
+	if _, err := NewS3Sink(context.Background(), "s3:///prefix", "", ""); err == nil {
+		t.Error("missing bucket: want error")
+	}
+	if _, err := NewS3Sink(context.Background(), "s3://bucket", "not a url", ""); err == nil {
+		t.Error("bad endpoint: want error")
+	}
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "")
+	if _, err := NewS3Sink(context.Background(), "s3://bucket", "", ""); err == nil {
+		t.Error("missing credentials: want error")
+	}
+}
+
+func TestNewS3SinkDefaultsRegion(t *testing.T) {
+	setS3Credentials(t)
+	t.Setenv("AWS_REGION", "ap-south-1")
+	s, err := NewS3Sink(context.Background(), "s3://bucket/p", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.region != "ap-south-1" || s.endpoint.Host != "s3.ap-south-1.amazonaws.com" {
+		t.Errorf("region %q endpoint %q", s.region, s.endpoint.Host)
+	}
+}
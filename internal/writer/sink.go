@@ -0,0 +1,129 @@
+package writer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Sink is a destination for output files. Names are slash-separated paths
+// relative to the root of the output. Put is called from a single goroutine;
+// Close flushes and releases the sink and must be called once at the end.
+type Sink interface {
+	Put(name string, data []byte) error
+	Close() error
+}
+
+// ContextSink is a Sink whose uploads run under a context that can be
+// replaced, so the last files of a run can get a deadline of their own.
+type ContextSink interface {
+	Sink
+	SetContext(ctx context.Context)
+}
+
+// SinkOptions holds settings for sinks that need more than a location.
+type SinkOptions struct {
+	S3Endpoint string // S3-compatible endpoint URL; empty = AWS for S3Region
+	S3Region   string
+}
+
+// OpenSink picks a sink based on the output location:
+//
+//	s3://bucket/prefix      S3-compatible object store
+//	*.tar.gz, *.tgz         gzipped tar archive streamed to disk
+//	*.zip                   zip archive streamed to disk
+//	anything else           directory tree
+//
+// Cancelling ctx aborts uploads to an object store.
+func OpenSink(ctx context.Context, output string, opts SinkOptions) (Sink, error) {
+	switch outputKind(output) {
+	case kindS3:
+		return NewS3Sink(ctx, output, opts.S3Endpoint, opts.S3Region)
+	case kindTarGz:
+		return NewTarGzSink(output)
+	case kindZip:
+		return NewZipSink(output)
+	default:
+		return NewDirSink(output), nil
+	}
+}
+
+// IsDirOutput reports whether output would be written as a directory tree.
+func IsDirOutput(output string) bool {
+	return outputKind(output) == kindDir
+}
+
+const (
+	kindDir = iota
+	kindTarGz
+	kindZip
+	kindS3
+)
+
+func outputKind(output string) int {
+	lower := strings.ToLower(output)
+	switch {
+	case strings.HasPrefix(lower, "s3://"):
+		return kindS3
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		return kindTarGz
+	case strings.HasSuffix(lower, ".zip"):
+		return kindZip
+	default:
+		return kindDir
+	}
+}
+
+// DirSink writes files into a directory tree. Each file is written to a
+// temporary file in the target directory and renamed into place, so an
+// interrupted run never leaves a half-written file behind.
+type DirSink struct {
+	root string
+}
+
+// NewDirSink creates a sink rooted at dir.
+func NewDirSink(dir string) *DirSink {
+	return &DirSink{root: dir}
+}
+
+// Put atomically writes data to name under the sink's root.
+func (s *DirSink) Put(name string, data []byte) error {
+	path := filepath.Join(s.root, filepath.FromSlash(name))
+
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("creating directory for %s: %w", path, err)
+	}
+
+	tmp, err := os.CreateTemp(dir, ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("writing %s: %w", path, err)
+	}
+	tmpName := tmp.Name()
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmpName)
+		return fmt.Errorf("writing %s: %w", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpName)
+		return fmt.Errorf("writing %s: %w", path, err)
+	}
+	if err := os.Chmod(tmpName, 0644); err != nil {
+		os.Remove(tmpName)
+		return fmt.Errorf("writing %s: %w", path, err)
+	}
+	if err := os.Rename(tmpName, path); err != nil {
+		os.Remove(tmpName)
+		return fmt.Errorf("writing %s: %w", path, err)
+	}
+	return nil
+}
+
+// Close is a no-op for directory sinks.
+func (s *DirSink) Close() error {
+	return nil
+}
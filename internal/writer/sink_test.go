@@ -0,0 +1,86 @@
+package writer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOutputKind(t *testing.T) {
+	tests := []struct {
+		output string
+		want   int
+	}{
+		{"./docs", kindDir},
+		{"docs.tar.gz", kindTarGz},
+		{"DOCS.TGZ", kindTarGz},
+		{"out/docs.zip", kindZip},
+		{"s3://bucket/prefix", kindS3},
+		{"S3://bucket", kindS3},
+		{"docs.tar", kindDir},
+	}
+	for _, tt := range tests {
+		if got := outputKind(tt.output); got != tt.want {
+			t.Errorf("outputKind(%q) = %d, want %d", tt.output, got, tt.want)
+		}
+	}
+}
+
+func TestOpenSinkPicksKind(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		output string
+		want   string
+	}{
+		{filepath.Join(dir, "tree"), "*writer.DirSink"},
+		{filepath.Join(dir, "a.tar.gz"), "*writer.TarGzSink"},
+		{filepath.Join(dir, "a.zip"), "*writer.ZipSink"},
+	}
+	for _, tt := range tests {
+		s, err := OpenSink(context.Background(), tt.output, SinkOptions{})
+		if err != nil {
+			t.Fatalf("OpenSink(%q): %v", tt.output, err)
+		}
+		if got := fmt.Sprintf("%T", s); got != tt.want {
+			t.Errorf("OpenSink(%q) = %s, want %s", tt.output, got, tt.want)
+		}
+		s.Close()
+	}
+}
+
+func TestDirSinkPut(t *testing.T) {
+	root := t.TempDir()
+	s := NewDirSink(root)
+	if err := s.Put("guide/a.md", []byte("first")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Put("guide/a.md", []byte("second")); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(root, "guide", "a.md")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "second" {
+		t.Errorf("content = %q, want %q", data, "second")
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0644 {
+		t.Errorf("mode = %o, want 644", info.Mode().Perm())
+	}
+
+	entries, err := os.ReadDir(filepath.Join(root, "guide"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("directory has %d entries, want only a.md (no temporary files)", len(entries))
+	}
+}
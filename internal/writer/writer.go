@@ -2,123 +2,88 @@ package writer
 
 import (
 	"fmt"
-	"net/url"
-	"os"
-	"path/filepath"
 	"strings"
 	"time"
 )
 
-// Frontmatter returns a YAML frontmatter block for a markdown file.
-func Frontmatter(title string, sourceURL string, crawlDate time.Time) string {
-	safeTitle := escapeYAML(title)
-	return fmt.Sprintf("---\ntitle: %s\nsource_url: %s\ncrawl_date: %s\n---\n\n",
-		safeTitle,
-		sourceURL,
-		crawlDate.Format(time.RFC3339),
-	)
-}
-
-// WriteMarkdown writes a markdown file to disk, mirroring the URL path structure.
-func WriteMarkdown(outputDir string, sourceURL string, title string, markdown string) error {
-	path, err := URLToFilePath(outputDir, sourceURL)
-	if err != nil {
-		return err
-	}
-
-	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
-		return fmt.Errorf("creating directory for %s: %w", path, err)
-	}
-
-	if err := os.WriteFile(path, []byte(markdown), 0644); err != nil {
-		return fmt.Errorf("writing %s: %w", path, err)
-	}
-
-	return nil
+// Meta holds the fields written to a page's frontmatter. Optional fields are
+// omitted when empty.
+type Meta struct {
+	Title        string
+	Description  string // meta description
+	SourceURL    string
+	CanonicalURL string // <link rel="canonical">
+	SourceMode   string // how the page was produced (e.g. "markdown" or "html")
+	CrawlDate    time.Time
+	Lang         string            // from sitemap hreflang alternates or <html lang>
+	LastMod      string            // from the sitemap
+	Modified     string            // from article:modified_time or "Last updated" text
+	ChangeFreq   string            // from the sitemap
+	Priority     string            // from the sitemap
+	Version      string            // docs version from the URL or HTML markers
+	Keywords     []string          // meta keywords
+	Breadcrumbs  []string          // breadcrumb trail, outermost first
+	OpenGraph    map[string]string // og:* tags without the prefix
+	WordCount    int
+	Tokens       int    // estimated LLM tokens in the body
+	ContentHash  string // "sha256:<hex>" of the body
 }
 
 // PageResult holds a processed page for single-file concatenation.
 type PageResult struct {
-	URL      string
-	Title    string
-	Markdown string
+	URL         string
+	Title       string
+	Markdown    string
+	TitleInBody bool // Markdown already has the title as its H1
 }
 
+// SingleFile is the name of the file WriteSingleFile writes.
+const SingleFile = "all-pages.md"
+
 // WriteSingleFile concatenates all pages into a single all-pages.md with a TOC.
-func WriteSingleFile(outputDir string, pages []PageResult) error {
+// Each page section starts with an explicit anchor, heading ids are prefixed
+// with it, and links between the pages are rewritten to point inside the file.
+func WriteSingleFile(sink Sink, pages []PageResult) error {
 	var sb strings.Builder
 
+	anchors := pageAnchors(pages)
+	urls := make(map[string]int, len(pages))
+	ids := make([]map[string]bool, len(pages))
+	for i, p := range pages {
+		if _, ok := urls[p.URL]; !ok {
+			urls[p.URL] = i
+		}
+		ids[i] = pageIDs(p.Markdown)
+	}
+
 	// Table of contents
 	sb.WriteString("# Documentation Index\n\n")
 	for i, p := range pages {
-		anchor := slugify(p.Title)
-		if anchor == "" {
-			anchor = fmt.Sprintf("page-%d", i+1)
-		}
 		title := p.Title
 		if title == "" {
 			title = p.URL
 		}
-		sb.WriteString(fmt.Sprintf("- [%s](#%s)\n", title, anchor))
+		sb.WriteString(fmt.Sprintf("- [%s](#%s)\n", title, anchors[i]))
 	}
 	sb.WriteString("\n---\n\n")
 
 	// Pages
-	for _, p := range pages {
+	for i, p := range pages {
 		title := p.Title
 		if title == "" {
 			title = p.URL
 		}
-		sb.WriteString(fmt.Sprintf("## %s\n\n", title))
+		sb.WriteString(fmt.Sprintf("<a id=\"%s\"></a>\n\n", anchors[i]))
+		// The page's own H1 serves as the section heading
+		if !p.TitleInBody {
+			sb.WriteString(fmt.Sprintf("## %s\n\n", title))
+		}
 		sb.WriteString(fmt.Sprintf("*Source: %s*\n\n", p.URL))
-		sb.WriteString(p.Markdown)
+		sb.WriteString(namespaceAnchors(p.Markdown, i, urls, anchors, ids))
 		sb.WriteString("\n\n---\n\n")
 	}
 
-	path := filepath.Join(outputDir, "all-pages.md")
-	if err := os.MkdirAll(outputDir, 0755); err != nil {
-		return fmt.Errorf("creating output directory: %w", err)
-	}
-	return os.WriteFile(path, []byte(sb.String()), 0644)
-}
-
-// URLToFilePath converts a page URL to a filesystem path under outputDir.
-func URLToFilePath(outputDir string, rawURL string) (string, error) {
-	u, err := url.Parse(rawURL)
-	if err != nil {
-		return "", fmt.Errorf("parsing URL %q: %w", rawURL, err)
-	}
-
-	p := strings.TrimPrefix(u.Path, "/")
-	if p == "" || strings.HasSuffix(p, "/") {
-		p += "index"
-	}
-
-	// Strip known extensions
-	for _, ext := range []string{".html", ".htm", ".php"} {
-		p = strings.TrimSuffix(p, ext)
-	}
-
-	p += ".md"
-
-	return filepath.Join(outputDir, p), nil
-}
-
-// escapeYAML wraps a string in double quotes if it contains characters that
-// could break YAML parsing.
-func escapeYAML(s string) string {
-	if s == "" {
-		return `""`
-	}
-	needsQuoting := strings.ContainsAny(s, `:#"'{}[]|>&*!%@`+"`") ||
-		strings.HasPrefix(s, " ") ||
-		strings.HasPrefix(s, "-")
-	if needsQuoting {
-		escaped := strings.ReplaceAll(s, `\`, `\\`)
-		escaped = strings.ReplaceAll(escaped, `"`, `\"`)
-		return `"` + escaped + `"`
-	}
-	return s
+	return sink.Put(SingleFile, []byte(sb.String()))
 }
 
 // slugify creates a markdown-compatible anchor from a heading string.
@@ -0,0 +1,604 @@
+// Package cloner clones documentation sites into markdown. It resolves a
+// sitemap (or a feed, URL list or llms.txt), fetches and converts each page
+// concurrently, and writes the results through a pluggable Writer. The
+// docs-cloner CLI is a thin wrapper around this package.
+package cloner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"log"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Devon-White/docs-cloner/internal/config"
+	"github.com/Devon-White/docs-cloner/internal/converter"
+	"github.com/Devon-White/docs-cloner/internal/dedupe"
+	"github.com/Devon-White/docs-cloner/internal/extractor"
+	"github.com/Devon-White/docs-cloner/internal/fetcher"
+	"github.com/Devon-White/docs-cloner/internal/quality"
+	"github.com/Devon-White/docs-cloner/internal/render"
+	"github.com/Devon-White/docs-cloner/internal/sitemap"
+	"github.com/Devon-White/docs-cloner/internal/urlfilter"
+	"github.com/Devon-White/docs-cloner/internal/versions"
+	"github.com/Devon-White/docs-cloner/internal/writer"
+)
+
+// Config holds all options for a clone run. It is the same type the CLI
+// populates from its flags.
+type Config = config.Config
+
+// Response is a fetched document as returned by a Fetcher.
+type Response = fetcher.Response
+
+// SitemapEntry is a page's <url> entry from the sitemap, including lastmod,
+// changefreq, priority and hreflang alternates.
+type SitemapEntry = sitemap.URL
+
+// PageMetadata is page-level metadata collected from a page's HTML: meta
+// description, canonical URL, Open Graph tags, keywords, breadcrumbs and the
+// last modification date.
+type PageMetadata = extractor.PageMeta
+
+// Source modes recorded in Page.Mode.
+const (
+	ModeMarkdown     = "markdown"      // raw markdown via Config.FetchMD, or a linked .md file
+	ModeHTML         = "html"          // HTML extraction and conversion
+	ModeHTMLFallback = "html-fallback" // HTML path after raw markdown failed
+	ModeNegotiated   = "negotiated"    // markdown via Config.AcceptMD
+	ModeEmbedded     = "embedded"      // content from Next.js, Nuxt or Gatsby page data
+	ModeRendered     = "rendered"      // HTML path on the DOM returned by the Renderer
+)
+
+// Page is a single converted documentation page.
+type Page struct {
+	URL       string
+	Path      string // slash-separated output path assigned to the page
+	Title     string
+	Markdown  string // page body, without frontmatter
+	Mode      string // one of the Mode* constants
+	Version   string // docs version from the URL or HTML markers; empty if unknown
+	CrawlDate time.Time
+	Sitemap   SitemapEntry
+	Metadata  PageMetadata // empty for pages fetched as markdown
+	Issues    []string     // quality checks the page failed; empty for good pages
+
+	// DuplicateOf is the URL of an earlier page in the run with the same
+	// canonical URL or (nearly) the same content. Run doesn't write such
+	// pages; the default Writer records them as aliases of that page.
+	DuplicateOf string
+
+	pageWords int    // words in the whole HTML page, for the text ratio check
+	dupReason string // why the page is a duplicate, for logging
+}
+
+// Values for Config.OnThin, deciding what happens to pages that fail a
+// quality check.
+const (
+	OnThinSkip  = "skip"  // leave the page out and record it in the manifest
+	OnThinWarn  = "warn"  // write the page and log a warning
+	OnThinWrite = "write" // write the page; issues are only recorded in the manifest
+)
+
+// PlannedPage is a page that a run would clone, as reported by Plan.
+type PlannedPage struct {
+	URL     string
+	Path    string // slash-separated output path the page would be written to
+	Version string // version segment of the URL, if any
+	Sitemap SitemapEntry
+}
+
+// Fetcher retrieves a URL. accept, if non-empty, is the Accept header to send.
+// Implementations must return an error for non-2xx responses.
+type Fetcher interface {
+	Get(ctx context.Context, url string, accept string) (*Response, error)
+}
+
+// Extractor isolates the main content of an HTML page and returns it as an
+// HTML fragment along with the page title.
+type Extractor interface {
+	Extract(htmlBody []byte, sourceURL string) (contentHTML string, title string, err error)
+}
+
+// Converter turns an extracted HTML fragment into markdown.
+type Converter interface {
+	Convert(contentHTML string, sourceURL string) (string, error)
+}
+
+// Renderer returns the DOM of a page after its JavaScript has run. It is used
+// for pages that are empty app shells or fail a quality check when fetched,
+// and whose content isn't in Next.js, Nuxt or Gatsby page data.
+type Renderer interface {
+	Render(ctx context.Context, url string) ([]byte, error)
+}
+
+// Writer persists converted pages. WritePage is called from a single
+// goroutine as pages complete; Close is called once after the last page.
+type Writer interface {
+	WritePage(p Page) error
+	Close() error
+}
+
+// SkipRecorder is implemented by Writers that record pages left out of the
+// output: thin pages with Config.OnThin set to OnThinSkip, and duplicates
+// (Page.DuplicateOf set). Run calls SkipPage instead of WritePage for them.
+type SkipRecorder interface {
+	SkipPage(p Page) error
+}
+
+// Cloner runs documentation clones. Create one with New.
+type Cloner struct {
+	cfg       Config
+	fetcher   Fetcher
+	extractor Extractor
+	converter Converter
+	renderer  Renderer // nil = no JavaScript rendering
+	writer    Writer
+	logger    *log.Logger
+	paths     *writer.PathMapper
+
+	mdPatterns *converter.PatternSet // nil = HTML-to-markdown mode
+	versions   versions.Selector
+	titleStrip *regexp.Regexp // --title-strip; nil = strip the shared site name
+	quality    *quality.Checker
+	closers    []io.Closer // fetchers created by New, closed after a run
+}
+
+// Option customizes a Cloner.
+type Option func(*Cloner)
+
+// WithFetcher replaces the default HTTP fetcher.
+func WithFetcher(f Fetcher) Option {
+	return func(c *Cloner) { c.fetcher = f }
+}
+
+// WithExtractor replaces the default CSS-selector content extractor.
+func WithExtractor(e Extractor) Option {
+	return func(c *Cloner) { c.extractor = e }
+}
+
+// WithConverter replaces the default HTML-to-markdown converter.
+func WithConverter(conv Converter) Option {
+	return func(c *Cloner) { c.converter = conv }
+}
+
+// WithRenderer sets the renderer used for JavaScript-rendered pages,
+// replacing the one configured by Config.RenderCmd or Config.RenderURL.
+func WithRenderer(r Renderer) Option {
+	return func(c *Cloner) { c.renderer = r }
+}
+
+// WithWriter replaces the default writer, which mirrors the site structure
+// in Config.OutputDir (a directory, a .tar.gz or .zip archive, or an
+// s3://bucket/prefix location).
+func WithWriter(w Writer) Option {
+	return func(c *Cloner) { c.writer = w }
+}
+
+// WithLogger sets the logger used for progress output. The default is the
+// standard library's default logger.
+func WithLogger(l *log.Logger) Option {
+	return func(c *Cloner) { c.logger = l }
+}
+
+// New creates a Cloner from cfg. Components not supplied through options use
+// the same defaults as the CLI.
+func New(cfg Config, opts ...Option) (*Cloner, error) {
+	switch sources := countSet(cfg.SitemapURL, cfg.URLsFrom, cfg.FromDir); {
+	case sources == 0:
+		return nil, fmt.Errorf("sitemap URL is required")
+	case sources > 1:
+		return nil, fmt.Errorf("only one of --url, --urls-from and --from-dir can be used")
+	}
+	if cfg.FromDir != "" && cfg.BaseURL == "" {
+		return nil, fmt.Errorf("--from-dir requires --base-url")
+	}
+	if countSet(cfg.FromDir, cfg.WARCIn, cfg.Replay) > 1 {
+		return nil, fmt.Errorf("only one of --from-dir, --warc-in and --replay can be used")
+	}
+	if cfg.Concurrency < 1 {
+		return nil, fmt.Errorf("concurrency must be at least 1")
+	}
+	if cfg.DelayMS < 0 {
+		return nil, fmt.Errorf("delay must be non-negative")
+	}
+	if cfg.ConnectTimeout < 0 || cfg.HeaderTimeout < 0 || cfg.Timeout < 0 {
+		return nil, fmt.Errorf("timeouts must be non-negative")
+	}
+	if cfg.MaxBodySize < 0 || cfg.MaxPages < 0 || cfg.MaxDuration < 0 {
+		return nil, fmt.Errorf("--max-body-size, --max-pages and --max-duration must be non-negative")
+	}
+	if cfg.GracePeriod < 0 {
+		return nil, fmt.Errorf("--grace-period must be non-negative")
+	}
+	if cfg.SitemapMaxDepth < 0 {
+		return nil, fmt.Errorf("sitemap max depth must be non-negative")
+	}
+	if len(cfg.FetchMD) > 1 && slices.Contains(cfg.FetchMD, converter.AutoPattern) {
+		return nil, fmt.Errorf("--fetch-md auto cannot be combined with other patterns")
+	}
+	if cfg.FetchMDMatch != "" && len(cfg.FetchMD) == 0 {
+		return nil, fmt.Errorf("--fetch-md-match requires --fetch-md")
+	}
+
+	if _, err := urlfilter.Compile(urlRules(&cfg)); err != nil {
+		return nil, err
+	}
+
+	if err := frontmatterOptions(&cfg).Validate(); err != nil {
+		return nil, fmt.Errorf("invalid --frontmatter: %w", err)
+	}
+
+	mdPatterns, err := mdPatternSet(&cfg)
+	if err != nil {
+		return nil, err
+	}
+	versionSel, err := versions.ParseSelector(cfg.Versions)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --versions: %w", err)
+	}
+
+	if err := extractor.ValidateTitleFrom(cfg.TitleFrom); err != nil {
+		return nil, fmt.Errorf("invalid --title-from: %w", err)
+	}
+	switch cfg.OnThin {
+	case "", OnThinSkip, OnThinWarn, OnThinWrite:
+	default:
+		return nil, fmt.Errorf("invalid --on-thin %q (want skip, warn or write)", cfg.OnThin)
+	}
+	if cfg.NearDuplicates < 0 || cfg.NearDuplicates > dedupe.MaxDistance {
+		return nil, fmt.Errorf("--near-duplicates must be between 0 and %d", dedupe.MaxDistance)
+	}
+	if cfg.MinWords < 0 || cfg.MinTextRatio < 0 || cfg.MinTextRatio > 1 {
+		return nil, fmt.Errorf("--min-words must be non-negative and --min-text-ratio between 0 and 1")
+	}
+	if err := converter.ValidateAnchors(cfg.HeadingAnchors); err != nil {
+		return nil, fmt.Errorf("invalid --heading-anchors: %w", err)
+	}
+	titleStrip, err := compileTitleStrip(cfg.TitleStrip)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --title-strip: %w", err)
+	}
+	if cfg.RenderCmd != "" && cfg.RenderURL != "" {
+		return nil, fmt.Errorf("only one of --render-cmd and --render-url can be used")
+	}
+
+	c := &Cloner{
+		cfg:        cfg,
+		logger:     log.Default(),
+		paths:      writer.NewPathMapper(cfg.HostDirs),
+		mdPatterns: mdPatterns,
+		versions:   versionSel,
+		titleStrip: titleStrip,
+	}
+	c.quality = quality.New(c.qualityOptions())
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if c.renderer == nil {
+		if err := c.defaultRenderer(); err != nil {
+			return nil, err
+		}
+	}
+	if c.fetcher == nil {
+		if err := c.defaultFetcher(); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.WARCOut != "" {
+		rec, err := fetcher.NewWARCRecorder(c.fetcher, cfg.WARCOut, cfg.UserAgent)
+		if err != nil {
+			c.closeFetchers()
+			return nil, fmt.Errorf("--warc-out: %w", err)
+		}
+		c.fetcher = rec
+		c.closers = append(c.closers, rec)
+	}
+	if cfg.Record != "" {
+		rec, err := fetcher.NewFixtureRecorder(c.fetcher, cfg.Record)
+		if err != nil {
+			c.closeFetchers()
+			return nil, fmt.Errorf("--record: %w", err)
+		}
+		c.fetcher = rec
+	}
+	if c.extractor == nil {
+		c.extractor = &selectorExtractor{selector: cfg.Selector, titleFrom: cfg.TitleFrom}
+	}
+	if c.converter == nil {
+		c.converter = htmlConverter{anchors: cfg.HeadingAnchors}
+	}
+	if c.writer == nil {
+		c.writer = newSinkWriter(&c.cfg, c.logger)
+	}
+	return c, nil
+}
+
+// ErrInterrupted is returned by Run when its context was cancelled. The
+// pages finished before then have been written.
+var ErrInterrupted = errors.New("interrupted")
+
+// closeTimeout bounds the uploads made when the Writer is closed.
+const closeTimeout = 2 * time.Minute
+
+// Run clones every page and writes it through the Writer. Pages are processed
+// while the sitemap is still being read. Per-page failures are logged and
+// counted; Run only fails if the sitemap cannot be resolved, the Writer fails
+// to close, or every page fails.
+//
+// When ctx is cancelled or Config.MaxPages or Config.MaxDuration is reached,
+// no more pages are started. Pages in flight get Config.GracePeriod to
+// finish and are written as usual, and the Writer is closed, with
+// closeTimeout of its own for uploads, so single-file and manifest output
+// covers every finished page. A cancelled run returns ErrInterrupted.
+func (c *Cloner) Run(ctx context.Context) error {
+	r := c.start(ctx)
+	defer r.close()
+
+	// Uploads of finished pages get the same grace period as pages in flight
+	writes, cancelWrites := graceContext(ctx, c.cfg.GracePeriod)
+	defer cancelWrites()
+	w, _ := c.writer.(*sinkWriter)
+	if w != nil {
+		w.setContext(writes)
+	}
+
+	var written, errCount, thin, dups int
+	modeCounts := make(map[string]int)
+	done := 0
+
+	for result := range r.results {
+		done++
+		if result.err != nil {
+			errCount++
+			c.logf("[%d/%d] ERROR %s: %v", done, r.queued.Load(), result.page.URL, result.err)
+			continue
+		}
+
+		skip := func() {
+			if rec, ok := c.writer.(SkipRecorder); ok {
+				if err := rec.SkipPage(result.page); err != nil {
+					errCount++
+					c.logf("[%d/%d] WRITE ERROR %s: %v", done, r.queued.Load(), result.page.URL, err)
+				}
+			}
+		}
+
+		if result.page.DuplicateOf != "" {
+			dups++
+			if c.cfg.Verbose {
+				c.logf("[%d/%d] DUPLICATE %s of %s (%s)", done, r.queued.Load(), result.page.URL, result.page.DuplicateOf, result.page.dupReason)
+			}
+			skip()
+			continue
+		}
+
+		if issues := result.page.Issues; len(issues) > 0 {
+			thin++
+			switch c.cfg.OnThin {
+			case OnThinSkip:
+				c.logf("[%d/%d] SKIP %s: %s", done, r.queued.Load(), result.page.URL, strings.Join(issues, "; "))
+				skip()
+				continue
+			case OnThinWrite:
+			default:
+				c.logf("[%d/%d] WARNING thin page %s: %s", done, r.queued.Load(), result.page.URL, strings.Join(issues, "; "))
+			}
+		}
+
+		if err := c.writer.WritePage(result.page); err != nil {
+			errCount++
+			c.logf("[%d/%d] WRITE ERROR %s: %v", done, r.queued.Load(), result.page.URL, err)
+			continue
+		}
+
+		written++
+		modeCounts[result.page.Mode]++
+		if c.cfg.Verbose {
+			c.logf("[%d/%d] OK (%s) %s", done, r.queued.Load(), result.page.Mode, result.page.URL)
+		}
+	}
+
+	// The manifest and all-pages.md get a deadline of their own, so they are
+	// uploaded even when an interrupt used up the grace period
+	if w != nil {
+		closing, cancelClosing := context.WithTimeout(context.WithoutCancel(ctx), closeTimeout)
+		defer cancelClosing()
+		w.setContext(closing)
+	}
+	interrupted := ctx.Err() != nil
+	if err := errors.Join(c.writer.Close(), c.closeFetchers()); err != nil {
+		if interrupted {
+			return errors.Join(ErrInterrupted, err)
+		}
+		return err
+	}
+
+	if done > 0 {
+		c.logf("Done. %d pages written, %d errors.", written, errCount)
+		if thin > 0 {
+			action := "written"
+			if c.cfg.OnThin == OnThinSkip {
+				action = "skipped"
+			}
+			c.logf("Thin pages: %d %s.", thin, action)
+		}
+		if dups > 0 {
+			c.logf("Duplicates: %d pages not written (recorded as aliases).", dups)
+		}
+		if r.patterns != nil {
+			c.logf("Sources: %d raw markdown, %d HTML fallback.", modeCounts[ModeMarkdown], modeCounts[ModeHTMLFallback])
+		}
+		if c.cfg.AcceptMD {
+			c.logf("Content negotiation: %d pages served as markdown.", modeCounts[ModeNegotiated])
+		}
+		if n := modeCounts[ModeEmbedded]; n > 0 {
+			c.logf("Page data: %d pages extracted from Next.js, Nuxt or Gatsby data.", n)
+		}
+		if c.renderer != nil {
+			c.logf("Rendering: %d pages rendered with JavaScript.", modeCounts[ModeRendered])
+		}
+	}
+	if r.limited {
+		c.logf("Stopped at the --max-pages budget of %d pages; later sitemap entries were not queued.", c.cfg.MaxPages)
+	}
+	if err := r.stopped(); err != nil {
+		reason := err.Error()
+		if interrupted {
+			reason = "interrupted"
+		}
+		c.logf("Stopped early (%s): %d queued pages not started, %d pages in flight aborted; no more pages were queued.",
+			reason, r.dropped.Load(), r.aborted.Load())
+	}
+	if interrupted {
+		return ErrInterrupted
+	}
+	if r.err != nil {
+		return r.err
+	}
+	if written == 0 && errCount > 0 {
+		return fmt.Errorf("all %d pages failed", errCount)
+	}
+	return nil
+}
+
+// Pages streams converted pages as they complete without writing them.
+// Pages that fail a quality check are yielded with Page.Issues set,
+// regardless of Config.OnThin.
+// Per-page failures are yielded with the page URL set and a non-nil error;
+// a failure to resolve the sitemap is yielded last with an empty Page.
+// Cancelling ctx stops the run like it stops Run; pages that finish within
+// the grace period are still yielded. Stopping the iteration early aborts
+// outstanding work.
+func (c *Cloner) Pages(ctx context.Context) iter.Seq2[Page, error] {
+	return func(yield func(Page, error) bool) {
+		defer c.closeFetchers()
+
+		r := c.start(ctx)
+		defer r.close()
+		for result := range r.results {
+			if !yield(result.page, result.err) {
+				return
+			}
+		}
+		if r.err != nil {
+			yield(Page{}, r.err)
+		}
+	}
+}
+
+// defaultFetcher sets up the fetcher for cfg: local files for --from-dir,
+// recorded responses for --warc-in or --replay, and HTTP otherwise.
+func (c *Cloner) defaultFetcher() error {
+	cfg := &c.cfg
+	switch {
+	case cfg.FromDir != "":
+		d, err := fetcher.NewDir(cfg.FromDir, cfg.BaseURL)
+		if err != nil {
+			return fmt.Errorf("--from-dir: %w", err)
+		}
+		c.fetcher = d
+		c.closers = append(c.closers, d)
+	case cfg.WARCIn != "":
+		w, err := fetcher.LoadWARC(cfg.WARCIn)
+		if err != nil {
+			return fmt.Errorf("--warc-in: %w", err)
+		}
+		c.logf("Loaded %d responses from %s", w.Len(), cfg.WARCIn)
+		c.fetcher = w
+	case cfg.Replay != "":
+		r, err := fetcher.NewFixtureReplayer(cfg.Replay)
+		if err != nil {
+			return fmt.Errorf("--replay: %w", err)
+		}
+		c.fetcher = r
+	default:
+		c.fetcher = fetcher.New(fetcher.Options{
+			UserAgent:      cfg.UserAgent,
+			DelayMS:        cfg.DelayMS,
+			ConnectTimeout: cfg.ConnectTimeout,
+			HeaderTimeout:  cfg.HeaderTimeout,
+			Timeout:        cfg.Timeout,
+			MaxBodySize:    cfg.MaxBodySize,
+		})
+	}
+	return nil
+}
+
+// defaultRenderer sets up the renderer for --render-cmd or --render-url, if
+// either is set.
+func (c *Cloner) defaultRenderer() error {
+	switch {
+	case c.cfg.RenderCmd != "":
+		r, err := render.NewCommand(c.cfg.RenderCmd)
+		if err != nil {
+			return fmt.Errorf("--render-cmd: %w", err)
+		}
+		c.renderer = r
+	case c.cfg.RenderURL != "":
+		r, err := render.NewEndpoint(c.cfg.RenderURL)
+		if err != nil {
+			return fmt.Errorf("--render-url: %w", err)
+		}
+		c.renderer = r
+	}
+	return nil
+}
+
+// closeFetchers closes the fetchers New created.
+func (c *Cloner) closeFetchers() error {
+	var errs []error
+	for _, cl := range c.closers {
+		errs = append(errs, cl.Close())
+	}
+	c.closers = nil
+	return errors.Join(errs...)
+}
+
+// countSet returns how many of values are non-empty.
+func countSet(values ...string) int {
+	n := 0
+	for _, v := range values {
+		if v != "" {
+			n++
+		}
+	}
+	return n
+}
+
+// Plan resolves the sitemap and applies every filter like Run does, but only
+// reports which pages would be cloned and where they would be written. No
+// pages are fetched. A failure to resolve the sitemap is yielded last.
+func (c *Cloner) Plan(ctx context.Context) iter.Seq2[PlannedPage, error] {
+	return func(yield func(PlannedPage, error) bool) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		defer c.closeFetchers()
+
+		r := &run{planOnly: true}
+		jobCh := make(chan job, c.cfg.Concurrency*2)
+		go func() {
+			defer close(jobCh)
+			r.err = c.produce(ctx, r, jobCh)
+		}()
+
+		for j := range jobCh {
+			if !yield(PlannedPage{URL: j.entry.Loc, Path: j.path, Version: j.version, Sitemap: j.entry}, nil) {
+				return
+			}
+		}
+		if r.err != nil {
+			yield(PlannedPage{}, r.err)
+		}
+	}
+}
+
+func (c *Cloner) logf(format string, args ...any) {
+	c.logger.Printf(format, args...)
+}
@@ -0,0 +1,928 @@
+package cloner
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeFetcher serves canned responses by URL and records the requests made.
+// Missing URLs fail like a 404 from the HTTP fetcher.
+type fakeFetcher struct {
+	mu        sync.Mutex
+	responses map[string]*Response
+	requests  []string
+	accepts   map[string]string // URL -> last Accept header sent
+}
+
+func newFakeFetcher(pages map[string]string) *fakeFetcher {
+	f := &fakeFetcher{responses: make(map[string]*Response), accepts: make(map[string]string)}
+	for url, body := range pages {
+		contentType := "text/html"
+		switch {
+		case strings.HasSuffix(url, ".xml"):
+			contentType = "application/xml"
+		case strings.HasSuffix(url, ".md"):
+			contentType = "text/markdown"
+		}
+		f.responses[url] = &Response{URL: url, StatusCode: 200, Header: http.Header{"Content-Type": {contentType}}, Body: []byte(body)}
+	}
+	return f
+}
+
+func (f *fakeFetcher) Get(_ context.Context, url string, accept string) (*Response, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests = append(f.requests, url)
+	f.accepts[url] = accept
+	resp, ok := f.responses[url]
+	if !ok {
+		return nil, fmt.Errorf("HTTP 404 for %s", url)
+	}
+	return resp, nil
+}
+
+// sitemapXML returns a sitemap listing urls.
+func sitemapXML(urls ...string) string {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?><urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">`)
+	for _, u := range urls {
+		fmt.Fprintf(&b, "<url><loc>%s</loc></url>", u)
+	}
+	b.WriteString("</urlset>")
+	return b.String()
+}
+
+// htmlPage returns a documentation page with a title and body paragraph.
+func htmlPage(title, body string) string {
+	return fmt.Sprintf("<html><head><title>%s</title></head><body><main><h1>%s</h1><p>%s</p></main></body></html>", title, title, body)
+}
+
+// testConfig returns a config reading the sitemap at sitemapURL.
+func testConfig(sitemapURL string) Config {
+	return Config{SitemapURL: sitemapURL, OutputDir: "unused", Concurrency: 2, UserAgent: "test"}
+}
+
+// clonePages runs Pages with f and returns the pages by URL, failing the
+// test on any error.
+func clonePages(t *testing.T, cfg Config, f Fetcher, opts ...Option) map[string]Page {
+	t.Helper()
+	opts = append([]Option{WithFetcher(f), WithLogger(log.New(io.Discard, "", 0))}, opts...)
+	c, err := New(cfg, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pages := make(map[string]Page)
+	for p, err := range c.Pages(context.Background()) {
+		if err != nil {
+			t.Fatalf("%s: %v", p.URL, err)
+		}
+		pages[p.URL] = p
+	}
+	return pages
+}
+
+func TestFetchMDFallsBackToHTML(t *testing.T) {
+	const a, b = "https://example.com/docs/a", "https://example.com/docs/b"
+	f := newFakeFetcher(map[string]string{
+		"https://example.com/sitemap.xml": sitemapXML(a, b),
+		a + ".md":                         "# A\n\nFrom markdown.",
+		a:                                 htmlPage("A", "From HTML."),
+		b + ".md":                         "<!doctype html><html><body>Not found</body></html>",
+		b:                                 htmlPage("B", "From HTML."),
+	})
+	cfg := testConfig("https://example.com/sitemap.xml")
+	cfg.FetchMD = []string{"{url}.md"}
+	pages := clonePages(t, cfg, f)
+
+	if p := pages[a]; p.Mode != ModeMarkdown || !strings.Contains(p.Markdown, "From markdown.") {
+		t.Errorf("page a: mode %q, markdown %q", p.Mode, p.Markdown)
+	}
+	if p := pages[b]; p.Mode != ModeHTMLFallback || !strings.Contains(p.Markdown, "From HTML.") {
+		t.Errorf("page b: mode %q, markdown %q", p.Mode, p.Markdown)
+	}
+	if slices.Contains(f.requests, a) {
+		t.Errorf("fetched the HTML of %s although its markdown was valid", a)
+	}
+}
+
+func TestAcceptMarkdownRoutesByContentType(t *testing.T) {
+	const md, html = "https://example.com/docs/md", "https://example.com/docs/html"
+	f := newFakeFetcher(map[string]string{
+		"https://example.com/sitemap.xml": sitemapXML(md, html),
+		html:                              htmlPage("HTML page", "Converted from HTML."),
+	})
+	f.responses[md] = &Response{URL: md, StatusCode: 200, Header: http.Header{"Content-Type": {"text/markdown; charset=utf-8"}}, Body: []byte("# Negotiated\n\nServed as markdown.")}
+	cfg := testConfig("https://example.com/sitemap.xml")
+	cfg.AcceptMD = true
+	pages := clonePages(t, cfg, f)
+
+	if p := pages[md]; p.Mode != ModeNegotiated || p.Title != "Negotiated" || !strings.Contains(p.Markdown, "Served as markdown.") {
+		t.Errorf("markdown page: mode %q, title %q, markdown %q", p.Mode, p.Title, p.Markdown)
+	}
+	if p := pages[html]; p.Mode != ModeHTML || !strings.Contains(p.Markdown, "Converted from HTML.") {
+		t.Errorf("HTML page: mode %q, markdown %q", p.Mode, p.Markdown)
+	}
+	if !strings.HasPrefix(f.accepts[md], "text/markdown") {
+		t.Errorf("Accept = %q, want markdown preferred", f.accepts[md])
+	}
+}
+
+// memWriter keeps written pages in memory.
+type memWriter struct {
+	pages   []Page
+	skipped []Page
+	closed  bool
+}
+
+func (w *memWriter) WritePage(p Page) error {
+	w.pages = append(w.pages, p)
+	return nil
+}
+
+func (w *memWriter) SkipPage(p Page) error {
+	w.skipped = append(w.skipped, p)
+	return nil
+}
+
+func (w *memWriter) Close() error {
+	w.closed = true
+	return nil
+}
+
+func TestNewValidatesConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*Config)
+		want   string
+	}{
+		{"no source", func(c *Config) { c.SitemapURL = "" }, "sitemap URL is required"},
+		{"two sources", func(c *Config) { c.URLsFrom = "urls.txt" }, "only one of"},
+		{"no concurrency", func(c *Config) { c.Concurrency = 0 }, "concurrency"},
+		{"negative delay", func(c *Config) { c.DelayMS = -1 }, "delay"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig("https://example.com/sitemap.xml")
+			tt.modify(&cfg)
+			_, err := New(cfg, WithFetcher(newFakeFetcher(nil)))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("New error = %v, want it to mention %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunWritesThroughWriter(t *testing.T) {
+	const a, missing = "https://example.com/docs/a", "https://example.com/docs/missing"
+	f := newFakeFetcher(map[string]string{
+		"https://example.com/sitemap.xml": sitemapXML(a, missing),
+		a:                                 htmlPage("A", "Some documentation text for page A."),
+	})
+	w := &memWriter{}
+	c, err := New(testConfig("https://example.com/sitemap.xml"), WithFetcher(f), WithWriter(w), WithLogger(log.New(io.Discard, "", 0)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if len(w.pages) != 1 || w.pages[0].URL != a || w.pages[0].Path != "docs/a.md" || w.pages[0].Title != "A" {
+		t.Errorf("written pages = %+v", w.pages)
+	}
+	if !w.closed {
+		t.Error("writer was not closed")
+	}
+}
+
+func TestRunFailsWhenEveryPageFails(t *testing.T) {
+	f := newFakeFetcher(map[string]string{
+		"https://example.com/sitemap.xml": sitemapXML("https://example.com/gone"),
+	})
+	c, err := New(testConfig("https://example.com/sitemap.xml"), WithFetcher(f), WithWriter(&memWriter{}), WithLogger(log.New(io.Discard, "", 0)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Run(context.Background()); err == nil || !strings.Contains(err.Error(), "all 1 pages failed") {
+		t.Fatalf("Run error = %v", err)
+	}
+}
+
+func TestPagesYieldsPageErrors(t *testing.T) {
+	const gone = "https://example.com/gone"
+	f := newFakeFetcher(map[string]string{
+		"https://example.com/sitemap.xml": sitemapXML(gone),
+	})
+	c, err := New(testConfig("https://example.com/sitemap.xml"), WithFetcher(f), WithLogger(log.New(io.Discard, "", 0)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var errs []string
+	for p, err := range c.Pages(context.Background()) {
+		if err != nil {
+			errs = append(errs, p.URL)
+		}
+	}
+	if !slices.Equal(errs, []string{gone}) {
+		t.Errorf("pages with errors = %q, want %q", errs, gone)
+	}
+}
+
+func TestGraceContextOutlivesCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	g, stop := graceContext(ctx, 50*time.Millisecond)
+	defer stop()
+
+	cancel()
+	time.Sleep(10 * time.Millisecond)
+	if g.Err() != nil {
+		t.Fatal("grace context cancelled before the grace period")
+	}
+	select {
+	case <-g.Done():
+	case <-time.After(time.Second):
+		t.Fatal("grace context not cancelled after the grace period")
+	}
+}
+
+func TestSitemapFiltersByLangAndSince(t *testing.T) {
+	const en, de, old, undated = "https://example.com/en/a", "https://example.com/de/a", "https://example.com/en/old", "https://example.com/en/undated"
+	sitemap := `<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9" xmlns:xhtml="http://www.w3.org/1999/xhtml">
+<url><loc>` + en + `</loc><lastmod>2024-05-01</lastmod><changefreq>daily</changefreq>
+  <xhtml:link rel="alternate" hreflang="en-US" href="` + en + `"/>
+  <xhtml:link rel="alternate" hreflang="de" href="` + de + `"/></url>
+<url><loc>` + de + `</loc><lastmod>2024-05-01</lastmod>
+  <xhtml:link rel="alternate" hreflang="de" href="` + de + `"/></url>
+<url><loc>` + old + `</loc><lastmod>2023-01-01</lastmod></url>
+<url><loc>` + undated + `</loc></url>
+</urlset>`
+	f := newFakeFetcher(map[string]string{
+		"https://example.com/sitemap.xml": sitemap,
+		en:                                htmlPage("A", "English."),
+		de:                                htmlPage("A", "Deutsch."),
+		old:                               htmlPage("Old", "Old page."),
+		undated:                           htmlPage("Undated", "No lastmod."),
+	})
+	cfg := testConfig("https://example.com/sitemap.xml")
+	cfg.Lang = "en"
+	cfg.Since = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	pages := clonePages(t, cfg, f)
+
+	if len(pages) != 2 {
+		t.Fatalf("got %d pages, want %s and %s", len(pages), en, undated)
+	}
+	p, ok := pages[en]
+	if !ok || p.Sitemap.Lang() != "en-US" || p.Sitemap.LastMod != "2024-05-01" || p.Sitemap.ChangeFreq != "daily" {
+		t.Errorf("page %s: sitemap entry %+v", en, p.Sitemap)
+	}
+	if _, ok := pages[undated]; !ok {
+		t.Errorf("page without lastmod was dropped by --since")
+	}
+}
+
+func TestURLsFromLLMsTxtFile(t *testing.T) {
+	const guide, api = "https://example.com/guide.md", "https://example.com/api"
+	list := filepath.Join(t.TempDir(), "llms.txt")
+	if err := os.WriteFile(list, []byte("# Example\n\n- [Guide]("+guide+")\n- [API]("+api+")\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	f := newFakeFetcher(map[string]string{
+		guide: "# Guide\n\nWritten in markdown.",
+		api:   htmlPage("API", "Converted from HTML."),
+	})
+	cfg := testConfig("")
+	cfg.URLsFrom = list
+	pages := clonePages(t, cfg, f)
+
+	if p := pages[guide]; p.Mode != ModeMarkdown || !strings.Contains(p.Markdown, "Written in markdown.") {
+		t.Errorf("page %s: mode %q, markdown %q", guide, p.Mode, p.Markdown)
+	}
+	if p := pages[api]; p.Mode != ModeHTML || !strings.Contains(p.Markdown, "Converted from HTML.") {
+		t.Errorf("page %s: mode %q, markdown %q", api, p.Mode, p.Markdown)
+	}
+}
+
+func TestFromDirClonesLocalBuild(t *testing.T) {
+	dir := t.TempDir()
+	for name, body := range map[string]string{
+		"index.html":         htmlPage("Home", "Welcome to the docs."),
+		"guide/install.html": htmlPage("Install", "Run the installer."),
+	} {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(p), 0755)
+		if err := os.WriteFile(p, []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg := Config{FromDir: dir, BaseURL: "https://example.com/docs", OutputDir: "unused", Concurrency: 2}
+	c, err := New(cfg, WithLogger(log.New(io.Discard, "", 0)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pages := make(map[string]Page)
+	for p, err := range c.Pages(context.Background()) {
+		if err != nil {
+			t.Fatalf("%s: %v", p.URL, err)
+		}
+		pages[p.URL] = p
+	}
+
+	home, install := pages["https://example.com/docs/"], pages["https://example.com/docs/guide/install.html"]
+	if home.Title != "Home" || home.Path != "docs/index.md" {
+		t.Errorf("home page: title %q, path %q", home.Title, home.Path)
+	}
+	if !strings.Contains(install.Markdown, "Run the installer.") || install.Path != "docs/guide/install.md" {
+		t.Errorf("install page: path %q, markdown %q", install.Path, install.Markdown)
+	}
+}
+
+func TestFromDirRequiresBaseURL(t *testing.T) {
+	_, err := New(Config{FromDir: t.TempDir(), Concurrency: 1})
+	if err == nil || !strings.Contains(err.Error(), "--base-url") {
+		t.Errorf("New = %v, want a --base-url error", err)
+	}
+}
+
+func TestPlanFetchesOnlySitemaps(t *testing.T) {
+	f := newFakeFetcher(map[string]string{
+		"https://example.com/sitemap.xml": sitemapXML(
+			"https://example.com/docs/",
+			"https://example.com/docs/guide.html",
+			"https://example.com/blog/post",
+			"https://example.com/docs/Guide",
+		),
+	})
+	cfg := testConfig("https://example.com/sitemap.xml")
+	cfg.Include = []string{"/docs/"}
+	c, err := New(cfg, WithFetcher(f), WithLogger(log.New(io.Discard, "", 0)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for p, err := range c.Plan(context.Background()) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, p.URL+" -> "+p.Path)
+	}
+	want := []string{
+		"https://example.com/docs/ -> docs/index.md",
+		"https://example.com/docs/guide.html -> docs/guide.md",
+	}
+	if len(got) != 3 || !slices.Equal(got[:2], want) || !regexp.MustCompile(`^https://example.com/docs/Guide -> docs/Guide-[0-9a-f]{8}\.md$`).MatchString(got[2]) {
+		t.Errorf("Plan =\n  %q\nwant\n  %q", got, want)
+	}
+	if !slices.Equal(f.requests, []string{"https://example.com/sitemap.xml"}) {
+		t.Errorf("requests = %q, want only the sitemap", f.requests)
+	}
+}
+
+func TestPlanStopsWhenConsumerBreaks(t *testing.T) {
+	var urls []string
+	for i := range 100 {
+		urls = append(urls, fmt.Sprintf("https://example.com/p%d", i))
+	}
+	f := newFakeFetcher(map[string]string{"https://example.com/sitemap.xml": sitemapXML(urls...)})
+	c, err := New(testConfig("https://example.com/sitemap.xml"), WithFetcher(f), WithLogger(log.New(io.Discard, "", 0)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for range c.Plan(context.Background()) {
+			break
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Plan did not return after the consumer stopped")
+	}
+}
+
+func TestVersionDirsUseURLVersions(t *testing.T) {
+	const versioned, marked = "https://example.com/docs/v2/a", "https://example.com/docs/b"
+	f := newFakeFetcher(map[string]string{
+		"https://example.com/sitemap.xml": sitemapXML(versioned, marked),
+		versioned:                         htmlPage("A", "Versioned in the URL."),
+		marked: `<html><head><title>B</title><meta name="docsearch:version" content="3.0"></head>` +
+			`<body><main><h1>B</h1><p>Versioned in the HTML.</p></main></body></html>`,
+	})
+	cfg := testConfig("https://example.com/sitemap.xml")
+	cfg.VersionDirs = true
+	pages := clonePages(t, cfg, f)
+
+	if p := pages[versioned]; p.Version != "v2" || p.Path != "v2/docs/a.md" {
+		t.Errorf("URL-versioned page: version %q, path %q", p.Version, p.Path)
+	}
+	// The HTML version labels the page but doesn't move it to a 3.0 tree
+	if p := pages[marked]; p.Version != "3.0" || p.Path != "latest/docs/b.md" {
+		t.Errorf("HTML-versioned page: version %q, path %q", p.Version, p.Path)
+	}
+}
+
+func TestTitlesStripSharedSiteName(t *testing.T) {
+	pages := map[string]string{}
+	var urls []string
+	for _, name := range []string{"install", "usage", "config"} {
+		u := "https://example.com/docs/" + name
+		urls = append(urls, u)
+		pages[u] = htmlPage(strings.ToUpper(name[:1])+name[1:]+" | Acme Docs", "About "+name+".")
+	}
+	pages["https://example.com/sitemap.xml"] = sitemapXML(urls...)
+
+	cfg := testConfig("https://example.com/sitemap.xml")
+	cfg.TitleFrom = []string{"title"}
+	got := clonePages(t, cfg, newFakeFetcher(pages))
+	if title := got["https://example.com/docs/usage"].Title; title != "Usage" {
+		t.Errorf("title = %q, want %q", title, "Usage")
+	}
+
+	// --title-strip replaces the automatic detection
+	cfg.TitleStrip = `^Usage`
+	got = clonePages(t, cfg, newFakeFetcher(pages))
+	if title := got["https://example.com/docs/usage"].Title; title != "| Acme Docs" {
+		t.Errorf("title with --title-strip = %q", title)
+	}
+}
+
+func TestOnThin(t *testing.T) {
+	const good, thin = "https://example.com/docs/good", "https://example.com/docs/thin"
+	pages := map[string]string{
+		"https://example.com/sitemap.xml": sitemapXML(good, thin),
+		good:                              htmlPage("Good", "Run the installer, then follow the prompts to finish setting up."),
+		thin:                              htmlPage("Oops", "This page could not be found."),
+	}
+
+	tests := []struct {
+		onThin         string
+		written, skips int
+	}{
+		{OnThinSkip, 1, 1},
+		{OnThinWarn, 2, 0},
+		{OnThinWrite, 2, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.onThin, func(t *testing.T) {
+			cfg := testConfig("https://example.com/sitemap.xml")
+			cfg.OnThin = tt.onThin
+			w := &memWriter{}
+			c, err := New(cfg, WithFetcher(newFakeFetcher(pages)), WithWriter(w), WithLogger(log.New(io.Discard, "", 0)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := c.Run(context.Background()); err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+			if len(w.pages) != tt.written || len(w.skipped) != tt.skips {
+				t.Fatalf("written %d, skipped %d; want %d, %d", len(w.pages), len(w.skipped), tt.written, tt.skips)
+			}
+			for _, p := range append(w.pages, w.skipped...) {
+				if flagged := len(p.Issues) > 0; flagged != (p.URL == thin) {
+					t.Errorf("%s issues = %q", p.URL, p.Issues)
+				}
+			}
+		})
+	}
+
+	// Pages yields flagged pages whatever OnThin says
+	cfg := testConfig("https://example.com/sitemap.xml")
+	cfg.MinWords = 20
+	got := clonePages(t, cfg, newFakeFetcher(pages))
+	if issues := got[thin].Issues; len(issues) != 2 {
+		t.Errorf("Pages issues = %q, want too short and soft 404", issues)
+	}
+}
+
+func TestDuplicates(t *testing.T) {
+	const a, b = "https://example.com/docs/a", "https://example.com/docs/a-copy"
+	pages := map[string]string{
+		"https://example.com/sitemap.xml": sitemapXML(a, b),
+		a:                                 htmlPage("A", "The same documentation text."),
+		b:                                 htmlPage("A", "The same documentation text."),
+	}
+	run := func(keep bool) *memWriter {
+		cfg := testConfig("https://example.com/sitemap.xml")
+		cfg.Concurrency = 1 // a completes first
+		cfg.KeepDuplicates = keep
+		w := &memWriter{}
+		c, err := New(cfg, WithFetcher(newFakeFetcher(pages)), WithWriter(w), WithLogger(log.New(io.Discard, "", 0)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := c.Run(context.Background()); err != nil {
+			t.Fatalf("Run: %v", err)
+		}
+		return w
+	}
+
+	w := run(false)
+	if len(w.pages) != 1 || w.pages[0].URL != a {
+		t.Fatalf("written = %+v", w.pages)
+	}
+	if len(w.skipped) != 1 || w.skipped[0].URL != b || w.skipped[0].DuplicateOf != a {
+		t.Fatalf("skipped = %+v", w.skipped)
+	}
+
+	// --keep-duplicates writes the copy but still flags it
+	w = run(true)
+	if len(w.pages) != 2 || len(w.skipped) != 0 {
+		t.Fatalf("written %d, skipped %d; want 2, 0", len(w.pages), len(w.skipped))
+	}
+	dup := w.pages[1]
+	if dup.URL != b || dup.DuplicateOf != "" || !slices.Equal(dup.Issues, []string{"duplicate of " + a}) {
+		t.Errorf("copy = %s, DuplicateOf %q, issues %q", dup.URL, dup.DuplicateOf, dup.Issues)
+	}
+}
+
+// laterFetcher is a fakeFetcher that answers requests for slow only once
+// fast has been fetched.
+type laterFetcher struct {
+	*fakeFetcher
+	slow, fast string
+	once       sync.Once
+	fetched    chan struct{}
+}
+
+func (f *laterFetcher) Get(ctx context.Context, url string, accept string) (*Response, error) {
+	if url == f.slow {
+		<-f.fetched
+		time.Sleep(20 * time.Millisecond)
+	}
+	resp, err := f.fakeFetcher.Get(ctx, url, accept)
+	if url == f.fast {
+		f.once.Do(func() { close(f.fetched) })
+	}
+	return resp, err
+}
+
+func TestCanonicalPageKept(t *testing.T) {
+	const oldURL, newURL = "https://example.com/docs/old", "https://example.com/docs/new"
+	const body = "<main><h1>Install</h1><p>The same documentation text.</p></main>"
+	for _, order := range [][]string{{oldURL, newURL}, {newURL, oldURL}} {
+		f := &laterFetcher{
+			fakeFetcher: newFakeFetcher(map[string]string{
+				"https://example.com/sitemap.xml": sitemapXML(order...),
+				oldURL:                            `<html><head><link rel="canonical" href="` + newURL + `"></head><body>` + body + `</body></html>`,
+				newURL:                            `<html><head><link rel="canonical" href="` + newURL + `"></head><body>` + body + `</body></html>`,
+			}),
+			// The alias completes first
+			slow:    newURL,
+			fast:    oldURL,
+			fetched: make(chan struct{}),
+		}
+		w := &memWriter{}
+		c, err := New(testConfig("https://example.com/sitemap.xml"), WithFetcher(f), WithWriter(w), WithLogger(log.New(io.Discard, "", 0)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := c.Run(context.Background()); err != nil {
+			t.Fatalf("Run: %v", err)
+		}
+		if len(w.pages) != 1 || w.pages[0].URL != newURL {
+			t.Errorf("sitemap %v: written = %+v", order, w.pages)
+		}
+		if len(w.skipped) != 1 || w.skipped[0].URL != oldURL || w.skipped[0].DuplicateOf != newURL {
+			t.Errorf("sitemap %v: skipped = %+v", order, w.skipped)
+		}
+	}
+}
+
+func TestEmbeddedPageData(t *testing.T) {
+	const prose = "Deploy the service by building the container image, pushing it to your registry, and applying the manifests in the deploy directory of the repository."
+	const next, gatsby = "https://example.com/docs/next", "https://example.com/docs/gatsby/"
+	f := newFakeFetcher(map[string]string{
+		"https://example.com/sitemap.xml": sitemapXML(next, gatsby),
+		next: `<html><head><title>Next</title></head><body><div id="__next"></div>` +
+			`<script id="__NEXT_DATA__" type="application/json">{"props":{"pageProps":{"doc":{"title":"Deploy","markdown":"## Steps\n\n` + prose + `"}}}}</script></body></html>`,
+		gatsby: `<html><head></head><body><div id="___gatsby"><p>Loading...</p></div></body></html>`,
+		"https://example.com/page-data/docs/gatsby/page-data.json": `{"result":{"data":{"markdownRemark":{"frontmatter":{"title":"Gatsby deploy"},"html":"<h2>Steps</h2><p>` + prose + `</p>"}}}}`,
+	})
+	pages := clonePages(t, testConfig("https://example.com/sitemap.xml"), f)
+
+	p := pages[next]
+	if p.Mode != ModeEmbedded || p.Title != "Next" || !strings.Contains(p.Markdown, "## Steps\n\n"+prose) {
+		t.Errorf("next page: mode %q, title %q\n%s", p.Mode, p.Title, p.Markdown)
+	}
+	p = pages[gatsby]
+	if p.Mode != ModeEmbedded || p.Title != "Gatsby deploy" || !strings.Contains(p.Markdown, prose) || strings.Contains(p.Markdown, "Loading") {
+		t.Errorf("gatsby page: mode %q, title %q\n%s", p.Mode, p.Title, p.Markdown)
+	}
+}
+
+// blockingFetcher is a fakeFetcher whose requests for block wait until ctx
+// is done.
+type blockingFetcher struct {
+	*fakeFetcher
+	block string
+}
+
+func (f *blockingFetcher) Get(ctx context.Context, url string, accept string) (*Response, error) {
+	if url == f.block {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	return f.fakeFetcher.Get(ctx, url, accept)
+}
+
+func TestMaxPages(t *testing.T) {
+	pages := map[string]string{}
+	var urls []string
+	for i := range 5 {
+		u := fmt.Sprintf("https://example.com/docs/%d", i)
+		urls = append(urls, u)
+		pages[u] = htmlPage(fmt.Sprintf("Page %d", i), "Some documentation text.")
+	}
+	pages["https://example.com/sitemap.xml"] = sitemapXML(urls...)
+	f := newFakeFetcher(pages)
+
+	cfg := testConfig("https://example.com/sitemap.xml")
+	cfg.MaxPages = 2
+	var logs bytes.Buffer
+	w := &memWriter{}
+	c, err := New(cfg, WithFetcher(f), WithWriter(w), WithLogger(log.New(&logs, "", 0)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if len(w.pages) != 2 {
+		t.Errorf("wrote %d pages, want 2", len(w.pages))
+	}
+	if n := len(f.requests); n != 3 {
+		t.Errorf("made %d requests, want the sitemap and 2 pages", n)
+	}
+	if !strings.Contains(logs.String(), "--max-pages budget of 2 pages") {
+		t.Errorf("log doesn't mention the budget:\n%s", logs.String())
+	}
+}
+
+func TestMaxDuration(t *testing.T) {
+	const fast, slow = "https://example.com/docs/fast", "https://example.com/docs/slow"
+	f := &blockingFetcher{
+		fakeFetcher: newFakeFetcher(map[string]string{
+			"https://example.com/sitemap.xml": sitemapXML(fast, slow),
+			fast:                              htmlPage("Fast", "Some documentation text."),
+		}),
+		block: slow,
+	}
+	cfg := testConfig("https://example.com/sitemap.xml")
+	cfg.MaxDuration = 100 * time.Millisecond
+	cfg.GracePeriod = 50 * time.Millisecond
+	var logs bytes.Buffer
+	w := &memWriter{}
+	c, err := New(cfg, WithFetcher(f), WithWriter(w), WithLogger(log.New(&logs, "", 0)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	if err := c.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("Run took %s", d)
+	}
+	if len(w.pages) != 1 || w.pages[0].URL != fast || !w.closed {
+		t.Errorf("written = %+v, closed %v", w.pages, w.closed)
+	}
+	if !strings.Contains(logs.String(), "Stopped early (--max-duration of 100ms reached)") {
+		t.Errorf("log doesn't mention the duration budget:\n%s", logs.String())
+	}
+}
+
+// gatedFetcher is a fakeFetcher that holds requests for some URLs: those in
+// finish complete once release is closed, those in stuck wait until their
+// context is done. started receives each held URL when its request begins,
+// and done when its request has been answered.
+type gatedFetcher struct {
+	*fakeFetcher
+	done, finish, stuck string
+	release             chan struct{}
+	started             chan string
+}
+
+func (f *gatedFetcher) Get(ctx context.Context, url string, accept string) (*Response, error) {
+	switch url {
+	case f.done:
+		defer func() { f.started <- url }()
+	case f.finish:
+		f.started <- url
+		<-f.release
+	case f.stuck:
+		f.started <- url
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	return f.fakeFetcher.Get(ctx, url, accept)
+}
+
+func newGatedFetcher() *gatedFetcher {
+	const done, finish, stuck = "https://example.com/docs/done", "https://example.com/docs/finish", "https://example.com/docs/stuck"
+	return &gatedFetcher{
+		fakeFetcher: newFakeFetcher(map[string]string{
+			"https://example.com/sitemap.xml": sitemapXML(done, finish, stuck),
+			done:                              htmlPage("Done", "Finished before the interrupt."),
+			finish:                            htmlPage("Finish", "Finished within the grace period."),
+		}),
+		done:    done,
+		finish:  finish,
+		stuck:   stuck,
+		release: make(chan struct{}),
+		started: make(chan string, 3),
+	}
+}
+
+// interruptWhenHeld cancels once done has been fetched and both held
+// requests have started, then releases the one that finishes within the
+// grace period.
+func interruptWhenHeld(f *gatedFetcher, cancel context.CancelFunc) {
+	for range 3 {
+		<-f.started
+	}
+	cancel()
+	close(f.release)
+}
+
+func TestRunInterrupted(t *testing.T) {
+	f := newGatedFetcher()
+	cfg := testConfig("https://example.com/sitemap.xml")
+	cfg.Concurrency = 3
+	cfg.GracePeriod = 200 * time.Millisecond
+	w := &memWriter{}
+	c, err := New(cfg, WithFetcher(f), WithWriter(w), WithLogger(log.New(io.Discard, "", 0)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go interruptWhenHeld(f, cancel)
+	if err := c.Run(ctx); !errors.Is(err, ErrInterrupted) {
+		t.Fatalf("Run error = %v, want ErrInterrupted", err)
+	}
+	var written []string
+	for _, p := range w.pages {
+		written = append(written, p.URL)
+	}
+	slices.Sort(written)
+	if want := []string{"https://example.com/docs/done", "https://example.com/docs/finish"}; !slices.Equal(written, want) {
+		t.Errorf("written = %q, want %q", written, want)
+	}
+	if !w.closed {
+		t.Error("writer was not closed")
+	}
+}
+
+func TestRunInterruptedUploadsManifest(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	var mu sync.Mutex
+	var uploaded []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		uploaded = append(uploaded, r.URL.Path)
+	}))
+	defer srv.Close()
+
+	f := newGatedFetcher()
+	cfg := testConfig("https://example.com/sitemap.xml")
+	cfg.Concurrency = 3
+	cfg.GracePeriod = 200 * time.Millisecond
+	cfg.OutputDir = "s3://bucket/site"
+	cfg.S3Endpoint = srv.URL
+	cfg.SingleFile = true
+	cfg.TitleStrip = " [|] Docs$" // pages aren't held for a title sample
+	c, err := New(cfg, WithFetcher(f), WithLogger(log.New(io.Discard, "", 0)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go interruptWhenHeld(f, cancel)
+	// The writer is closed once the stuck page is aborted, after the grace
+	// period has run out
+	if err := c.Run(ctx); err != ErrInterrupted {
+		t.Fatalf("Run error = %v, want ErrInterrupted alone", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	for _, name := range []string{"/bucket/site/manifest.json", "/bucket/site/all-pages.md"} {
+		if !slices.Contains(uploaded, name) {
+			t.Errorf("%s not uploaded; got %q", name, uploaded)
+		}
+	}
+}
+
+func TestPagesInterrupted(t *testing.T) {
+	f := newGatedFetcher()
+	cfg := testConfig("https://example.com/sitemap.xml")
+	cfg.Concurrency = 3
+	cfg.GracePeriod = 200 * time.Millisecond
+	c, err := New(cfg, WithFetcher(f), WithLogger(log.New(io.Discard, "", 0)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go interruptWhenHeld(f, cancel)
+	var ok, failed []string
+	for p, err := range c.Pages(ctx) {
+		if err != nil {
+			failed = append(failed, p.URL)
+		} else {
+			ok = append(ok, p.URL)
+		}
+	}
+	slices.Sort(ok)
+	if want := []string{"https://example.com/docs/done", "https://example.com/docs/finish"}; !slices.Equal(ok, want) {
+		t.Errorf("pages = %q, want %q", ok, want)
+	}
+	// Pages aborted after the grace period are dropped, not yielded as errors
+	if len(failed) != 0 {
+		t.Errorf("failed = %q, want none", failed)
+	}
+}
+
+func TestLatestVersionKeptNextToUnrelatedPages(t *testing.T) {
+	urls := []string{
+		"https://example.com/docs/v1/intro", "https://example.com/docs/v2/intro",
+		"https://example.com/docs/v2/usage", "https://example.com/docs/search",
+	}
+	pages := map[string]string{"https://example.com/sitemap.xml": sitemapXML(urls...)}
+	for _, u := range urls {
+		pages[u] = htmlPage("Page", "Some documentation text.")
+	}
+	cfg := testConfig("https://example.com/sitemap.xml")
+	cfg.Versions = "latest"
+	got := slices.Sorted(maps.Keys(clonePages(t, cfg, newFakeFetcher(pages))))
+	want := []string{"https://example.com/docs/search", "https://example.com/docs/v2/intro", "https://example.com/docs/v2/usage"}
+	if !slices.Equal(got, want) {
+		t.Errorf("cloned %q, want %q", got, want)
+	}
+}
+
+func TestPagesStreamWithoutTitles(t *testing.T) {
+	// Markdown pages have no <title>; they must still be yielded while
+	// the run is going, not once it ends
+	const gate = "https://example.com/docs/gate.md"
+	pages := map[string]string{}
+	urls := []string{gate}
+	for i := range titleSampleSize + 1 {
+		u := fmt.Sprintf("https://example.com/docs/%d.md", i)
+		urls = append(urls, u)
+		pages[u] = fmt.Sprintf("# Page %d\n\nSome documentation text.\n", i)
+	}
+	pages[gate] = "# Gate\n\nReleased once a page has been yielded.\n"
+	pages["https://example.com/sitemap.xml"] = sitemapXML(urls...)
+	f := &gatedFetcher{
+		fakeFetcher: newFakeFetcher(pages),
+		finish:      gate,
+		release:     make(chan struct{}),
+		started:     make(chan string, 1),
+	}
+	stuck := time.AfterFunc(5*time.Second, func() { close(f.release) })
+
+	c, err := New(testConfig("https://example.com/sitemap.xml"), WithFetcher(f), WithLogger(log.New(io.Discard, "", 0)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	for p, err := range c.Pages(context.Background()) {
+		if err != nil {
+			t.Fatalf("%s: %v", p.URL, err)
+		}
+		if n == 0 {
+			if !stuck.Stop() {
+				t.Fatal("no page was yielded while a page was still being fetched")
+			}
+			close(f.release)
+		}
+		n++
+	}
+	if n != len(urls) {
+		t.Errorf("yielded %d pages, want %d", n, len(urls))
+	}
+}
@@ -0,0 +1,210 @@
+package cloner
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/Devon-White/docs-cloner/internal/converter"
+	"github.com/Devon-White/docs-cloner/internal/extractor"
+	"github.com/Devon-White/docs-cloner/internal/writer"
+)
+
+// selectorExtractor is the default Extractor. It uses an explicit CSS
+// selector or falls back to the heuristic cascade.
+type selectorExtractor struct {
+	selector  string
+	titleFrom []string
+}
+
+func (e *selectorExtractor) Extract(htmlBody []byte, sourceURL string) (string, string, error) {
+	return extractor.Extract(htmlBody, e.selector, e.titleFrom, sourceURL)
+}
+
+// htmlConverter is the default Converter. Heading ids are kept as anchors in
+// the configured style.
+type htmlConverter struct {
+	anchors string
+}
+
+func (c htmlConverter) Convert(contentHTML string, sourceURL string) (string, error) {
+	return converter.ConvertHTML(contentHTML, sourceURL, c.anchors)
+}
+
+// frontmatterOptions returns the frontmatter format and fields selected in cfg.
+func frontmatterOptions(cfg *Config) writer.FrontmatterOptions {
+	return writer.FrontmatterOptions{Format: cfg.Frontmatter, Fields: cfg.FrontmatterFields}
+}
+
+// sinkWriter is the default Writer. It writes each page with frontmatter to
+// its assigned path in the output sink selected by OutputDir and records
+// skipped pages. On Close it writes the manifest and, if enabled,
+// concatenates all pages into all-pages.md.
+type sinkWriter struct {
+	ctx      context.Context // cancels uploads; set by Run
+	cfg      *Config
+	logger   *log.Logger
+	sink     writer.Sink
+	pages    []writer.PageResult
+	manifest writer.Manifest
+	written  map[string]int // URL -> index in manifest.Pages
+	skipped  map[string]int // URL -> index in manifest.Skipped
+}
+
+func newSinkWriter(cfg *Config, logger *log.Logger) *sinkWriter {
+	return &sinkWriter{
+		cfg:     cfg,
+		logger:  logger,
+		written: make(map[string]int),
+		skipped: make(map[string]int),
+	}
+}
+
+// setContext makes later uploads use ctx, including those of a sink that is
+// already open.
+func (w *sinkWriter) setContext(ctx context.Context) {
+	w.ctx = ctx
+	if s, ok := w.sink.(writer.ContextSink); ok {
+		s.SetContext(ctx)
+	}
+}
+
+// open cleans the output directory if requested and opens the sink. It runs
+// on the first write, once there is something to write.
+func (w *sinkWriter) open() error {
+	if w.cfg.Clean && writer.IsDirOutput(w.cfg.OutputDir) {
+		w.logger.Printf("Cleaning output directory: %s", w.cfg.OutputDir)
+		if err := os.RemoveAll(w.cfg.OutputDir); err != nil {
+			return fmt.Errorf("cleaning output directory: %w", err)
+		}
+	}
+
+	ctx := w.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	sink, err := writer.OpenSink(ctx, w.cfg.OutputDir, writer.SinkOptions{
+		S3Endpoint: w.cfg.S3Endpoint,
+		S3Region:   w.cfg.S3Region,
+	})
+	if err != nil {
+		return fmt.Errorf("opening output: %w", err)
+	}
+	w.sink = sink
+	return nil
+}
+
+func (w *sinkWriter) WritePage(p Page) error {
+	if w.sink == nil {
+		if err := w.open(); err != nil {
+			return err
+		}
+	}
+
+	lang := p.Sitemap.Lang()
+	if lang == "" {
+		lang = p.Metadata.Lang
+	}
+	frontmatter, err := writer.Frontmatter(writer.Meta{
+		Title:        p.Title,
+		Description:  p.Metadata.Description,
+		SourceURL:    p.URL,
+		CanonicalURL: p.Metadata.Canonical,
+		SourceMode:   p.Mode,
+		CrawlDate:    p.CrawlDate,
+		Lang:         lang,
+		LastMod:      p.Sitemap.LastMod,
+		Modified:     p.Metadata.Modified,
+		ChangeFreq:   p.Sitemap.ChangeFreq,
+		Priority:     p.Sitemap.Priority,
+		Version:      p.Version,
+		Keywords:     p.Metadata.Keywords,
+		Breadcrumbs:  p.Metadata.Breadcrumbs,
+		OpenGraph:    p.Metadata.OpenGraph,
+		WordCount:    writer.WordCount(p.Markdown),
+		Tokens:       writer.EstimateTokens(p.Markdown),
+		ContentHash:  writer.ContentHash(p.Markdown),
+	}, frontmatterOptions(w.cfg))
+	if err != nil {
+		return fmt.Errorf("frontmatter: %w", err)
+	}
+	markdown := frontmatter + p.Markdown
+	if err := w.sink.Put(p.Path, []byte(markdown)); err != nil {
+		return err
+	}
+
+	w.written[p.URL] = len(w.manifest.Pages)
+	w.manifest.Pages = append(w.manifest.Pages, manifestEntry(p))
+
+	if w.cfg.SingleFile {
+		w.pages = append(w.pages, writer.PageResult{
+			URL:         p.URL,
+			Title:       p.Title,
+			Markdown:    markdown,
+			TitleInBody: p.Title != "" && converter.ExtractTitleFromMarkdown(p.Markdown) == p.Title,
+		})
+	}
+	return nil
+}
+
+// SkipPage records a page left out of the output in the manifest: a
+// duplicate as an alias of the page it duplicates, anything else under
+// "skipped".
+func (w *sinkWriter) SkipPage(p Page) error {
+	if w.sink == nil {
+		if err := w.open(); err != nil {
+			return err
+		}
+	}
+	if p.DuplicateOf != "" {
+		if i, ok := w.written[p.DuplicateOf]; ok {
+			w.manifest.Pages[i].Aliases = append(w.manifest.Pages[i].Aliases, p.URL)
+			return nil
+		}
+		if i, ok := w.skipped[p.DuplicateOf]; ok {
+			w.manifest.Skipped[i].Aliases = append(w.manifest.Skipped[i].Aliases, p.URL)
+			return nil
+		}
+	}
+	w.skipped[p.URL] = len(w.manifest.Skipped)
+	w.manifest.Skipped = append(w.manifest.Skipped, manifestEntry(p))
+	return nil
+}
+
+func manifestEntry(p Page) writer.ManifestEntry {
+	return writer.ManifestEntry{
+		URL:         p.URL,
+		Path:        p.Path,
+		Title:       p.Title,
+		Mode:        p.Mode,
+		Issues:      p.Issues,
+		DuplicateOf: p.DuplicateOf,
+	}
+}
+
+func (w *sinkWriter) Close() error {
+	if w.sink == nil {
+		return nil
+	}
+
+	w.manifest.Generated = time.Now()
+	if err := writer.WriteManifest(w.sink, &w.manifest); err != nil {
+		w.sink.Close()
+		return fmt.Errorf("manifest: %w", err)
+	}
+
+	// Single-file output
+	if w.cfg.SingleFile && len(w.pages) > 0 {
+		w.logger.Printf("Writing single file with %d pages...", len(w.pages))
+		if err := writer.WriteSingleFile(w.sink, w.pages); err != nil {
+			w.sink.Close()
+			return fmt.Errorf("single file: %w", err)
+		}
+	}
+	if err := w.sink.Close(); err != nil {
+		return fmt.Errorf("closing output: %w", err)
+	}
+	return nil
+}
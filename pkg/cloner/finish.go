@@ -0,0 +1,165 @@
+package cloner
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/Devon-White/docs-cloner/internal/converter"
+	"github.com/Devon-White/docs-cloner/internal/dedupe"
+	"github.com/Devon-White/docs-cloner/internal/extractor"
+	"github.com/Devon-White/docs-cloner/internal/quality"
+)
+
+// finishPages forwards results from in to out, cleaning each page's title,
+// dropping headings that repeat it from the body, normalizing heading levels,
+// running the quality checks, and marking duplicates of earlier pages. It
+// runs in a single goroutine, in the order pages complete, so the first copy
+// of a page to complete is the one kept, except that a page declaring a
+// queued page as its canonical URL waits for that page to finish, so the
+// canonical page is kept whichever completes first. Pages are only compared
+// with pages of the same version and language. With --keep-duplicates
+// nothing is marked, but pages identical to an earlier page get a "duplicate
+// of" issue. With --title-strip the regex is removed from every title.
+// Otherwise the first titleSampleSize pages to complete are held, and the
+// site-name prefix and suffix their <title>s share is stripped from those and
+// all later titles. Pages without a <title>, such as raw markdown, count
+// toward the sample size, so pages are never held for the whole run.
+func (c *Cloner) finishPages(ctx context.Context, in <-chan result, out chan<- result, queued func(key string) bool) {
+	var prefix, suffix string
+	learning := c.titleStrip == nil
+	var held []result
+	var sample []string
+	dups := dedupe.New(c.cfg.NearDuplicates)
+	if c.cfg.KeepDuplicates {
+		dups = dedupe.NewExact()
+	}
+
+	send := func(res result) bool {
+		if res.err == nil {
+			p := &res.page
+			p.Title = c.cleanTitle(p.Title, prefix, suffix)
+			md := converter.DropRepeatedTitle(p.Markdown, p.Title)
+			p.Markdown = converter.NormalizeHeadings(md, p.Title)
+			p.Issues = c.quality.Check(quality.Page{
+				URL:       p.URL,
+				Title:     p.Title,
+				Markdown:  p.Markdown,
+				PageWords: p.pageWords,
+			})
+			dupOf, reason := dups.Add(dedupe.Page{
+				URL:       p.URL,
+				Canonical: p.Metadata.Canonical,
+				Group:     p.Version + "\x00" + p.Sitemap.Lang(),
+				Markdown:  p.Markdown,
+			})
+			if c.cfg.KeepDuplicates && dupOf != "" {
+				p.Issues = append(p.Issues, "duplicate of "+dupOf)
+			} else {
+				p.DuplicateOf, p.dupReason = dupOf, reason
+			}
+		}
+		select {
+		case out <- res:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	// Aliases wait in waiting, by the key of their canonical URL, until the
+	// page at that URL has been sent.
+	done := make(map[string]bool)
+	waiting := make(map[string][]result)
+	var awaited []string
+	awaits := func(res result) string {
+		canonical := res.page.Metadata.Canonical
+		if c.cfg.KeepDuplicates || res.err != nil || canonical == "" {
+			return ""
+		}
+		key := dedupe.Key(canonical)
+		if key == dedupe.Key(res.page.URL) || done[key] || !queued(key) {
+			return ""
+		}
+		return key
+	}
+	deliver := func(res result) bool {
+		if key := awaits(res); key != "" {
+			if _, ok := waiting[key]; !ok {
+				awaited = append(awaited, key)
+			}
+			waiting[key] = append(waiting[key], res)
+			return true
+		}
+		ready := []result{res}
+		for len(ready) > 0 {
+			res, ready = ready[0], ready[1:]
+			if !send(res) {
+				return false
+			}
+			key := dedupe.Key(res.page.URL)
+			done[key] = true
+			ready = append(ready, waiting[key]...)
+			delete(waiting, key)
+		}
+		return true
+	}
+
+	flush := func() bool {
+		learning = false
+		prefix, suffix = extractor.CommonAffixes(sample)
+		if (prefix != "" || suffix != "") && c.cfg.Verbose {
+			c.logf("Stripping site name from titles: prefix %q, suffix %q", prefix, suffix)
+		}
+		for _, res := range held {
+			if !deliver(res) {
+				return false
+			}
+		}
+		held = nil
+		return true
+	}
+
+	for res := range in {
+		if !learning {
+			if !deliver(res) {
+				return
+			}
+			continue
+		}
+		held = append(held, res)
+		if t := res.page.Metadata.Title; res.err == nil && t != "" {
+			sample = append(sample, t)
+		}
+		if len(held) >= titleSampleSize && !flush() {
+			return
+		}
+	}
+	if learning && !flush() {
+		return
+	}
+	// Aliases whose canonical page failed or never started are sent last
+	for _, key := range awaited {
+		for _, res := range waiting[key] {
+			if !send(res) {
+				return
+			}
+		}
+	}
+}
+
+// qualityOptions returns the quality checks selected in the config. The
+// built-in soft-404 phrases are always checked.
+func (c *Cloner) qualityOptions() quality.Options {
+	phrases := slices.Clone(quality.DefaultPhrases)
+	for _, p := range c.cfg.Soft404Phrases {
+		if p = strings.ToLower(strings.TrimSpace(p)); p != "" {
+			phrases = append(phrases, p)
+		}
+	}
+	return quality.Options{
+		MinWords:     c.cfg.MinWords,
+		MinTextRatio: c.cfg.MinTextRatio,
+		Phrases:      phrases,
+	}
+}
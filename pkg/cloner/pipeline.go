@@ -0,0 +1,821 @@
+package cloner
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+
+	"github.com/Devon-White/docs-cloner/internal/converter"
+	"github.com/Devon-White/docs-cloner/internal/dedupe"
+	"github.com/Devon-White/docs-cloner/internal/extractor"
+	"github.com/Devon-White/docs-cloner/internal/fetcher"
+	"github.com/Devon-White/docs-cloner/internal/quality"
+	"github.com/Devon-White/docs-cloner/internal/sitemap"
+	"github.com/Devon-White/docs-cloner/internal/urlfilter"
+	"github.com/Devon-White/docs-cloner/internal/versions"
+	"github.com/Devon-White/docs-cloner/internal/writer"
+)
+
+// result is a processed page or the error that prevented processing it.
+type result struct {
+	page Page
+	err  error
+}
+
+// job is a sitemap entry queued for a worker along with its output path.
+type job struct {
+	entry   sitemap.URL
+	path    string
+	version string // version segment of the URL, if any
+}
+
+// errPageBudget stops the producer once --max-pages pages are queued.
+var errPageBudget = errors.New("page budget reached")
+
+// run is the state of a started clone. The producer goroutine sets patterns
+// before queuing the first job, and err and limited before closing the job
+// channel, so they are safe to read once a job has been received or results
+// is closed.
+type run struct {
+	results  chan result
+	queued   atomic.Int64
+	patterns *converter.PatternSet
+	err      error // fatal sitemap error
+	limited  bool  // the producer stopped at --max-pages
+	planOnly bool  // queue jobs without fetching anything but sitemaps
+
+	// dispatch is cancelled, with the reason as its cause, to stop queuing
+	// and starting pages. It is derived from the caller's context, so
+	// cancelling that stops dispatch too.
+	dispatch context.Context
+	stop     context.CancelCauseFunc
+
+	// work is the context of pages in flight. It outlives the caller's
+	// context by the grace period.
+	work  context.Context
+	abort context.CancelFunc
+
+	// detached is cancelled when the caller stops reading results.
+	detached context.Context
+	detach   context.CancelFunc
+
+	dropped atomic.Int64 // queued pages not started because dispatch stopped
+	aborted atomic.Int64 // pages in flight abandoned after the grace period
+
+	mu   sync.Mutex
+	urls map[string]bool // dedupe keys of queued pages
+}
+
+// expect records that the page at rawURL is queued.
+func (r *run) expect(rawURL string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.urls == nil {
+		r.urls = make(map[string]bool)
+	}
+	r.urls[dedupe.Key(rawURL)] = true
+}
+
+// isQueued reports whether the page with the dedupe key was queued.
+func (r *run) isQueued(key string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.urls[key]
+}
+
+// stopped returns why dispatch was stopped before the sitemap was done, or
+// nil.
+func (r *run) stopped() error {
+	if r.dispatch == nil || r.dispatch.Err() == nil {
+		return nil
+	}
+	return context.Cause(r.dispatch)
+}
+
+// close abandons the run: nothing more is started or delivered.
+func (r *run) close() {
+	r.stop(nil)
+	r.abort()
+	r.detach()
+}
+
+// start launches the sitemap producer and the worker pool. Sitemap entries
+// are streamed to workers as they are decoded, so pages are processed while
+// the sitemap is still being read. The results channel is closed once all
+// workers have finished; r.err is valid from then on.
+//
+// Cancelling ctx, or reaching Config.MaxDuration, stops dispatch: no more
+// pages are queued or started, and pages in flight are given
+// Config.GracePeriod to finish before they are aborted. Finished pages are
+// still delivered, so the caller must keep reading results until they are
+// closed, or call r.close.
+func (c *Cloner) start(ctx context.Context) *run {
+	cfg := &c.cfg
+	r := &run{results: make(chan result, cfg.Concurrency*2)}
+	r.dispatch, r.stop = context.WithCancelCause(ctx)
+	r.work, r.abort = context.WithCancel(context.WithoutCancel(ctx))
+	r.detached, r.detach = context.WithCancel(context.Background())
+	jobCh := make(chan job, cfg.Concurrency*2)
+
+	var budget *time.Timer
+	if cfg.MaxDuration > 0 {
+		budget = time.AfterFunc(cfg.MaxDuration, func() {
+			r.stop(fmt.Errorf("--max-duration of %s reached", cfg.MaxDuration))
+		})
+	}
+
+	// Once dispatch stops, give pages in flight the grace period to finish
+	go func() {
+		select {
+		case <-r.dispatch.Done():
+		case <-r.work.Done():
+			return
+		}
+		grace := time.NewTimer(cfg.GracePeriod)
+		defer grace.Stop()
+		select {
+		case <-grace.C:
+			r.abort()
+		case <-r.work.Done():
+		}
+	}()
+
+	go func() {
+		defer close(jobCh)
+		r.err = c.produce(r.dispatch, r, jobCh)
+	}()
+
+	pages := make(chan result, cfg.Concurrency*2)
+	var wg sync.WaitGroup
+	for i := 0; i < cfg.Concurrency; i++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			for j := range jobCh {
+				if r.dispatch.Err() != nil {
+					r.dropped.Add(1)
+					continue
+				}
+				page, err := c.processPage(r.work, r.patterns, j)
+				if err != nil && r.work.Err() != nil {
+					r.aborted.Add(1)
+					continue
+				}
+				select {
+				case pages <- result{page: page, err: err}:
+				case <-r.detached.Done():
+					return
+				}
+			}
+		}(i)
+	}
+
+	// Close pages when all workers finish, and results once every page has
+	// passed through finishPages
+	go func() {
+		wg.Wait()
+		close(pages)
+	}()
+	go func() {
+		defer close(r.results)
+		c.finishPages(r.detached, pages, r.results, r.isQueued)
+		if budget != nil {
+			budget.Stop()
+		}
+		r.abort()
+	}()
+
+	return r
+}
+
+// graceContext returns a context that is cancelled grace after ctx is.
+func graceContext(ctx context.Context, grace time.Duration) (context.Context, context.CancelFunc) {
+	g, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	stop := context.AfterFunc(ctx, func() {
+		time.AfterFunc(grace, cancel)
+	})
+	return g, func() {
+		stop()
+		cancel()
+	}
+}
+
+// produce resolves the sitemap, filters each entry, assigns output paths, and
+// queues jobs. Paths are assigned in sitemap order, so collision resolution is
+// deterministic regardless of which page finishes first.
+func (c *Cloner) produce(ctx context.Context, r *run, jobCh chan<- job) error {
+	cfg := &c.cfg
+	urls, err := urlfilter.Compile(urlRules(cfg))
+	if err != nil {
+		return err
+	}
+	filter := newEntryFilter(cfg, urls)
+
+	// With --fetch-md auto, hold back the first few jobs until the pattern
+	// has been detected from them.
+	detecting := !r.planOnly && c.mdPatterns != nil && c.mdPatterns.Patterns[0] == converter.AutoPattern
+	if !detecting {
+		r.patterns = c.mdPatterns
+	}
+	var held []job
+
+	send := func(j job) error {
+		if cfg.MaxPages > 0 && r.queued.Load() >= int64(cfg.MaxPages) {
+			return errPageBudget
+		}
+		r.expect(j.entry.Loc)
+		select {
+		case jobCh <- j:
+			r.queued.Add(1)
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	flush := func() error {
+		if !detecting {
+			return nil
+		}
+		detecting = false
+		r.patterns = c.detectPattern(ctx, held)
+		for _, j := range held {
+			if err := send(j); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// queue assigns an output path to a filtered entry and hands it to the
+	// workers.
+	queue := func(e sitemap.URL) error {
+		version, stripped, _ := versionOf(e.Loc)
+
+		var path string
+		var collided bool
+		var err error
+		if cfg.VersionDirs {
+			dir := version
+			if dir == "" {
+				dir = versions.Latest
+			}
+			path, collided, err = c.paths.AssignUnder(e.Loc, stripped, dir)
+		} else {
+			path, collided, err = c.paths.Assign(e.Loc)
+		}
+		if err != nil {
+			c.logf("WARNING: skipping %s: %v", e.Loc, err)
+			return nil
+		}
+		if collided && cfg.Verbose {
+			c.logf("Path collision for %s; writing to %s", e.Loc, path)
+		}
+		if c.paths.MultipleHosts() {
+			c.logf("Hint: the sitemap spans several hosts; use --host-dirs to keep them in separate trees.")
+		}
+
+		j := job{entry: e, path: path, version: version}
+		if detecting {
+			held = append(held, j)
+			if len(held) < autoSampleSize {
+				return nil
+			}
+			return flush()
+		}
+		return send(j)
+	}
+
+	// Choosing the latest version needs every entry, so with --versions
+	// other than "all" entries are held until the sitemap has been read.
+	var pending []sitemap.URL
+	found := 0
+	emit := func(e sitemap.URL) error {
+		found++
+		if !filter.keep(e) {
+			return nil
+		}
+		if !c.versions.KeepsAll() {
+			pending = append(pending, e)
+			return nil
+		}
+		return queue(e)
+	}
+
+	stats, err := c.resolve(ctx, emit)
+	if err == nil && pending != nil {
+		err = c.queueVersions(pending, filter, queue)
+	}
+	if err == nil {
+		err = flush()
+	}
+	if errors.Is(err, errPageBudget) {
+		r.limited = true
+		return nil
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil
+		}
+		return fmt.Errorf("sitemap: %w", err)
+	}
+
+	kept := int(r.queued.Load())
+	if stats.Format == sitemap.FormatSitemap {
+		c.logf("Read %d sitemaps (%d failed, %d skipped by --sitemap-include/--sitemap-exclude, %d too deep, %d repeated references)",
+			stats.Sitemaps, stats.Failed, stats.Filtered, stats.TooDeep, stats.Repeated)
+	} else {
+		c.logf("Read %s", stats.Format)
+	}
+	c.logf("Found %d URLs in sitemap (%d duplicates removed), %d after filtering", found, stats.Duplicates, kept)
+	filter.report(c, kept)
+	if kept == 0 {
+		c.logf("No URLs found after filtering. Nothing to do.")
+	}
+	return nil
+}
+
+// queueVersions queues the held entries that the --versions selector keeps.
+func (c *Cloner) queueVersions(entries []sitemap.URL, filter *entryFilter, queue func(sitemap.URL) error) error {
+	pages := make([]versions.Page, len(entries))
+	for i, e := range entries {
+		v, stripped, root := versionOf(e.Loc)
+		pages[i] = versions.Page{
+			Version:  v,
+			Root:     strings.ToLower(strings.TrimSuffix(root, "/")),
+			Stripped: strings.ToLower(strings.TrimSuffix(stripped, "/")),
+		}
+	}
+	latest := versions.LatestByRoot(pages)
+	roots := slices.Sorted(maps.Keys(latest))
+	for _, root := range roots {
+		c.logf("Latest numbered version under %s: %s", root, latest[root])
+	}
+
+	for i, keep := range c.versions.Select(pages) {
+		if !keep {
+			filter.byVersion++
+			continue
+		}
+		if err := queue(entries[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// versionOf returns the version segment of a page URL, or "", the URL with
+// that segment removed, and the URL up to the segment (the whole URL if
+// there is none).
+func versionOf(rawURL string) (version, stripped, root string) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", rawURL, rawURL
+	}
+	version, idx := versions.FromPath(u.Path)
+	if idx < 0 {
+		return "", rawURL, rawURL
+	}
+	s := versions.StripSegment(u, idx)
+	r := *s
+	r.RawQuery, r.Fragment = "", ""
+	r.Path = "/" + strings.Join(strings.FieldsFunc(u.Path, func(c rune) bool { return c == '/' })[:idx], "/")
+	return version, s.String(), r.String()
+}
+
+// resolve streams page entries from the configured source to emit: a local
+// HTML directory, or a sitemap, feed or URL list including sitemap index
+// recursion.
+func (c *Cloner) resolve(ctx context.Context, emit func(sitemap.URL) error) (sitemap.ResolveStats, error) {
+	cfg := &c.cfg
+
+	if cfg.FromDir != "" {
+		c.logf("Reading HTML files from %s", cfg.FromDir)
+		err := sitemap.WalkDir(ctx, cfg.FromDir, cfg.BaseURL, emit)
+		return sitemap.ResolveStats{Format: sitemap.FormatDir}, err
+	}
+
+	source := c.source()
+	if isRemote(source) {
+		c.logf("Fetching sitemap: %s", source)
+	} else {
+		c.logf("Reading URLs from %s", sourceName(source))
+	}
+	resolver := &sitemap.Resolver{
+		Open:        c.openURL,
+		MaxDepth:    cfg.SitemapMaxDepth,
+		Concurrency: cfg.SitemapConcurrency,
+		Logf:        c.logf,
+	}
+	if len(cfg.SitemapInclude) > 0 || len(cfg.SitemapExclude) > 0 {
+		resolver.Allow = func(u string) bool {
+			return matchesFilter(u, cfg.SitemapInclude, cfg.SitemapExclude)
+		}
+	}
+	return resolver.Resolve(ctx, source, emit)
+}
+
+// source returns the URL, path or "-" that page URLs are read from.
+func (c *Cloner) source() string {
+	if c.cfg.URLsFrom != "" {
+		return c.cfg.URLsFrom
+	}
+	return c.cfg.SitemapURL
+}
+
+// sourceName describes a local source for log messages.
+func sourceName(source string) string {
+	if source == "-" {
+		return "standard input"
+	}
+	return source
+}
+
+// entryFilter applies the URL, language, and lastmod filters to sitemap
+// entries one at a time and counts what each filter dropped.
+type entryFilter struct {
+	cfg          *Config
+	urls         *urlfilter.Set
+	byLang       int
+	bySince      int
+	byVersion    int
+	unknownSince int
+}
+
+func newEntryFilter(cfg *Config, urls *urlfilter.Set) *entryFilter {
+	return &entryFilter{cfg: cfg, urls: urls}
+}
+
+func (f *entryFilter) keep(e sitemap.URL) bool {
+	cfg := f.cfg
+
+	// Filter URLs by substring, glob, regex and host rules
+	if !f.urls.Keep(e.Loc) {
+		return false
+	}
+
+	// Keep one locale, using hreflang alternates
+	if cfg.Lang != "" && !e.MatchesLang(cfg.Lang) {
+		f.byLang++
+		return false
+	}
+
+	// Keep recently changed pages; pages without a lastmod are kept
+	if !cfg.Since.IsZero() {
+		t, ok := e.LastModified()
+		if !ok {
+			f.unknownSince++
+		} else if t.Before(cfg.Since) {
+			f.bySince++
+			return false
+		}
+	}
+
+	return true
+}
+
+// report logs how many entries each filter rule dropped. kept is the number
+// of entries that passed every filter.
+func (f *entryFilter) report(c *Cloner, kept int) {
+	cfg := f.cfg
+	for _, d := range f.urls.Report() {
+		c.logf("  %s dropped %d URLs", d.Rule, d.Count)
+	}
+	if kept == 0 && runtime.GOOS == "windows" && (len(cfg.Include) > 0 || len(cfg.Exclude) > 0) {
+		c.logf("Hint: Git Bash rewrites args starting with \"/\" into Windows paths.")
+		c.logf("      Use --include docs/en/ (no leading /), --include-glob docs/en/**, or set MSYS_NO_PATHCONV=1")
+	}
+	if cfg.Lang != "" {
+		c.logf("  language %q dropped %d URLs", cfg.Lang, f.byLang)
+	}
+	if cfg.Versions != "" && cfg.Versions != versions.All {
+		c.logf("  --versions %s dropped %d URLs", cfg.Versions, f.byVersion)
+	}
+	if !cfg.Since.IsZero() {
+		c.logf("  --since %s dropped %d URLs (%d without lastmod kept)",
+			cfg.Since.Format("2006-01-02"), f.bySince, f.unknownSince)
+	}
+}
+
+// urlRules returns the URL filter rules configured in cfg.
+func urlRules(cfg *Config) urlfilter.Rules {
+	return urlfilter.Rules{
+		Include:      cfg.Include,
+		Exclude:      cfg.Exclude,
+		IncludeGlob:  cfg.IncludeGlob,
+		ExcludeGlob:  cfg.ExcludeGlob,
+		IncludeRegex: cfg.IncludeRegex,
+		ExcludeRegex: cfg.ExcludeRegex,
+		IncludeHost:  cfg.IncludeHost,
+		ExcludeHost:  cfg.ExcludeHost,
+	}
+}
+
+// openURL streams a URL's body if the fetcher supports it and falls back to
+// a buffered Get otherwise. "-" reads standard input, and anything that is not
+// an http(s) URL is read as a local file, so --urls-from can name either.
+func (c *Cloner) openURL(ctx context.Context, rawURL string) (io.ReadCloser, error) {
+	if !isRemote(rawURL) {
+		return openLocal(rawURL)
+	}
+	if o, ok := c.fetcher.(fetcher.Opener); ok {
+		return o.Open(ctx, rawURL)
+	}
+	resp, err := c.fetcher.Get(ctx, rawURL, "")
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(resp.Body)), nil
+}
+
+// isRemote reports whether source is an http(s) URL.
+func isRemote(source string) bool {
+	lower := strings.ToLower(source)
+	return strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://")
+}
+
+// openLocal opens a local source: "-" for standard input, a file:// URL, or
+// a plain file path.
+func openLocal(source string) (io.ReadCloser, error) {
+	if source == "-" {
+		return io.NopCloser(os.Stdin), nil
+	}
+	if strings.HasPrefix(source, "file://") {
+		u, err := url.Parse(source)
+		if err != nil {
+			return nil, fmt.Errorf("invalid file URL %q: %w", source, err)
+		}
+		source = filepath.FromSlash(u.Path)
+	}
+	return os.Open(source)
+}
+
+// mdPatternSet builds the --fetch-md pattern set, or returns nil in
+// HTML-to-markdown mode. With --fetch-md auto the set holds the single
+// pattern "auto" until detectPattern replaces it.
+func mdPatternSet(cfg *Config) (*converter.PatternSet, error) {
+	if len(cfg.FetchMD) == 0 {
+		return nil, nil
+	}
+
+	ps := &converter.PatternSet{Patterns: cfg.FetchMD}
+	if cfg.FetchMDMatch != "" {
+		re, err := regexp.Compile(cfg.FetchMDMatch)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --fetch-md-match: %w", err)
+		}
+		ps.Match = re
+	}
+	return ps, nil
+}
+
+// detectPattern probes the given jobs' pages to pick a raw markdown pattern
+// for --fetch-md auto. A nil result means HTML-to-markdown mode.
+func (c *Cloner) detectPattern(ctx context.Context, samples []job) *converter.PatternSet {
+	urls := make([]string, len(samples))
+	for i, j := range samples {
+		urls[i] = j.entry.Loc
+	}
+
+	c.logf("Detecting raw markdown pattern from %d sample pages...", len(urls))
+	pattern := converter.DetectPattern(c.fetcher, ctx, urls, c.mdPatterns.Match)
+	if pattern == "" {
+		if ctx.Err() == nil {
+			c.logf("No raw markdown pattern found; using HTML conversion.")
+		}
+		return nil
+	}
+	c.logf("Using raw markdown pattern: %s", pattern)
+	return &converter.PatternSet{Patterns: []string{pattern}, Match: c.mdPatterns.Match}
+}
+
+// autoSampleSize is the number of pages probed by --fetch-md auto.
+const autoSampleSize = 3
+
+// processPage fetches and converts a single page to markdown. When patterns
+// is non-nil, raw markdown is tried first and pages whose markdown is missing
+// or turns out to be HTML fall back to the HTML extraction path.
+func (c *Cloner) processPage(ctx context.Context, patterns *converter.PatternSet, j job) (Page, error) {
+	pageURL := j.entry.Loc
+	page := Page{URL: pageURL, Path: j.path, Mode: ModeHTML, Version: j.version, Sitemap: j.entry}
+
+	if patterns != nil {
+		md, err := converter.FetchRawMD(c.fetcher, ctx, pageURL, patterns)
+		if err == nil {
+			page.Markdown = md
+			page.Title = converter.ExtractTitleFromMarkdown(md)
+			page.Mode = ModeMarkdown
+		} else {
+			if ctx.Err() != nil {
+				return page, err
+			}
+			if c.cfg.Verbose {
+				c.logf("Falling back to HTML for %s: %v", pageURL, err)
+			}
+			page.Mode = ModeHTMLFallback
+		}
+	}
+
+	if page.Mode != ModeMarkdown {
+		if err := c.fetchPage(ctx, &page); err != nil {
+			return page, err
+		}
+	}
+
+	page.Markdown = converter.CleanMarkdown(page.Markdown)
+	page.CrawlDate = time.Now()
+
+	return page, nil
+}
+
+// fetchPage fetches page.URL and converts it to markdown, filling in the
+// page's body, title, and mode. With --accept-markdown the request prefers
+// markdown and the response is routed by Content-Type: markdown is used as-is
+// (ModeNegotiated), anything else goes through HTML extraction and
+// conversion. Pages that are markdown files in their own right, such as .md
+// links from llms.txt, are always used as-is (ModeMarkdown). HTML pages
+// without a version in their URL get one from version markers in the HTML.
+func (c *Cloner) fetchPage(ctx context.Context, page *Page) error {
+	accept := ""
+	if c.cfg.AcceptMD {
+		accept = converter.AcceptMarkdown
+	}
+
+	resp, err := c.fetcher.Get(ctx, page.URL, accept)
+	if err != nil {
+		return err
+	}
+
+	mode := ""
+	switch {
+	case c.cfg.AcceptMD && converter.IsMarkdownResponse(resp):
+		mode = ModeNegotiated
+	case converter.IsMarkdownDocument(resp):
+		mode = ModeMarkdown
+	}
+	if mode != "" {
+		page.Markdown = converter.CleanMarkdown(string(resp.Body))
+		page.Title = converter.ExtractTitleFromMarkdown(page.Markdown)
+		page.Mode = mode
+		return nil
+	}
+
+	clientRendered, err := c.convertHTML(page, resp.Body)
+	if err != nil {
+		return err
+	}
+	if !clientRendered && !c.thin(page) {
+		return nil
+	}
+	if c.embedded(ctx, page, resp.Body) {
+		return nil
+	}
+	if c.renderer != nil {
+		c.render(ctx, page)
+	}
+	return nil
+}
+
+// convertHTML extracts and converts an HTML page into page and collects its
+// metadata. It reports whether the page is an app shell that is rendered in
+// the browser.
+func (c *Cloner) convertHTML(page *Page, body []byte) (clientRendered bool, err error) {
+	html, title, err := c.extractor.Extract(body, page.URL)
+	if err != nil {
+		return false, fmt.Errorf("extraction: %w", err)
+	}
+
+	markdown, err := c.converter.Convert(html, page.URL)
+	if err != nil {
+		return false, fmt.Errorf("conversion: %w", err)
+	}
+
+	page.Markdown = markdown
+	page.Title = title
+	if doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body)); err == nil {
+		page.Metadata = extractor.Metadata(doc, page.URL)
+		if page.Version == "" {
+			page.Version = versions.FromDocument(doc)
+		}
+		clientRendered = extractor.ClientRendered(doc)
+		doc.Find("script, style, noscript, template").Remove()
+		page.pageWords = writer.WordCount(doc.Find("body").Text())
+	}
+	return clientRendered, nil
+}
+
+// thin reports whether a converted page fails a quality check.
+func (c *Cloner) thin(page *Page) bool {
+	return len(c.quality.Check(quality.Page{
+		URL:       page.URL,
+		Title:     page.Title,
+		Markdown:  page.Markdown,
+		PageWords: page.pageWords,
+	})) > 0
+}
+
+// embedded replaces page with the content found in the page's hydration
+// data (Next.js, Nuxt, or a Gatsby page-data.json file) and reports whether
+// there was any. The title found in the HTML is kept if there is one.
+func (c *Cloner) embedded(ctx context.Context, page *Page, body []byte) bool {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+	if err != nil {
+		return false
+	}
+	content, ok := extractor.EmbeddedContent(doc)
+	if !ok {
+		dataURL := extractor.GatsbyPageDataURL(doc, page.URL)
+		if dataURL == "" {
+			return false
+		}
+		resp, err := c.fetcher.Get(ctx, dataURL, "")
+		if err != nil {
+			if c.cfg.Verbose {
+				c.logf("No Gatsby page data for %s: %v", page.URL, err)
+			}
+			return false
+		}
+		if content, ok = extractor.EmbeddedFromPageData(resp.Body); !ok {
+			return false
+		}
+	}
+
+	markdown := content.Body
+	if content.Format == extractor.EmbeddedHTML {
+		if markdown, err = c.converter.Convert(content.Body, page.URL); err != nil {
+			c.logf("WARNING: converting %s data of %s: %v", content.Source, page.URL, err)
+			return false
+		}
+	}
+	page.Markdown = markdown
+	if page.Title == "" {
+		page.Title = content.Title
+	}
+	if page.Title == "" {
+		page.Title = converter.ExtractTitleFromMarkdown(markdown)
+	}
+	page.Mode = ModeEmbedded
+	if c.cfg.Verbose {
+		c.logf("Extracted %s from %s data", page.URL, content.Source)
+	}
+	return true
+}
+
+// render replaces page with the conversion of its rendered DOM. If rendering
+// fails, the page is kept as served and a warning is logged.
+func (c *Cloner) render(ctx context.Context, page *Page) {
+	body, err := c.renderer.Render(ctx, page.URL)
+	if err != nil {
+		c.logf("WARNING: rendering %s: %v", page.URL, err)
+		return
+	}
+	rendered := *page
+	if _, err := c.convertHTML(&rendered, body); err != nil {
+		c.logf("WARNING: rendering %s: %v", page.URL, err)
+		return
+	}
+	rendered.Mode = ModeRendered
+	*page = rendered
+	if c.cfg.Verbose {
+		c.logf("Rendered %s", page.URL)
+	}
+}
+
+// matchesFilter returns true if the URL passes include/exclude filters.
+// If include is non-empty, the URL must contain at least one include substring.
+// If exclude is non-empty, the URL must not contain any exclude substring.
+func matchesFilter(url string, include, exclude []string) bool {
+	if len(include) > 0 {
+		matched := false
+		for _, pattern := range include {
+			if strings.Contains(url, pattern) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	for _, pattern := range exclude {
+		if strings.Contains(url, pattern) {
+			return false
+		}
+	}
+	return true
+}
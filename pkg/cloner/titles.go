@@ -0,0 +1,32 @@
+package cloner
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/Devon-White/docs-cloner/internal/extractor"
+)
+
+// titleSampleSize is how many completed pages are held to collect their
+// <title>s before the shared site-name prefix and suffix are chosen.
+const titleSampleSize = 8
+
+// cleanTitle applies --title-strip, or removes the learned site-name prefix
+// and suffix.
+func (c *Cloner) cleanTitle(title, prefix, suffix string) string {
+	if c.titleStrip != nil {
+		if stripped := strings.TrimSpace(c.titleStrip.ReplaceAllString(title, "")); stripped != "" {
+			return stripped
+		}
+		return title
+	}
+	return extractor.StripAffixes(title, prefix, suffix)
+}
+
+// compileTitleStrip compiles the --title-strip regex, or returns nil.
+func compileTitleStrip(expr string) (*regexp.Regexp, error) {
+	if expr == "" {
+		return nil, nil
+	}
+	return regexp.Compile(expr)
+}